@@ -9,6 +9,10 @@ type ClientConnInterface interface {
 	// Invoke performs a unary RPC and returns after the response is received
 	// into reply.
 	Invoke(ctx context.Context, method string, args any, reply any) error
+	// NewStream begins a streaming RPC. The stream ends when RecvMsg returns
+	// a non-nil error or ctx is done; callers should cancel ctx if they stop
+	// receiving before that.
+	NewStream(ctx context.Context, desc *StreamDesc, method string) (ClientStream, error)
 }
 
 // ServiceRegistrar wraps a single method that supports service registration. It
@@ -20,5 +24,38 @@ type ServiceRegistrar interface {
 	// once the server has started serving.
 	// desc describes the service and its methods and handlers. impl is the
 	// service implementation which is passed to the method handlers.
-	RegisterService(serverName string, impl any)
+	// Only the methods of desc.HandlerType can be called remotely.
+	// It returns an error if impl does not implement desc.HandlerType, if a
+	// method does not match the expected signature, or if the service has
+	// already been registered.
+	RegisterService(desc *ServiceDesc, impl any) error
+}
+
+// ServiceDesc represents an RPC service's specification.
+type ServiceDesc struct {
+	// ServiceName is the name used in the method of a call, e.g. the
+	// "hello_service" in "hello_service.Hello".
+	ServiceName string
+	// HandlerType is a pointer to the service interface, e.g.
+	// (*pb.IHelloService)(nil). It is used to check whether the user
+	// provided implementation satisfies the interface requirements, and its
+	// methods are the only ones exposed to clients.
+	HandlerType any
+	// Methods lists the unary methods exposed to clients. If it is empty,
+	// all the methods of HandlerType not listed in Streams are exposed.
+	// Every method must be declared in HandlerType.
+	Methods []MethodDesc
+	// Streams lists the streaming methods exposed to clients. Every stream
+	// must be declared in HandlerType.
+	Streams []StreamDesc
+	// Metadata is the metadata of the service, e.g. the .proto file it was
+	// generated from. It is not used by the framework.
+	Metadata any
+}
+
+// MethodDesc represents an RPC service's method specification.
+type MethodDesc struct {
+	// MethodName is the name used in the method of a call, e.g. the "Hello"
+	// in "hello_service.Hello".
+	MethodName string
 }
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"v2/trpc/metadata"
+)
+
+// StreamHandler defines the handler called by the server to complete the
+// execution of a streaming RPC. srv is the service implementation.
+type StreamHandler func(srv any, stream ServerStream) error
+
+// StreamDesc represents a streaming RPC service's method specification.
+type StreamDesc struct {
+	// StreamName is the method name of the stream, e.g. the "ListUsers" in
+	// "user_service.ListUsers".
+	StreamName string
+	// Handler is the handler called for the method.
+	Handler StreamHandler
+
+	// ServerStreams and ClientStreams indicate whether the server or the
+	// client can perform streaming sends.
+	ServerStreams bool
+	ClientStreams bool
+}
+
+// ClientStream defines the client-side behavior of a streaming RPC.
+type ClientStream interface {
+	// Header returns the header metadata received from the server. It
+	// blocks until the header is received or the stream ends.
+	Header() (metadata.MD, error)
+	// Trailer returns the trailer metadata from the server. It must only be
+	// called after RecvMsg returns a non-nil error.
+	Trailer() metadata.MD
+	// CloseSend closes the send direction of the stream.
+	CloseSend() error
+	// Context returns the context for this stream. It is done when the
+	// stream ends.
+	Context() context.Context
+	// SendMsg sends m to the server. It returns io.EOF if the stream has
+	// ended; the status can be discovered with RecvMsg.
+	SendMsg(m any) error
+	// RecvMsg blocks until it receives a message into m or the stream is
+	// done. It returns io.EOF when the stream completes successfully, and
+	// the status error otherwise. If the server does not stream, RecvMsg
+	// waits for the stream to end after the single response and returns
+	// nil only if it completed successfully.
+	RecvMsg(m any) error
+}
+
+// ServerStream defines the server-side behavior of a streaming RPC.
+type ServerStream interface {
+	// SetHeader sets the header metadata. It may be called multiple times,
+	// the provided metadata will be merged. It fails once the header has
+	// been sent.
+	SetHeader(metadata.MD) error
+	// SendHeader sends the header metadata. It fails if called multiple
+	// times.
+	SendHeader(metadata.MD) error
+	// SetTrailer sets the trailer metadata which will be sent with the
+	// status. It may be called multiple times, the provided metadata will
+	// be merged.
+	SetTrailer(metadata.MD)
+	// Context returns the context for this stream. It is done when the
+	// client cancels the stream or the connection is closed.
+	Context() context.Context
+	// SendMsg sends m to the client. The header is sent before the first
+	// message.
+	SendMsg(m any) error
+	// RecvMsg blocks until it receives a message into m or the stream is
+	// done. It returns io.EOF when the client has called CloseSend.
+	RecvMsg(m any) error
+}
+
+// ServerStreamingClient represents the client side of a server-streaming
+// RPC, where the server sends a sequence of Res messages.
+type ServerStreamingClient[Res any] interface {
+	// Recv receives the next response message. It returns io.EOF when the
+	// stream completes successfully.
+	Recv() (*Res, error)
+	ClientStream
+}
+
+// ServerStreamingServer represents the server side of a server-streaming
+// RPC, where the server sends a sequence of Res messages.
+type ServerStreamingServer[Res any] interface {
+	// Send sends a response message to the client.
+	Send(*Res) error
+	ServerStream
+}
+
+// ClientStreamingClient represents the client side of a client-streaming
+// RPC, where the client sends a sequence of Req messages and the server
+// replies with a single Res message.
+type ClientStreamingClient[Req any, Res any] interface {
+	// Send sends a request message to the server.
+	Send(*Req) error
+	// CloseAndRecv closes the send direction of the stream and waits for
+	// the response from the server.
+	CloseAndRecv() (*Res, error)
+	ClientStream
+}
+
+// ClientStreamingServer represents the server side of a client-streaming
+// RPC, where the client sends a sequence of Req messages and the server
+// replies with a single Res message.
+type ClientStreamingServer[Req any, Res any] interface {
+	// Recv receives the next request message. It returns io.EOF when the
+	// client has called CloseSend.
+	Recv() (*Req, error)
+	// SendAndClose sends the response to the client. The handler should
+	// return right after calling it.
+	SendAndClose(*Res) error
+	ServerStream
+}
+
+// BidiStreamingClient represents the client side of a bidirectional
+// streaming RPC, where both sides send independent sequences of messages.
+type BidiStreamingClient[Req any, Res any] interface {
+	// Send sends a request message to the server.
+	Send(*Req) error
+	// Recv receives the next response message. It returns io.EOF when the
+	// stream completes successfully.
+	Recv() (*Res, error)
+	ClientStream
+}
+
+// BidiStreamingServer represents the server side of a bidirectional
+// streaming RPC, where both sides send independent sequences of messages.
+type BidiStreamingServer[Req any, Res any] interface {
+	// Recv receives the next request message. It returns io.EOF when the
+	// client has called CloseSend.
+	Recv() (*Req, error)
+	// Send sends a response message to the client.
+	Send(*Res) error
+	ServerStream
+}
+
+// GenericClientStream implements the typed client stream interfaces on top
+// of a ClientStream. It is only intended to be used by generated code.
+type GenericClientStream[Req any, Res any] struct {
+	ClientStream
+}
+
+// Send sends a request message to the server.
+func (x *GenericClientStream[Req, Res]) Send(m *Req) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+// Recv receives the next response message from the server.
+func (x *GenericClientStream[Req, Res]) Recv() (*Res, error) {
+	m := new(Res)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CloseAndRecv closes the send direction of the stream and receives the
+// single response message from the server.
+func (x *GenericClientStream[Req, Res]) CloseAndRecv() (*Res, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Res)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GenericServerStream implements the typed server stream interfaces on top
+// of a ServerStream. It is only intended to be used by generated code.
+type GenericServerStream[Req any, Res any] struct {
+	ServerStream
+}
+
+// Send sends a response message to the client.
+func (x *GenericServerStream[Req, Res]) Send(m *Res) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Recv receives the next request message from the client.
+func (x *GenericServerStream[Req, Res]) Recv() (*Req, error) {
+	m := new(Req)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SendAndClose sends the single response message to the client.
+func (x *GenericServerStream[Req, Res]) SendAndClose(m *Res) error {
+	return x.ServerStream.SendMsg(m)
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log"
 	"time"
 	"v2/pb"
@@ -18,6 +20,9 @@ func main() {
 
 	user := getUser(client, 1)
 	sayHello(client, user.Name)
+	addUsers(client)
+	listUsers(client)
+	getUsers(client, 1, 3)
 }
 
 func getUser(client *trpc.Client, uid int64) *pb.User {
@@ -52,3 +57,83 @@ func sayHello(client *trpc.Client, name string) {
 
 	log.Printf("服务器响应: %s", r.Msg)
 }
+
+func listUsers(client *trpc.Client) {
+	c := pb.NewUserClient(client)
+
+	// 设置超时上下文，提前返回时取消流
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream, err := c.ListUsers(ctx, &pb.ApplyList{})
+	if err != nil {
+		log.Fatalf("调用 ListUsers 方法失败: %v", err)
+	}
+
+	for {
+		user, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			log.Fatalf("接收用户失败: %v", err)
+		}
+		log.Printf("服务器响应: %+v", user)
+	}
+}
+
+func addUsers(client *trpc.Client) {
+	c := pb.NewUserClient(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream, err := c.AddUsers(ctx)
+	if err != nil {
+		log.Fatalf("调用 AddUsers 方法失败: %v", err)
+	}
+	for _, user := range []*pb.User{{Uid: 3, Name: "Wang", Age: 20, Sex: 1}, {Uid: 4, Name: "Zhao", Age: 21, Sex: 2}} {
+		if err := stream.Send(user); err != nil {
+			log.Fatalf("发送用户失败: %v", err)
+		}
+	}
+
+	r, err := stream.CloseAndRecv()
+	if err != nil {
+		log.Fatalf("调用 AddUsers 方法失败: %v", err)
+	}
+	log.Printf("服务器响应: 添加了 %d 个用户", r.Count)
+}
+
+func getUsers(client *trpc.Client, uids ...int64) {
+	c := pb.NewUserClient(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream, err := c.GetUsers(ctx)
+	if err != nil {
+		log.Fatalf("调用 GetUsers 方法失败: %v", err)
+	}
+
+	// 发送和接收互不阻塞，在另一个 goroutine 中发送
+	go func() {
+		for _, uid := range uids {
+			if err := stream.Send(&pb.ApplyUser{Uid: uid}); err != nil {
+				return
+			}
+		}
+		stream.CloseSend()
+	}()
+
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			log.Fatalf("接收用户失败: %v", err)
+		}
+		log.Printf("服务器响应: %+v", r.User)
+	}
+}
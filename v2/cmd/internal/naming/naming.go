@@ -0,0 +1,40 @@
+// Package naming 代码生成工具共用的命名规则
+package naming
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ServiceName 将服务的 Go 名称转换为调用时使用的服务名称，与手写的 pb 保持一致：
+// Hello -> hello_service，UserService -> user_service，HTTPProxy -> http_proxy_service。
+// 服务名称中不能包含 "."
+func ServiceName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// 在单词边界处插入下划线：aB -> a_b，ABc -> a_bc
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	s := b.String()
+	if s != "service" && !strings.HasSuffix(s, "_service") {
+		s += "_service"
+	}
+	return s
+}
+
+// BaseName 去掉服务名称末尾的 Service，UserService -> User，Service 保持不变
+func BaseName(name string) string {
+	if trimmed := strings.TrimSuffix(name, "Service"); trimmed != "" {
+		return trimmed
+	}
+	return name
+}
@@ -0,0 +1,46 @@
+//go:build unit
+
+package naming
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Hello", want: "hello_service"},
+		{name: "User", want: "user_service"},
+		{name: "UserService", want: "user_service"},
+		{name: "HTTPProxy", want: "http_proxy_service"},
+		{name: "OAuth2Token", want: "o_auth2_token_service"},
+		{name: "Service", want: "service"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ServiceName(tt.name))
+		})
+	}
+}
+
+func TestBaseName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Hello", want: "Hello"},
+		{name: "UserService", want: "User"},
+		{name: "Service", want: "Service"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, BaseName(tt.name))
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// protoc-gen-go-trpc 是 protoc 的插件，根据 .proto 文件中的 service 生成 trpc 的客户端和服务端代码，
+// 消息类型仍由 protoc-gen-go 生成：
+//
+//	go install v2/cmd/protoc-gen-go-trpc
+//	protoc --go_out=. --go-trpc_out=. hello.proto
+//
+// 每个 .proto 文件生成一个 xxx_trpc.pb.go，包含服务名称和方法名称常量、XClient、NewXClient、
+// IXService、UnimplementedXService、XServiceDesc 和 RegisterXServer。
+// 生成的代码只依赖 api.ClientConnInterface 和 api.ServiceRegistrar，客户端需要使用 codec.Proto 编码
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const version = "0.1.0"
+
+func main() {
+	showVersion := flag.Bool("version", false, "打印版本后退出")
+	flag.Parse()
+	if *showVersion {
+		fmt.Printf("protoc-gen-go-trpc %s\n", version)
+		return
+	}
+
+	protogen.Options{}.Run(func(gen *protogen.Plugin) error {
+		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+		for _, f := range gen.Files {
+			if !f.Generate {
+				continue
+			}
+			if err := generateFile(gen, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
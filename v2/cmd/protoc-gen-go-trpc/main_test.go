@@ -0,0 +1,145 @@
+//go:build unit
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+var update = flag.Bool("update", false, "更新 testdata 中的期望输出")
+
+// helloFile 与 testdata/hello.proto 对应的文件描述，测试环境没有 protoc，手动构造
+func helloFile() *descriptorpb.FileDescriptorProto {
+	message := func(name, field string, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{
+			Name: proto.String(name),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String(field),
+				JsonName: proto.String(field),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     typ.Enum(),
+			}},
+		}
+	}
+	method := func(name, input, output string) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(".hello." + input),
+			OutputType: proto.String(".hello." + output),
+		}
+	}
+
+	serverStream := func(name, input, output string) *descriptorpb.MethodDescriptorProto {
+		m := method(name, input, output)
+		m.ServerStreaming = proto.Bool(true)
+		return m
+	}
+	clientStream := func(name, input, output string) *descriptorpb.MethodDescriptorProto {
+		m := method(name, input, output)
+		m.ClientStreaming = proto.Bool(true)
+		return m
+	}
+	bidiStream := func(name, input, output string) *descriptorpb.MethodDescriptorProto {
+		m := clientStream(name, input, output)
+		m.ServerStreaming = proto.Bool(true)
+		return m
+	}
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("hello.proto"),
+		Package: proto.String("hello"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("example.com/hello;hello")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			message("ApplyHello", "name", descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			message("ReplyHello", "msg", descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			message("ApplyUser", "uid", descriptorpb.FieldDescriptorProto_TYPE_INT64),
+			message("ReplyUser", "name", descriptorpb.FieldDescriptorProto_TYPE_STRING),
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{
+				Name: proto.String("Hello"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					method("Hello", "ApplyHello", "ReplyHello"),
+					method("Bye", "ApplyHello", "ReplyHello"),
+					serverStream("Watch", "ApplyHello", "ReplyHello"),
+					clientStream("Collect", "ApplyHello", "ReplyHello"),
+					bidiStream("Chat", "ApplyHello", "ReplyHello"),
+				},
+			},
+			{
+				Name:   proto.String("UserService"),
+				Method: []*descriptorpb.MethodDescriptorProto{method("User", "ApplyUser", "ReplyUser")},
+			},
+		},
+		SourceCodeInfo: &descriptorpb.SourceCodeInfo{
+			Location: []*descriptorpb.SourceCodeInfo_Location{
+				// service Hello
+				{Path: []int32{6, 0}, Span: []int32{5, 0, 9, 1}, LeadingComments: proto.String(" Hello 打招呼服务\n")},
+				// rpc Hello
+				{Path: []int32{6, 0, 2, 0}, Span: []int32{7, 2, 50}, LeadingComments: proto.String(" Hello 返回 Hello, name!\n")},
+			},
+		},
+	}
+}
+
+// generate 运行插件，返回生成的文件内容
+func generate(t *testing.T, file *descriptorpb.FileDescriptorProto) (*pluginpb.CodeGeneratorResponse, error) {
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+		CompilerVersion: &pluginpb.Version{
+			Major: proto.Int32(6),
+			Minor: proto.Int32(33),
+			Patch: proto.Int32(1),
+		},
+	}
+	gen, err := protogen.Options{}.New(req)
+	require.NoError(t, err)
+
+	for _, f := range gen.Files {
+		if f.Generate {
+			if err := generateFile(gen, f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return gen.Response(), nil
+}
+
+func TestGenerateFile(t *testing.T) {
+	resp, err := generate(t, helloFile())
+	require.NoError(t, err)
+	require.Empty(t, resp.GetError())
+	require.Len(t, resp.File, 1)
+	assert.Equal(t, "example.com/hello/hello_trpc.pb.go", resp.File[0].GetName())
+
+	golden := filepath.Join("testdata", "hello_trpc.pb.go.golden")
+	if *update {
+		require.NoError(t, os.WriteFile(golden, []byte(resp.File[0].GetContent()), 0o644))
+	}
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), resp.File[0].GetContent())
+}
+
+func TestGenerateFile_NoService(t *testing.T) {
+	file := helloFile()
+	file.Service = nil
+	file.SourceCodeInfo = nil
+
+	resp, err := generate(t, file)
+	require.NoError(t, err)
+	assert.Empty(t, resp.File)
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"v2/cmd/internal/naming"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+const (
+	contextPackage = protogen.GoImportPath("context")
+	apiPackage     = protogen.GoImportPath("v2/api")
+	codesPackage   = protogen.GoImportPath("v2/trpc/codes")
+	statusPackage  = protogen.GoImportPath("v2/trpc/status")
+)
+
+// generateFile 为 file 中的所有 service 生成代码，没有 service 的文件不生成
+func generateFile(gen *protogen.Plugin, file *protogen.File) error {
+	if len(file.Services) == 0 {
+		return nil
+	}
+
+	filename := file.GeneratedFilenamePrefix + "_trpc.pb.go"
+	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+	g.P("// Code generated by protoc-gen-go-trpc. DO NOT EDIT.")
+	g.P("// versions:")
+	g.P("// - protoc-gen-go-trpc v", version)
+	g.P("// - protoc             ", protocVersion(gen))
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+
+	for _, service := range file.Services {
+		generateService(g, file, service)
+	}
+	return nil
+}
+
+func protocVersion(gen *protogen.Plugin) string {
+	v := gen.Request.GetCompilerVersion()
+	if v == nil {
+		return "(unknown)"
+	}
+	s := fmt.Sprintf("v%d.%d.%d", v.GetMajor(), v.GetMinor(), v.GetPatch())
+	if suffix := v.GetSuffix(); suffix != "" {
+		s += "-" + suffix
+	}
+	return s
+}
+
+func generateService(g *protogen.GeneratedFile, file *protogen.File, service *protogen.Service) {
+	// UserService 生成 UserClient、IUserService，避免 IUserServiceService 这样重复的名称
+	name := naming.BaseName(service.GoName)
+	clientName := name + "Client"
+	serverName := "I" + name + "Service"
+	unimplementedName := "Unimplemented" + name + "Service"
+	descName := name + "ServiceDesc"
+	serviceNameConst := name + "ServiceName"
+
+	// 服务名称和方法名称常量
+	g.P("const (")
+	g.P(serviceNameConst, " = ", fmt.Sprintf("%q", naming.ServiceName(name)))
+	for _, method := range service.Methods {
+		g.P(fullMethodConst(service, method), " = ", serviceNameConst, ` + ".`, method.GoName, `"`)
+	}
+	g.P(")")
+	g.P()
+
+	// 流式方法的类型别名，与 gRPC 生成的名称一致
+	for _, method := range service.Methods {
+		if !isStream(method) {
+			continue
+		}
+		kind, args := streamKind(method), typeArgs(g, method)
+		g.P("// ", streamName(service, method), "Client is the client-side stream of ", method.GoName, ".")
+		g.P("type ", streamName(service, method), "Client = ", apiPackage.Ident(kind+"Client"), "[", args, "]")
+		g.P()
+		g.P("// ", streamName(service, method), "Server is the server-side stream of ", method.GoName, ".")
+		g.P("type ", streamName(service, method), "Server = ", apiPackage.Ident(kind+"Server"), "[", args, "]")
+		g.P()
+	}
+
+	// 客户端
+	g.P("// ", clientName, " is the client API for ", name, " service.")
+	g.P("type ", clientName, " struct {")
+	for _, method := range service.Methods {
+		g.P(method.Comments.Leading, method.GoName, " func", clientSignature(g, service, method))
+	}
+	g.P("}")
+	g.P()
+
+	g.P("func New", clientName, "(c ", apiPackage.Ident("ClientConnInterface"), ") *", clientName, " {")
+	g.P("return &", clientName, "{")
+	streamIndex := 0
+	for _, method := range service.Methods {
+		g.P(method.GoName, ": func", clientSignature(g, service, method), " {")
+		if isStream(method) {
+			g.P("stream, err := c.NewStream(ctx, &", descName, ".Streams[", streamIndex, "], ", fullMethodConst(service, method), ")")
+			g.P("if err != nil {")
+			g.P("return nil, err")
+			g.P("}")
+			streamIndex++
+			genericStream := apiPackage.Ident("GenericClientStream")
+			if method.Desc.IsStreamingClient() {
+				g.P("return &", genericStream, "[", g.QualifiedGoIdent(method.Input.GoIdent), ", ",
+					g.QualifiedGoIdent(method.Output.GoIdent), "]{ClientStream: stream}, nil")
+				g.P("},")
+				continue
+			}
+			g.P("x := &", genericStream, "[", g.QualifiedGoIdent(method.Input.GoIdent), ", ",
+				g.QualifiedGoIdent(method.Output.GoIdent), "]{ClientStream: stream}")
+			g.P("if err := x.ClientStream.SendMsg(in); err != nil {")
+			g.P("return nil, err")
+			g.P("}")
+			g.P("if err := x.ClientStream.CloseSend(); err != nil {")
+			g.P("return nil, err")
+			g.P("}")
+			g.P("return x, nil")
+			g.P("},")
+			continue
+		}
+		g.P("out := new(", g.QualifiedGoIdent(method.Output.GoIdent), ")")
+		g.P("if err := c.Invoke(ctx, ", fullMethodConst(service, method), ", in, out); err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("return out, nil")
+		g.P("},")
+	}
+	g.P("}")
+	g.P("}")
+	g.P()
+
+	g.P("func (s *", clientName, ") Name() string {")
+	g.P("return ", serviceNameConst)
+	g.P("}")
+	g.P()
+
+	// 服务端
+	g.P("// ", serverName, " is the server API for ", name, " service.")
+	if service.Comments.Leading != "" {
+		g.P("//")
+	}
+	g.P(service.Comments.Leading, "type ", serverName, " interface {")
+	for _, method := range service.Methods {
+		g.P(method.Comments.Leading, method.GoName, serverSignature(g, service, method))
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// ", unimplementedName, " can be embedded to have forward compatible implementations.")
+	g.P("type ", unimplementedName, " struct{}")
+	g.P()
+	for _, method := range service.Methods {
+		switch {
+		case method.Desc.IsStreamingClient():
+			g.P("func (", unimplementedName, ") ", method.GoName, "(", streamName(service, method), "Server) error {")
+			g.P("return ", statusPackage.Ident("Error"), "(", codesPackage.Ident("Unimplemented"), `, "method `, method.GoName, ` 未实现")`)
+		case method.Desc.IsStreamingServer():
+			g.P("func (", unimplementedName, ") ", method.GoName, "(", contextPackage.Ident("Context"), ", *",
+				g.QualifiedGoIdent(method.Input.GoIdent), ", ", streamName(service, method), "Server) error {")
+			g.P("return ", statusPackage.Ident("Error"), "(", codesPackage.Ident("Unimplemented"), `, "method `, method.GoName, ` 未实现")`)
+		default:
+			g.P("func (", unimplementedName, ") ", method.GoName, "(", contextPackage.Ident("Context"), ", *",
+				g.QualifiedGoIdent(method.Input.GoIdent), ") (*", g.QualifiedGoIdent(method.Output.GoIdent), ", error) {")
+			g.P("return nil, ", statusPackage.Ident("Error"), "(", codesPackage.Ident("Unimplemented"), `, "method `, method.GoName, ` 未实现")`)
+		}
+		g.P("}")
+		g.P()
+	}
+
+	for _, method := range service.Methods {
+		if !isStream(method) {
+			continue
+		}
+		g.P("func ", handlerName(service, method), "(srv any, stream ", apiPackage.Ident("ServerStream"), ") error {")
+		if method.Desc.IsStreamingClient() {
+			g.P("return srv.(", serverName, ").", method.GoName, "(&", apiPackage.Ident("GenericServerStream"), "[",
+				g.QualifiedGoIdent(method.Input.GoIdent), ", ", g.QualifiedGoIdent(method.Output.GoIdent), "]{ServerStream: stream})")
+			g.P("}")
+			g.P()
+			continue
+		}
+		g.P("in := new(", g.QualifiedGoIdent(method.Input.GoIdent), ")")
+		g.P("if err := stream.RecvMsg(in); err != nil {")
+		g.P("return err")
+		g.P("}")
+		g.P("return srv.(", serverName, ").", method.GoName, "(stream.Context(), in, &", apiPackage.Ident("GenericServerStream"), "[",
+			g.QualifiedGoIdent(method.Input.GoIdent), ", ", g.QualifiedGoIdent(method.Output.GoIdent), "]{ServerStream: stream})")
+		g.P("}")
+		g.P()
+	}
+
+	g.P("// ", descName, " is the ", apiPackage.Ident("ServiceDesc"), " for ", name, " service.")
+	g.P("var ", descName, " = ", apiPackage.Ident("ServiceDesc"), "{")
+	g.P("ServiceName: ", serviceNameConst, ",")
+	g.P("HandlerType: (*", serverName, ")(nil),")
+	g.P("Methods: []", apiPackage.Ident("MethodDesc"), "{")
+	for _, method := range service.Methods {
+		if !isStream(method) {
+			g.P("{MethodName: ", fmt.Sprintf("%q", method.GoName), "},")
+		}
+	}
+	g.P("},")
+	if streamIndex > 0 {
+		g.P("Streams: []", apiPackage.Ident("StreamDesc"), "{")
+		for _, method := range service.Methods {
+			if !isStream(method) {
+				continue
+			}
+			g.P("{")
+			g.P("StreamName: ", fmt.Sprintf("%q", method.GoName), ",")
+			g.P("Handler: ", handlerName(service, method), ",")
+			if method.Desc.IsStreamingServer() {
+				g.P("ServerStreams: true,")
+			}
+			if method.Desc.IsStreamingClient() {
+				g.P("ClientStreams: true,")
+			}
+			g.P("},")
+		}
+		g.P("},")
+	}
+	g.P("Metadata: ", fmt.Sprintf("%q", file.Desc.Path()), ",")
+	g.P("}")
+	g.P()
+
+	g.P("func Register", name, "Server(s ", apiPackage.Ident("ServiceRegistrar"), ", srv ", serverName, ") error {")
+	g.P("return s.RegisterService(&", descName, ", srv)")
+	g.P("}")
+	g.P()
+}
+
+// clientSignature 返回客户端方法的参数和返回值，例如 (ctx context.Context, in *ApplyHello) (*ReplyHello, error)，
+// 流式方法返回流，例如 (ctx context.Context, in *ApplyHello) (Hello_WatchClient, error)，
+// 客户端流式发送的方法没有 in 参数，例如 (ctx context.Context) (Hello_ChatClient, error)
+func clientSignature(g *protogen.GeneratedFile, service *protogen.Service, method *protogen.Method) string {
+	ctx := "ctx " + g.QualifiedGoIdent(contextPackage.Ident("Context"))
+	switch {
+	case method.Desc.IsStreamingClient():
+		return "(" + ctx + ") (" + streamName(service, method) + "Client, error)"
+	case method.Desc.IsStreamingServer():
+		return "(" + ctx + ", in *" + g.QualifiedGoIdent(method.Input.GoIdent) + ") (" + streamName(service, method) + "Client, error)"
+	}
+	return "(" + ctx + ", in *" + g.QualifiedGoIdent(method.Input.GoIdent) + ") (*" + g.QualifiedGoIdent(method.Output.GoIdent) + ", error)"
+}
+
+// serverSignature 返回服务接口方法的参数和返回值，流式方法与 gRPC 一致：
+// 服务端流为 (ctx context.Context, in *ApplyHello, stream Hello_WatchServer) error，
+// 客户端流和双向流为 (stream Hello_ChatServer) error
+func serverSignature(g *protogen.GeneratedFile, service *protogen.Service, method *protogen.Method) string {
+	switch {
+	case method.Desc.IsStreamingClient():
+		return "(stream " + streamName(service, method) + "Server) error"
+	case method.Desc.IsStreamingServer():
+		return "(ctx " + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
+			", in *" + g.QualifiedGoIdent(method.Input.GoIdent) +
+			", stream " + streamName(service, method) + "Server) error"
+	}
+	return clientSignature(g, service, method)
+}
+
+func isStream(method *protogen.Method) bool {
+	return method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer()
+}
+
+// streamKind 返回 api 中流类型名称的前缀，例如 ServerStreaming 对应 api.ServerStreamingClient 和 api.ServerStreamingServer
+func streamKind(method *protogen.Method) string {
+	switch {
+	case method.Desc.IsStreamingClient() && method.Desc.IsStreamingServer():
+		return "BidiStreaming"
+	case method.Desc.IsStreamingClient():
+		return "ClientStreaming"
+	default:
+		return "ServerStreaming"
+	}
+}
+
+// typeArgs 返回流类型的类型参数，服务端流只有响应类型
+func typeArgs(g *protogen.GeneratedFile, method *protogen.Method) string {
+	res := g.QualifiedGoIdent(method.Output.GoIdent)
+	if method.Desc.IsStreamingClient() {
+		return g.QualifiedGoIdent(method.Input.GoIdent) + ", " + res
+	}
+	return res
+}
+
+// streamName 流式方法类型别名的前缀，例如 Hello_Watch
+func streamName(service *protogen.Service, method *protogen.Method) string {
+	return service.GoName + "_" + method.GoName
+}
+
+func handlerName(service *protogen.Service, method *protogen.Method) string {
+	return "_" + service.GoName + "_" + method.GoName + "_Handler"
+}
+
+func fullMethodConst(service *protogen.Service, method *protogen.Method) string {
+	return service.GoName + "_" + method.GoName + "_FullMethodName"
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+	"v2/cmd/internal/naming"
+)
+
+// directive 标记服务接口的注释指令，可以在后面指定服务名称：//trpc:service hello_service
+const directive = "//trpc:service"
+
+// service 一个带有 //trpc:service 指令的接口
+type service struct {
+	Interface   string // 接口名称，例如 IHelloService
+	Name        string // 生成代码的名称前缀，例如 Hello
+	ServiceName string // 调用时使用的服务名称，例如 hello_service
+	Methods     []method
+}
+
+type method struct {
+	Name  string
+	Req   string // 请求类型，不带 *，例如 ApplyHello
+	Reply string // 响应类型，不带 *，例如 ReplyHello
+
+	// 流式方法中服务端或客户端是否流式发送，Req、Reply 为流中消息的类型
+	ServerStreams bool
+	ClientStreams bool
+	StreamIndex   int // 流式方法在 ServiceDesc.Streams 中的下标
+}
+
+// 流式方法中流参数的类型
+const (
+	serverStreamType = "api.ServerStreamingServer" // 第三个参数，类型参数为 [RespType]
+	clientStreamType = "api.ClientStreamingServer" // 唯一的参数，类型参数为 [ReqType, RespType]
+	bidiStreamType   = "api.BidiStreamingServer"   // 唯一的参数，类型参数为 [ReqType, RespType]
+)
+
+// IsStream 报告方法是否为流式方法
+func (m method) IsStream() bool {
+	return m.ServerStreams || m.ClientStreams
+}
+
+// StreamKind 返回流类型名称的前缀，例如 ServerStreaming 对应 api.ServerStreamingClient 和 api.ServerStreamingServer
+func (m method) StreamKind() string {
+	switch {
+	case m.ServerStreams && m.ClientStreams:
+		return "BidiStreaming"
+	case m.ClientStreams:
+		return "ClientStreaming"
+	default:
+		return "ServerStreaming"
+	}
+}
+
+// TypeArgs 返回流类型的类型参数，服务端流只有响应类型
+func (m method) TypeArgs() string {
+	if m.ClientStreams {
+		return m.Req + ", " + m.Reply
+	}
+	return m.Reply
+}
+
+// generate 为 file 中的服务接口生成代码，没有服务接口或 file 是生成的文件时返回 nil
+func generate(fset *token.FileSet, file *ast.File, filename string) ([]byte, error) {
+	if ast.IsGenerated(file) {
+		return nil, nil
+	}
+
+	var services []service
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			doc := ts.Doc
+			if doc == nil && len(gd.Specs) == 1 {
+				doc = gd.Doc
+			}
+			serviceName, ok := findDirective(doc)
+			if !ok {
+				continue
+			}
+
+			svc, err := parseService(fset, ts, serviceName)
+			if err != nil {
+				return nil, err
+			}
+			services = append(services, svc)
+		}
+	}
+	if len(services) == 0 {
+		return nil, nil
+	}
+
+	data := struct {
+		Source   string
+		Package  string
+		Imports  []string
+		Services []service
+	}{
+		Source:   filename,
+		Package:  file.Name.Name,
+		Imports:  imports(file, services),
+		Services: services,
+	}
+
+	var buf bytes.Buffer
+	if err := fileTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s: 生成的代码格式化失败: %w", filename, err)
+	}
+	return out, nil
+}
+
+// findDirective 查找注释中的 //trpc:service 指令，返回指定的服务名称
+func findDirective(doc *ast.CommentGroup) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	for _, c := range doc.List {
+		rest, ok := strings.CutPrefix(c.Text, directive)
+		if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+			continue
+		}
+		return strings.TrimSpace(rest), true
+	}
+	return "", false
+}
+
+func parseService(fset *token.FileSet, ts *ast.TypeSpec, serviceName string) (service, error) {
+	pos := fset.Position(ts.Pos())
+	iface, ok := ts.Type.(*ast.InterfaceType)
+	if !ok {
+		return service{}, fmt.Errorf("%s: %s 不是接口", pos, ts.Name.Name)
+	}
+
+	name := ts.Name.Name
+	// IHelloService -> HelloService -> Hello
+	if len(name) > 1 && name[0] == 'I' && unicode.IsUpper(rune(name[1])) {
+		name = name[1:]
+	}
+	name = naming.BaseName(name)
+	if serviceName == "" {
+		serviceName = naming.ServiceName(name)
+	}
+	if strings.Contains(serviceName, ".") {
+		return service{}, fmt.Errorf("%s: 服务名称 %s 不能包含 \".\"", pos, serviceName)
+	}
+
+	svc := service{
+		Interface:   ts.Name.Name,
+		Name:        name,
+		ServiceName: serviceName,
+	}
+	for _, field := range iface.Methods.List {
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) == 0 {
+			return service{}, fmt.Errorf("%s: %s 不支持嵌入接口", fset.Position(field.Pos()), ts.Name.Name)
+		}
+
+		m, err := parseMethod(field.Names[0].Name, ft)
+		if err != nil {
+			return service{}, fmt.Errorf("%s: %s.%s %w", fset.Position(field.Pos()), ts.Name.Name, field.Names[0].Name, err)
+		}
+		if m.IsStream() {
+			m.StreamIndex = len(svc.Streams())
+		}
+		svc.Methods = append(svc.Methods, m)
+	}
+	if len(svc.Methods) == 0 {
+		return service{}, fmt.Errorf("%s: %s 没有方法", pos, ts.Name.Name)
+	}
+	return svc, nil
+}
+
+// Unary 返回一元方法
+func (s service) Unary() []method {
+	var list []method
+	for _, m := range s.Methods {
+		if !m.IsStream() {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+// Streams 返回流式方法
+func (s service) Streams() []method {
+	var list []method
+	for _, m := range s.Methods {
+		if m.IsStream() {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+// parseMethod 检查方法签名是否为 func(ctx context.Context, req *ReqType) (*RespType, error)，
+// 或者流式方法：
+//
+//	func(ctx context.Context, req *ReqType, stream api.ServerStreamingServer[RespType]) error
+//	func(stream api.ClientStreamingServer[ReqType, RespType]) error
+//	func(stream api.BidiStreamingServer[ReqType, RespType]) error
+func parseMethod(name string, ft *ast.FuncType) (method, error) {
+	params := fieldTypes(ft.Params)
+	switch len(params) {
+	case 1:
+		// 只有一个泛型参数时按流式方法检查，其他情况报告参数数量不正确
+		switch params[0].(type) {
+		case *ast.IndexExpr, *ast.IndexListExpr:
+			return parseClientStream(name, params[0], fieldTypes(ft.Results))
+		}
+	case 3:
+		return parseServerStream(name, params, fieldTypes(ft.Results))
+	}
+	if len(params) != 2 {
+		return method{}, fmt.Errorf("参数数量不正确，应为 (context.Context, *ReqType)")
+	}
+	if types.ExprString(params[0]) != "context.Context" {
+		return method{}, fmt.Errorf("第一个参数应为 context.Context，实际为 %s", types.ExprString(params[0]))
+	}
+	req, ok := params[1].(*ast.StarExpr)
+	if !ok {
+		return method{}, fmt.Errorf("第二个参数应为指针，实际为 %s", types.ExprString(params[1]))
+	}
+
+	results := fieldTypes(ft.Results)
+	if len(results) != 2 {
+		return method{}, fmt.Errorf("返回值数量不正确，应为 (*RespType, error)")
+	}
+	reply, ok := results[0].(*ast.StarExpr)
+	if !ok {
+		return method{}, fmt.Errorf("第一个返回值应为指针，实际为 %s", types.ExprString(results[0]))
+	}
+	if types.ExprString(results[1]) != "error" {
+		return method{}, fmt.Errorf("第二个返回值应为 error，实际为 %s", types.ExprString(results[1]))
+	}
+
+	m := method{
+		Name:  name,
+		Req:   types.ExprString(req.X),
+		Reply: types.ExprString(reply.X),
+	}
+	return m, nil
+}
+
+func parseServerStream(name string, params, results []ast.Expr) (method, error) {
+	if types.ExprString(params[0]) != "context.Context" {
+		return method{}, fmt.Errorf("第一个参数应为 context.Context，实际为 %s", types.ExprString(params[0]))
+	}
+	req, ok := params[1].(*ast.StarExpr)
+	if !ok {
+		return method{}, fmt.Errorf("第二个参数应为指针，实际为 %s", types.ExprString(params[1]))
+	}
+	stream, ok := params[2].(*ast.IndexExpr)
+	if !ok || types.ExprString(stream.X) != serverStreamType {
+		return method{}, fmt.Errorf("第三个参数应为 %s[RespType]，实际为 %s", serverStreamType, types.ExprString(params[2]))
+	}
+	if len(results) != 1 || types.ExprString(results[0]) != "error" {
+		return method{}, fmt.Errorf("流式方法的返回值应为 error")
+	}
+
+	m := method{
+		Name:          name,
+		Req:           types.ExprString(req.X),
+		Reply:         types.ExprString(stream.Index),
+		ServerStreams: true,
+	}
+	return m, nil
+}
+
+// parseClientStream 解析只有一个流参数的客户端流式方法和双向流式方法
+func parseClientStream(name string, param ast.Expr, results []ast.Expr) (method, error) {
+	stream, ok := param.(*ast.IndexListExpr)
+	if !ok || len(stream.Indices) != 2 ||
+		(types.ExprString(stream.X) != clientStreamType && types.ExprString(stream.X) != bidiStreamType) {
+		return method{}, fmt.Errorf("参数应为 %s[ReqType, RespType] 或 %s[ReqType, RespType]，实际为 %s",
+			clientStreamType, bidiStreamType, types.ExprString(param))
+	}
+	if len(results) != 1 || types.ExprString(results[0]) != "error" {
+		return method{}, fmt.Errorf("流式方法的返回值应为 error")
+	}
+
+	m := method{
+		Name:          name,
+		Req:           types.ExprString(stream.Indices[0]),
+		Reply:         types.ExprString(stream.Indices[1]),
+		ServerStreams: types.ExprString(stream.X) == bidiStreamType,
+		ClientStreams: true,
+	}
+	return m, nil
+}
+
+// fieldTypes 展开参数列表，(a, b int) 展开为两个 int
+func fieldTypes(fl *ast.FieldList) []ast.Expr {
+	if fl == nil {
+		return nil
+	}
+	var exprs []ast.Expr
+	for _, f := range fl.List {
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			exprs = append(exprs, f.Type)
+		}
+	}
+	return exprs
+}
+
+// imports 返回生成的代码需要导入的包：固定依赖的包，以及请求、响应类型引用的源文件中导入的包
+func imports(file *ast.File, services []service) []string {
+	set := map[string]bool{
+		strconv.Quote("context"):        true,
+		strconv.Quote("v2/api"):         true,
+		strconv.Quote("v2/trpc/codes"):  true,
+		strconv.Quote("v2/trpc/status"): true,
+	}
+
+	used := make(map[string]bool)
+	for _, svc := range services {
+		for _, m := range svc.Methods {
+			for _, typ := range []string{m.Req, m.Reply} {
+				if pkg, _, ok := strings.Cut(typ, "."); ok {
+					used[pkg] = true
+				}
+			}
+		}
+	}
+
+	for _, spec := range file.Imports {
+		p, _ := strconv.Unquote(spec.Path.Value)
+		name := path.Base(p)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if !used[name] {
+			continue
+		}
+		if spec.Name != nil {
+			set[spec.Name.Name+" "+spec.Path.Value] = true
+		} else {
+			set[spec.Path.Value] = true
+		}
+	}
+
+	list := make([]string, 0, len(set))
+	for imp := range set {
+		list = append(list, imp)
+	}
+	sort.Strings(list)
+	return list
+}
+
+var fileTemplate = template.Must(template.New("trpc").Parse(`// Code generated by trpcgen. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.Package}}
+
+import (
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+{{range $svc := .Services}}
+const (
+	{{.Name}}ServiceName = {{printf "%q" .ServiceName}}
+{{- range .Methods}}
+	{{$svc.Name}}_{{.Name}}_FullMethodName = {{$svc.Name}}ServiceName + ".{{.Name}}"
+{{- end}}
+)
+{{range .Streams}}
+// {{$svc.Name}}_{{.Name}}Client is the client-side stream of {{.Name}}.
+type {{$svc.Name}}_{{.Name}}Client = api.{{.StreamKind}}Client[{{.TypeArgs}}]
+
+// {{$svc.Name}}_{{.Name}}Server is the server-side stream of {{.Name}}.
+type {{$svc.Name}}_{{.Name}}Server = api.{{.StreamKind}}Server[{{.TypeArgs}}]
+{{end}}
+// {{.Name}}Client is the client API for {{.Name}} service.
+type {{.Name}}Client struct {
+{{- range .Methods}}
+{{- if .ClientStreams}}
+	{{.Name}} func(ctx context.Context) ({{$svc.Name}}_{{.Name}}Client, error)
+{{- else if .ServerStreams}}
+	{{.Name}} func(ctx context.Context, in *{{.Req}}) ({{$svc.Name}}_{{.Name}}Client, error)
+{{- else}}
+	{{.Name}} func(ctx context.Context, in *{{.Req}}) (*{{.Reply}}, error)
+{{- end}}
+{{- end}}
+}
+
+func New{{.Name}}Client(c api.ClientConnInterface) *{{.Name}}Client {
+	return &{{.Name}}Client{
+{{- range .Methods}}
+{{- if .ClientStreams}}
+		{{.Name}}: func(ctx context.Context) ({{$svc.Name}}_{{.Name}}Client, error) {
+			stream, err := c.NewStream(ctx, &{{$svc.Name}}ServiceDesc.Streams[{{.StreamIndex}}], {{$svc.Name}}_{{.Name}}_FullMethodName)
+			if err != nil {
+				return nil, err
+			}
+			return &api.GenericClientStream[{{.Req}}, {{.Reply}}]{ClientStream: stream}, nil
+		},
+{{- else if .ServerStreams}}
+		{{.Name}}: func(ctx context.Context, in *{{.Req}}) ({{$svc.Name}}_{{.Name}}Client, error) {
+			stream, err := c.NewStream(ctx, &{{$svc.Name}}ServiceDesc.Streams[{{.StreamIndex}}], {{$svc.Name}}_{{.Name}}_FullMethodName)
+			if err != nil {
+				return nil, err
+			}
+			x := &api.GenericClientStream[{{.Req}}, {{.Reply}}]{ClientStream: stream}
+			if err := x.ClientStream.SendMsg(in); err != nil {
+				return nil, err
+			}
+			if err := x.ClientStream.CloseSend(); err != nil {
+				return nil, err
+			}
+			return x, nil
+		},
+{{- else}}
+		{{.Name}}: func(ctx context.Context, in *{{.Req}}) (*{{.Reply}}, error) {
+			out := new({{.Reply}})
+			if err := c.Invoke(ctx, {{$svc.Name}}_{{.Name}}_FullMethodName, in, out); err != nil {
+				return nil, err
+			}
+			return out, nil
+		},
+{{- end}}
+{{- end}}
+	}
+}
+
+func (s *{{.Name}}Client) Name() string {
+	return {{.Name}}ServiceName
+}
+
+// Unimplemented{{.Name}}Service can be embedded to have forward compatible implementations.
+type Unimplemented{{.Name}}Service struct{}
+{{range .Methods}}
+{{- if .ClientStreams}}
+func (Unimplemented{{$svc.Name}}Service) {{.Name}}(api.{{.StreamKind}}Server[{{.TypeArgs}}]) error {
+	return status.Error(codes.Unimplemented, "method {{.Name}} 未实现")
+}
+{{- else if .ServerStreams}}
+func (Unimplemented{{$svc.Name}}Service) {{.Name}}(context.Context, *{{.Req}}, api.ServerStreamingServer[{{.Reply}}]) error {
+	return status.Error(codes.Unimplemented, "method {{.Name}} 未实现")
+}
+{{- else}}
+func (Unimplemented{{$svc.Name}}Service) {{.Name}}(context.Context, *{{.Req}}) (*{{.Reply}}, error) {
+	return nil, status.Error(codes.Unimplemented, "method {{.Name}} 未实现")
+}
+{{- end}}
+{{end}}
+{{- range .Streams}}
+{{- if .ClientStreams}}
+func _{{$svc.Name}}_{{.Name}}_Handler(srv any, stream api.ServerStream) error {
+	return srv.({{$svc.Interface}}).{{.Name}}(&api.GenericServerStream[{{.Req}}, {{.Reply}}]{ServerStream: stream})
+}
+{{- else}}
+func _{{$svc.Name}}_{{.Name}}_Handler(srv any, stream api.ServerStream) error {
+	in := new({{.Req}})
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+	return srv.({{$svc.Interface}}).{{.Name}}(stream.Context(), in, &api.GenericServerStream[{{.Req}}, {{.Reply}}]{ServerStream: stream})
+}
+{{- end}}
+{{end}}
+// {{.Name}}ServiceDesc is the api.ServiceDesc for {{.Name}} service.
+var {{.Name}}ServiceDesc = api.ServiceDesc{
+	ServiceName: {{.Name}}ServiceName,
+	HandlerType: (*{{.Interface}})(nil),
+	Methods: []api.MethodDesc{
+{{- range .Unary}}
+		{MethodName: "{{.Name}}"},
+{{- end}}
+	},
+{{- if .Streams}}
+	Streams: []api.StreamDesc{
+{{- range .Streams}}
+		{
+			StreamName: "{{.Name}}",
+			Handler:    _{{$svc.Name}}_{{.Name}}_Handler,
+{{- if .ServerStreams}}
+			ServerStreams: true,
+{{- end}}
+{{- if .ClientStreams}}
+			ClientStreams: true,
+{{- end}}
+		},
+{{- end}}
+	},
+{{- end}}
+	Metadata: {{printf "%q" $.Source}},
+}
+
+func Register{{.Name}}Server(s api.ServiceRegistrar, srv {{.Interface}}) error {
+	return s.RegisterService(&{{.Name}}ServiceDesc, srv)
+}
+{{end}}`))
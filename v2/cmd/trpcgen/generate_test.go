@@ -0,0 +1,206 @@
+//go:build unit
+
+package main
+
+import (
+	"flag"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "更新 testdata 中的期望输出")
+
+func generateFromFile(t *testing.T, path string) []byte {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	require.NoError(t, err)
+
+	out, err := generate(fset, file, filepath.Base(path))
+	require.NoError(t, err)
+	return out
+}
+
+func TestGenerate(t *testing.T) {
+	out := generateFromFile(t, filepath.Join("testdata", "greeter.go"))
+
+	golden := filepath.Join("testdata", "greeter_trpc.go.golden")
+	if *update {
+		require.NoError(t, os.WriteFile(golden, out, 0o644))
+	}
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(out))
+}
+
+// TestGenerate_PbUpToDate pb 中生成的代码需要与服务接口保持一致，修改接口后需要运行 go generate ./pb
+func TestGenerate_PbUpToDate(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "..", "pb", "*_trpc.go"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		source := path[:len(path)-len("_trpc.go")] + ".go"
+		want, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(generateFromFile(t, source)), path)
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{
+			name: "没有ctx参数-失败",
+			src: `package p
+//trpc:service
+type IBad interface {
+	Do(req *Req) (*Resp, error)
+}`,
+			wantErr: "IBad.Do 参数数量不正确",
+		},
+		{
+			name: "参数不是指针-失败",
+			src: `package p
+//trpc:service
+type IBad interface {
+	Do(ctx context.Context, req Req) (*Resp, error)
+}`,
+			wantErr: "第二个参数应为指针",
+		},
+		{
+			name: "第二个返回值不是error-失败",
+			src: `package p
+//trpc:service
+type IBad interface {
+	Do(ctx context.Context, req *Req) (*Resp, string)
+}`,
+			wantErr: "第二个返回值应为 error",
+		},
+		{
+			name: "流式方法第三个参数不是流-失败",
+			src: `package p
+//trpc:service
+type IBad interface {
+	List(ctx context.Context, req *Req, stream chan *Resp) error
+}`,
+			wantErr: "第三个参数应为 api.ServerStreamingServer[RespType]",
+		},
+		{
+			name: "流式方法有多个返回值-失败",
+			src: `package p
+//trpc:service
+type IBad interface {
+	List(ctx context.Context, req *Req, stream api.ServerStreamingServer[Resp]) (*Resp, error)
+}`,
+			wantErr: "流式方法的返回值应为 error",
+		},
+		{
+			name: "客户端流缺少类型参数-失败",
+			src: `package p
+//trpc:service
+type IBad interface {
+	Upload(stream api.ClientStreamingServer[Req]) error
+}`,
+			wantErr: "参数应为 api.ClientStreamingServer[ReqType, RespType]",
+		},
+		{
+			name: "双向流有返回值-失败",
+			src: `package p
+//trpc:service
+type IBad interface {
+	Chat(stream api.BidiStreamingServer[Req, Resp]) (*Resp, error)
+}`,
+			wantErr: "流式方法的返回值应为 error",
+		},
+		{
+			name: "嵌入接口-失败",
+			src: `package p
+//trpc:service
+type IBad interface {
+	IOther
+}`,
+			wantErr: "不支持嵌入接口",
+		},
+		{
+			name: "不是接口-失败",
+			src: `package p
+//trpc:service
+type Bad struct{}`,
+			wantErr: "Bad 不是接口",
+		},
+		{
+			name: "服务名称包含点-失败",
+			src: `package p
+//trpc:service pkg.bad
+type IBad interface {
+	Do(ctx context.Context, req *Req) (*Resp, error)
+}`,
+			wantErr: "不能包含",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "bad.go", tt.src, parser.ParseComments)
+			require.NoError(t, err)
+
+			_, err = generate(fset, file, "bad.go")
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestGenerate_Skip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{
+			name: "没有指令",
+			src: `package p
+type IService interface {
+	Do(ctx context.Context, req *Req) (*Resp, error)
+}`,
+		},
+		{
+			name: "生成的文件",
+			src: `// Code generated by trpcgen. DO NOT EDIT.
+
+package p
+//trpc:service
+type IService interface {
+	Do(ctx context.Context, req *Req) (*Resp, error)
+}`,
+		},
+		{
+			name: "相似的指令",
+			src: `package p
+//trpc:services
+type IService interface {
+	Do(ctx context.Context, req *Req) (*Resp, error)
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "p.go", tt.src, parser.ParseComments)
+			require.NoError(t, err)
+
+			out, err := generate(fset, file, "p.go")
+			require.NoError(t, err)
+			assert.Nil(t, out)
+		})
+	}
+}
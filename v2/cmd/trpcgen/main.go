@@ -0,0 +1,75 @@
+// trpcgen 根据 Go 接口生成 trpc 的客户端和服务端代码，适用于没有 .proto、直接用 Go 接口定义服务的场景。
+// 在接口的注释中添加 //trpc:service 指令，可以指定调用时使用的服务名称，默认由接口名称转换而来：
+//
+//	//go:generate go run v2/cmd/trpcgen
+//
+//	//trpc:service
+//	type IHelloService interface {
+//		Hello(ctx context.Context, apply *ApplyHello) (*ReplyHello, error)
+//	}
+//
+// 运行 go generate 后，为每个包含服务接口的 xxx.go 生成 xxx_trpc.go，包含服务名称和方法名称常量、
+// XClient、NewXClient、UnimplementedXService、XServiceDesc 和 RegisterXServer。
+// 方法签名必须为 func(ctx context.Context, req *ReqType) (*RespType, error)，流式方法的签名与 gRPC 生成的一致：
+//
+//	ListUsers(ctx context.Context, req *ReqType, stream api.ServerStreamingServer[RespType]) error
+//	Upload(stream api.ClientStreamingServer[ReqType, RespType]) error
+//	Chat(stream api.BidiStreamingServer[ReqType, RespType]) error
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "服务接口所在的包目录")
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("trpcgen: ")
+	if err := run(*dir); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run 处理 dir 中所有的 Go 源文件，跳过测试文件和生成的文件
+func run(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+
+		out, err := generate(fset, file, filepath.Base(path))
+		if err != nil {
+			return err
+		}
+		if out == nil {
+			continue
+		}
+
+		target := strings.TrimSuffix(path, ".go") + "_trpc.go"
+		if err := os.WriteFile(target, out, 0o644); err != nil {
+			return err
+		}
+		fmt.Println(target)
+	}
+	return nil
+}
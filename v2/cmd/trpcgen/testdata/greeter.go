@@ -0,0 +1,46 @@
+package greeter
+
+import (
+	"context"
+	"time"
+	"v2/api"
+
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type ApplyGreet struct {
+	Name string
+	At   time.Time
+}
+
+type ReplyGreet struct {
+	Msg string
+}
+
+// IGreeterService 默认的服务名称为 greeter_service
+//
+//trpc:service
+type IGreeterService interface {
+	Greet(ctx context.Context, apply *ApplyGreet) (*ReplyGreet, error)
+	Echo(ctx context.Context, apply *wrappers.StringValue) (*wrappers.StringValue, error)
+	// Watch 持续推送问候语
+	Watch(ctx context.Context, apply *ApplyGreet, stream api.ServerStreamingServer[ReplyGreet]) error
+	// Collect 收集多个名字后一次性问候
+	Collect(stream api.ClientStreamingServer[ApplyGreet, ReplyGreet]) error
+	// Chat 逐个问候
+	Chat(stream api.BidiStreamingServer[ApplyGreet, ReplyGreet]) error
+}
+
+type (
+	// IAccount 指定服务名称
+	//
+	//trpc:service account
+	IAccount interface {
+		Login(context.Context, *ApplyGreet) (*ReplyGreet, error)
+	}
+
+	// INotService 没有指令，不生成
+	INotService interface {
+		Greet(ctx context.Context, apply *ApplyGreet) (*ReplyGreet, error)
+	}
+)
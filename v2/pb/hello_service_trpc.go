@@ -0,0 +1,58 @@
+// Code generated by trpcgen. DO NOT EDIT.
+// source: hello_service.go
+
+package pb
+
+import (
+	"context"
+	"v2/api"
+	"v2/trpc/codes"
+	"v2/trpc/status"
+)
+
+const (
+	HelloServiceName           = "hello_service"
+	Hello_Hello_FullMethodName = HelloServiceName + ".Hello"
+)
+
+// HelloClient is the client API for Hello service.
+type HelloClient struct {
+	Hello func(ctx context.Context, in *ApplyHello) (*ReplyHello, error)
+}
+
+func NewHelloClient(c api.ClientConnInterface) *HelloClient {
+	return &HelloClient{
+		Hello: func(ctx context.Context, in *ApplyHello) (*ReplyHello, error) {
+			out := new(ReplyHello)
+			if err := c.Invoke(ctx, Hello_Hello_FullMethodName, in, out); err != nil {
+				return nil, err
+			}
+			return out, nil
+		},
+	}
+}
+
+func (s *HelloClient) Name() string {
+	return HelloServiceName
+}
+
+// UnimplementedHelloService can be embedded to have forward compatible implementations.
+type UnimplementedHelloService struct{}
+
+func (UnimplementedHelloService) Hello(context.Context, *ApplyHello) (*ReplyHello, error) {
+	return nil, status.Error(codes.Unimplemented, "method Hello 未实现")
+}
+
+// HelloServiceDesc is the api.ServiceDesc for Hello service.
+var HelloServiceDesc = api.ServiceDesc{
+	ServiceName: HelloServiceName,
+	HandlerType: (*IHelloService)(nil),
+	Methods: []api.MethodDesc{
+		{MethodName: "Hello"},
+	},
+	Metadata: "hello_service.go",
+}
+
+func RegisterHelloServer(s api.ServiceRegistrar, srv IHelloService) error {
+	return s.RegisterService(&HelloServiceDesc, srv)
+}
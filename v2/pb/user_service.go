@@ -2,7 +2,6 @@ package pb
 
 import (
 	"context"
-	"fmt"
 	"v2/api"
 )
 
@@ -21,32 +20,18 @@ type ReplyUser struct {
 	User *User
 }
 
-type UserClient struct {
-	User func(ctx context.Context, apply *ApplyUser) (*ReplyUser, error)
+type ApplyList struct {
+	Sex int64 // 按性别过滤，0 表示不过滤
 }
 
-func NewUserClient(c api.ClientConnInterface) *UserClient {
-	userFunc := func(ctx context.Context, apply *ApplyUser) (*ReplyUser, error) {
-		var reply ReplyUser
-		if err := c.Invoke(ctx, fmt.Sprintf("%s.%s", (*UserClient).Name(nil), "User"), apply, &reply); err != nil {
-			return nil, err
-		}
-		return &reply, nil
-	}
-	userClient := &UserClient{
-		User: userFunc,
-	}
-	return userClient
-}
-
-func (s *UserClient) Name() string {
-	return "user_service"
-}
-
-func RegisterUserServer(server api.ServiceRegistrar, service IUserService) {
-	server.RegisterService("user_service", service)
+type ReplyAddUsers struct {
+	Count int64 // 添加的用户数量
 }
 
+//trpc:service user_service
 type IUserService interface {
 	User(ctx context.Context, apply *ApplyUser) (*ReplyUser, error)
+	ListUsers(ctx context.Context, apply *ApplyList, stream api.ServerStreamingServer[User]) error
+	AddUsers(stream api.ClientStreamingServer[User, ReplyAddUsers]) error
+	GetUsers(stream api.BidiStreamingServer[ApplyUser, ReplyUser]) error
 }
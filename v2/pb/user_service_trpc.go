@@ -0,0 +1,156 @@
+// Code generated by trpcgen. DO NOT EDIT.
+// source: user_service.go
+
+package pb
+
+import (
+	"context"
+	"v2/api"
+	"v2/trpc/codes"
+	"v2/trpc/status"
+)
+
+const (
+	UserServiceName               = "user_service"
+	User_User_FullMethodName      = UserServiceName + ".User"
+	User_ListUsers_FullMethodName = UserServiceName + ".ListUsers"
+	User_AddUsers_FullMethodName  = UserServiceName + ".AddUsers"
+	User_GetUsers_FullMethodName  = UserServiceName + ".GetUsers"
+)
+
+// User_ListUsersClient is the client-side stream of ListUsers.
+type User_ListUsersClient = api.ServerStreamingClient[User]
+
+// User_ListUsersServer is the server-side stream of ListUsers.
+type User_ListUsersServer = api.ServerStreamingServer[User]
+
+// User_AddUsersClient is the client-side stream of AddUsers.
+type User_AddUsersClient = api.ClientStreamingClient[User, ReplyAddUsers]
+
+// User_AddUsersServer is the server-side stream of AddUsers.
+type User_AddUsersServer = api.ClientStreamingServer[User, ReplyAddUsers]
+
+// User_GetUsersClient is the client-side stream of GetUsers.
+type User_GetUsersClient = api.BidiStreamingClient[ApplyUser, ReplyUser]
+
+// User_GetUsersServer is the server-side stream of GetUsers.
+type User_GetUsersServer = api.BidiStreamingServer[ApplyUser, ReplyUser]
+
+// UserClient is the client API for User service.
+type UserClient struct {
+	User      func(ctx context.Context, in *ApplyUser) (*ReplyUser, error)
+	ListUsers func(ctx context.Context, in *ApplyList) (User_ListUsersClient, error)
+	AddUsers  func(ctx context.Context) (User_AddUsersClient, error)
+	GetUsers  func(ctx context.Context) (User_GetUsersClient, error)
+}
+
+func NewUserClient(c api.ClientConnInterface) *UserClient {
+	return &UserClient{
+		User: func(ctx context.Context, in *ApplyUser) (*ReplyUser, error) {
+			out := new(ReplyUser)
+			if err := c.Invoke(ctx, User_User_FullMethodName, in, out); err != nil {
+				return nil, err
+			}
+			return out, nil
+		},
+		ListUsers: func(ctx context.Context, in *ApplyList) (User_ListUsersClient, error) {
+			stream, err := c.NewStream(ctx, &UserServiceDesc.Streams[0], User_ListUsers_FullMethodName)
+			if err != nil {
+				return nil, err
+			}
+			x := &api.GenericClientStream[ApplyList, User]{ClientStream: stream}
+			if err := x.ClientStream.SendMsg(in); err != nil {
+				return nil, err
+			}
+			if err := x.ClientStream.CloseSend(); err != nil {
+				return nil, err
+			}
+			return x, nil
+		},
+		AddUsers: func(ctx context.Context) (User_AddUsersClient, error) {
+			stream, err := c.NewStream(ctx, &UserServiceDesc.Streams[1], User_AddUsers_FullMethodName)
+			if err != nil {
+				return nil, err
+			}
+			return &api.GenericClientStream[User, ReplyAddUsers]{ClientStream: stream}, nil
+		},
+		GetUsers: func(ctx context.Context) (User_GetUsersClient, error) {
+			stream, err := c.NewStream(ctx, &UserServiceDesc.Streams[2], User_GetUsers_FullMethodName)
+			if err != nil {
+				return nil, err
+			}
+			return &api.GenericClientStream[ApplyUser, ReplyUser]{ClientStream: stream}, nil
+		},
+	}
+}
+
+func (s *UserClient) Name() string {
+	return UserServiceName
+}
+
+// UnimplementedUserService can be embedded to have forward compatible implementations.
+type UnimplementedUserService struct{}
+
+func (UnimplementedUserService) User(context.Context, *ApplyUser) (*ReplyUser, error) {
+	return nil, status.Error(codes.Unimplemented, "method User 未实现")
+}
+
+func (UnimplementedUserService) ListUsers(context.Context, *ApplyList, api.ServerStreamingServer[User]) error {
+	return status.Error(codes.Unimplemented, "method ListUsers 未实现")
+}
+
+func (UnimplementedUserService) AddUsers(api.ClientStreamingServer[User, ReplyAddUsers]) error {
+	return status.Error(codes.Unimplemented, "method AddUsers 未实现")
+}
+
+func (UnimplementedUserService) GetUsers(api.BidiStreamingServer[ApplyUser, ReplyUser]) error {
+	return status.Error(codes.Unimplemented, "method GetUsers 未实现")
+}
+
+func _User_ListUsers_Handler(srv any, stream api.ServerStream) error {
+	in := new(ApplyList)
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+	return srv.(IUserService).ListUsers(stream.Context(), in, &api.GenericServerStream[ApplyList, User]{ServerStream: stream})
+}
+
+func _User_AddUsers_Handler(srv any, stream api.ServerStream) error {
+	return srv.(IUserService).AddUsers(&api.GenericServerStream[User, ReplyAddUsers]{ServerStream: stream})
+}
+
+func _User_GetUsers_Handler(srv any, stream api.ServerStream) error {
+	return srv.(IUserService).GetUsers(&api.GenericServerStream[ApplyUser, ReplyUser]{ServerStream: stream})
+}
+
+// UserServiceDesc is the api.ServiceDesc for User service.
+var UserServiceDesc = api.ServiceDesc{
+	ServiceName: UserServiceName,
+	HandlerType: (*IUserService)(nil),
+	Methods: []api.MethodDesc{
+		{MethodName: "User"},
+	},
+	Streams: []api.StreamDesc{
+		{
+			StreamName:    "ListUsers",
+			Handler:       _User_ListUsers_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "AddUsers",
+			Handler:       _User_AddUsers_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetUsers",
+			Handler:       _User_GetUsers_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "user_service.go",
+}
+
+func RegisterUserServer(s api.ServiceRegistrar, srv IUserService) error {
+	return s.RegisterService(&UserServiceDesc, srv)
+}
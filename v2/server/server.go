@@ -1,12 +1,26 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"io"
 	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
 	"v2/pb"
 	"v2/trpc"
+	"v2/trpc/codes"
+	"v2/trpc/status"
 )
 
+// usersMu 保护 users，AddUsers 会并发修改
+var usersMu sync.RWMutex
+
 var users = map[int64]*pb.User{
 	1: {
 		Uid:  1,
@@ -22,10 +36,10 @@ var users = map[int64]*pb.User{
 	},
 }
 
-// server 实现 Hello 服务接口
+// server 实现 Hello 服务接口，嵌入 Unimplemented 类型后，服务接口新增的方法未实现时返回 codes.Unimplemented
 type server struct {
-	pb.IHelloService
-	pb.IUserService
+	pb.UnimplementedHelloService
+	pb.UnimplementedUserService
 }
 
 // Hello 实现 Hello 方法
@@ -37,7 +51,81 @@ func (s *server) Hello(ctx context.Context, in *pb.ApplyHello) (*pb.ReplyHello,
 // User 实现 User 方法
 func (s *server) User(ctx context.Context, in *pb.ApplyUser) (*pb.ReplyUser, error) {
 	log.Printf("收到请求: %v", in.Uid)
-	return &pb.ReplyUser{User: users[in.Uid]}, nil
+	return getUser(in.Uid)
+}
+
+func getUser(uid int64) (*pb.ReplyUser, error) {
+	usersMu.RLock()
+	defer usersMu.RUnlock()
+
+	user, ok := users[uid]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "uid:%d 不存在", uid)
+	}
+	return &pb.ReplyUser{User: user}, nil
+}
+
+// ListUsers 实现 ListUsers 方法，按 uid 顺序逐个发送用户
+func (s *server) ListUsers(ctx context.Context, in *pb.ApplyList, stream pb.User_ListUsersServer) error {
+	log.Printf("收到请求: %+v", in)
+	usersMu.RLock()
+	list := make([]*pb.User, 0, len(users))
+	for _, user := range users {
+		if in.Sex == 0 || user.Sex == in.Sex {
+			list = append(list, user)
+		}
+	}
+	usersMu.RUnlock()
+	slices.SortFunc(list, func(a, b *pb.User) int { return cmp.Compare(a.Uid, b.Uid) })
+
+	for _, user := range list {
+		if err := stream.Send(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddUsers 实现 AddUsers 方法，接收客户端发送的所有用户后返回添加的数量
+func (s *server) AddUsers(stream pb.User_AddUsersServer) error {
+	var count int64
+	for {
+		user, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&pb.ReplyAddUsers{Count: count})
+		}
+		if err != nil {
+			return err
+		}
+
+		log.Printf("收到请求: %+v", user)
+		usersMu.Lock()
+		users[user.Uid] = user
+		usersMu.Unlock()
+		count++
+	}
+}
+
+// GetUsers 实现 GetUsers 方法，每收到一个 uid 返回对应的用户
+func (s *server) GetUsers(stream pb.User_GetUsersServer) error {
+	for {
+		in, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		log.Printf("收到请求: %v", in.Uid)
+		reply, err := getUser(in.Uid)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(reply); err != nil {
+			return err
+		}
+	}
 }
 
 func main() {
@@ -48,12 +136,33 @@ func main() {
 	}
 
 	// 注册 Hello 服务
-	pb.RegisterHelloServer(s, &server{})
+	if err := pb.RegisterHelloServer(s, &server{}); err != nil {
+		log.Fatalf("注册 Hello 服务失败: %v", err)
+	}
 	// 注册 User 服务
-	pb.RegisterUserServer(s, &server{})
+	if err := pb.RegisterUserServer(s, &server{}); err != nil {
+		log.Fatalf("注册 User 服务失败: %v", err)
+	}
+
+	// 收到 SIGINT/SIGTERM 后优雅关闭，最多等待 5 秒
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+
+		log.Println("服务器正在关闭")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.GracefulStop(ctx); err != nil {
+			log.Printf("服务器优雅关闭失败: %v", err)
+		}
+	}()
 
 	log.Println("gRPC 服务器启动在 :50051")
-	if err := s.Start(); err != nil {
+	if err := s.Start(); !errors.Is(err, trpc.ErrServerClosed) {
 		log.Fatalf("服务器启动失败: %v", err)
 	}
+	<-stopped
 }
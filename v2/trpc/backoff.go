@@ -0,0 +1,39 @@
+package trpc
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// BackoffConfig 连接断开后重新建立连接的退避策略，第 n 次重试前等待
+// min(BaseDelay * Multiplier^n, MaxDelay)，并随机增减 Jitter 比例的时间，避免大量客户端同时重连
+type BackoffConfig struct {
+	BaseDelay  time.Duration // 第一次重试前的等待时间
+	Multiplier float64       // 每次失败后等待时间的增长倍数
+	Jitter     float64       // 随机抖动的比例，0.2 表示在 ±20% 之间浮动
+	MaxDelay   time.Duration // 等待时间的上限
+}
+
+// DefaultBackoffConfig 默认的退避策略，与 gRPC 相同
+var DefaultBackoffConfig = BackoffConfig{
+	BaseDelay:  time.Second,
+	Multiplier: 1.6,
+	Jitter:     0.2,
+	MaxDelay:   120 * time.Second,
+}
+
+// backoff 返回第 retries 次重试前的等待时间，retries 从 0 开始
+func (bc BackoffConfig) backoff(retries int) time.Duration {
+	delay := float64(bc.BaseDelay)
+	maxDelay := float64(bc.MaxDelay)
+	for ; retries > 0 && delay < maxDelay; retries-- {
+		delay *= bc.Multiplier
+	}
+	delay = min(delay, maxDelay)
+
+	delay *= 1 + bc.Jitter*(rand.Float64()*2-1)
+	if delay < 0 {
+		return 0
+	}
+	return time.Duration(delay)
+}
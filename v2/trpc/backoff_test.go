@@ -0,0 +1,42 @@
+//go:build unit
+
+package trpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffConfig_Backoff(t *testing.T) {
+	bc := BackoffConfig{BaseDelay: 100 * time.Millisecond, Multiplier: 2, Jitter: 0.2, MaxDelay: time.Second}
+
+	tests := []struct {
+		name    string
+		retries int
+		want    time.Duration
+	}{
+		{name: "第一次重试", retries: 0, want: 100 * time.Millisecond},
+		{name: "按倍数增长", retries: 1, want: 200 * time.Millisecond},
+		{name: "按倍数增长-多次", retries: 3, want: 800 * time.Millisecond},
+		{name: "不超过上限", retries: 4, want: time.Second},
+		{name: "重试次数很大时不超过上限", retries: 1000, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				got := bc.backoff(tt.retries)
+				assert.GreaterOrEqual(t, got, time.Duration(float64(tt.want)*0.8))
+				assert.LessOrEqual(t, got, time.Duration(float64(tt.want)*1.2))
+			}
+		})
+	}
+}
+
+func TestBackoffConfig_NoJitter(t *testing.T) {
+	bc := BackoffConfig{BaseDelay: 10 * time.Millisecond, Multiplier: 1.6, MaxDelay: time.Second}
+	assert.Equal(t, 10*time.Millisecond, bc.backoff(0))
+	assert.Equal(t, 16*time.Millisecond, bc.backoff(1))
+}
@@ -0,0 +1,83 @@
+// Package balancer 定义客户端的负载均衡接口。
+//
+// 客户端为 resolver 解析出的每个地址创建一个 SubConn，地址列表或任意 SubConn 的连接状态变化时，
+// 调用 Balancer.UpdateState 生成新的 Picker，之后每次调用都通过 Picker 选择一个 SubConn。
+// 内置 PickFirst、RoundRobin、WeightedRoundRobin、P2C、ConsistentHash 五种策略，
+// 也可以实现 Builder 通过 trpc.WithBalancer 使用自定义的策略
+package balancer
+
+import (
+	"context"
+	"errors"
+	"v2/trpc/connectivity"
+	"v2/trpc/resolver"
+)
+
+var (
+	// ErrNoSubConnAvailable Picker 暂时没有可用的 SubConn，调用等待下一个 Picker
+	ErrNoSubConnAvailable = errors.New("没有可用的连接")
+	// ErrTransientFailure 所有 SubConn 都连接失败，调用默认立即以 codes.Unavailable 失败，
+	// 使用 WaitForReady 的调用等待下一个 Picker
+	ErrTransientFailure = errors.New("所有连接都不可用")
+)
+
+// SubConn 一个地址的连接，由客户端创建和关闭
+type SubConn interface {
+	// Address 连接的地址
+	Address() resolver.Address
+	// State 连接状态
+	State() connectivity.State
+	// Load 进行中的调用和流的数量
+	Load() int
+	// Connect 处于 Idle 状态时在后台开始建立连接
+	Connect()
+}
+
+// PickInfo 一次调用的信息
+type PickInfo struct {
+	// Method 调用的方法，格式为 service_name.method_name
+	Method string
+	// Ctx 调用的 ctx，可以通过 metadata.FromOutgoingContext 获取元数据
+	Ctx context.Context
+}
+
+// Picker 为每次调用选择 SubConn，会被并发调用。
+// 返回 ErrNoSubConnAvailable 或 ErrTransientFailure 之外的错误时，调用以该错误失败
+type Picker interface {
+	Pick(info PickInfo) (SubConn, error)
+}
+
+// Balancer 根据 SubConn 的状态生成 Picker
+type Balancer interface {
+	// UpdateState 地址列表或 SubConn 的连接状态变化时调用，subConns 按 resolver 推送的顺序排列。
+	// 不会被并发调用，不能阻塞
+	UpdateState(subConns []SubConn) Picker
+}
+
+// Builder 为每个客户端创建一个 Balancer
+type Builder interface {
+	Build() Balancer
+	// Name 策略名称
+	Name() string
+}
+
+// errPicker 总是返回同一个错误的 Picker
+type errPicker struct {
+	err error
+}
+
+func (p errPicker) Pick(PickInfo) (SubConn, error) {
+	return nil, p.err
+}
+
+// idlePicker 第一次调用时为 Idle 的 SubConn 建立连接，调用等待连接建立完成
+type idlePicker struct {
+	subConns []SubConn
+}
+
+func (p idlePicker) Pick(PickInfo) (SubConn, error) {
+	for _, sc := range p.subConns {
+		sc.Connect()
+	}
+	return nil, ErrNoSubConnAvailable
+}
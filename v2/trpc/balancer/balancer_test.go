@@ -0,0 +1,251 @@
+//go:build unit
+
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"v2/trpc/connectivity"
+	"v2/trpc/metadata"
+	"v2/trpc/resolver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSubConn 状态和负载固定的 SubConn
+type testSubConn struct {
+	addr      resolver.Address
+	state     connectivity.State
+	load      int
+	connected bool // 是否调用过 Connect
+}
+
+func (sc *testSubConn) Address() resolver.Address { return sc.addr }
+
+func (sc *testSubConn) State() connectivity.State { return sc.state }
+
+func (sc *testSubConn) Load() int { return sc.load }
+
+func (sc *testSubConn) Connect() { sc.connected = true }
+
+func newTestSubConns(states ...connectivity.State) []*testSubConn {
+	scs := make([]*testSubConn, len(states))
+	for i, state := range states {
+		scs[i] = &testSubConn{addr: resolver.Address{Addr: fmt.Sprintf("10.0.0.%d:50051", i+1)}, state: state}
+	}
+	return scs
+}
+
+func toSubConns(scs []*testSubConn) []SubConn {
+	subConns := make([]SubConn, len(scs))
+	for i, sc := range scs {
+		subConns[i] = sc
+	}
+	return subConns
+}
+
+// pickAddrs 连续选择 n 次，返回每次选中的地址
+func pickAddrs(t *testing.T, p Picker, info PickInfo, n int) []string {
+	t.Helper()
+	addrs := make([]string, n)
+	for i := range addrs {
+		sc, err := p.Pick(info)
+		require.NoError(t, err)
+		addrs[i] = sc.Address().Addr
+	}
+	return addrs
+}
+
+func TestPickFirst(t *testing.T) {
+	tests := []struct {
+		name    string
+		states  []connectivity.State
+		want    string // 选中的地址，为空时期望返回 wantErr
+		wantErr error
+	}{
+		{name: "第一个地址可用", states: []connectivity.State{connectivity.Ready, connectivity.Ready}, want: "10.0.0.1:50051"},
+		{name: "第一个地址连接失败时使用第二个", states: []connectivity.State{connectivity.TransientFailure, connectivity.Ready}, want: "10.0.0.2:50051"},
+		{name: "第一个地址正在连接时等待", states: []connectivity.State{connectivity.Connecting, connectivity.Ready}, wantErr: ErrNoSubConnAvailable},
+		{name: "第一个地址空闲时建立连接", states: []connectivity.State{connectivity.Idle, connectivity.Idle}, wantErr: ErrNoSubConnAvailable},
+		{name: "所有地址都连接失败", states: []connectivity.State{connectivity.TransientFailure, connectivity.TransientFailure}, wantErr: ErrTransientFailure},
+		{name: "没有地址", wantErr: ErrTransientFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := PickFirst().Build().UpdateState(toSubConns(newTestSubConns(tt.states...)))
+			sc, err := p.Pick(PickInfo{Ctx: context.Background()})
+			if tt.want == "" {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, sc.Address().Addr)
+		})
+	}
+}
+
+func TestPickFirst_ConnectLazily(t *testing.T) {
+	scs := newTestSubConns(connectivity.Idle, connectivity.Idle)
+	p := PickFirst().Build().UpdateState(toSubConns(scs))
+	assert.False(t, scs[0].connected, "生成 Picker 时不应建立连接")
+
+	_, err := p.Pick(PickInfo{Ctx: context.Background()})
+	assert.ErrorIs(t, err, ErrNoSubConnAvailable)
+	assert.True(t, scs[0].connected)
+	assert.False(t, scs[1].connected, "第一个地址可用时不应为后面的地址建立连接")
+}
+
+func TestBaseBalancer(t *testing.T) {
+	tests := []struct {
+		name    string
+		states  []connectivity.State
+		wantErr error // 为 nil 时期望选中 Ready 的地址
+	}{
+		{name: "只选择可用的地址", states: []connectivity.State{connectivity.TransientFailure, connectivity.Ready, connectivity.Connecting}},
+		{name: "没有可用的地址时等待连接", states: []connectivity.State{connectivity.TransientFailure, connectivity.Connecting}, wantErr: ErrNoSubConnAvailable},
+		{name: "空闲的地址建立连接", states: []connectivity.State{connectivity.Idle, connectivity.TransientFailure}, wantErr: ErrNoSubConnAvailable},
+		{name: "所有地址都连接失败", states: []connectivity.State{connectivity.TransientFailure, connectivity.TransientFailure}, wantErr: ErrTransientFailure},
+	}
+
+	builders := []Builder{RoundRobin(), WeightedRoundRobin(), P2C(), ConsistentHash("user-id")}
+	for _, b := range builders {
+		for _, tt := range tests {
+			t.Run(b.Name()+"/"+tt.name, func(t *testing.T) {
+				p := b.Build().UpdateState(toSubConns(newTestSubConns(tt.states...)))
+				sc, err := p.Pick(PickInfo{Ctx: context.Background()})
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, connectivity.Ready, sc.State())
+			})
+		}
+	}
+}
+
+func TestBaseBalancer_ConnectIdle(t *testing.T) {
+	scs := newTestSubConns(connectivity.Ready, connectivity.Idle)
+	RoundRobin().Build().UpdateState(toSubConns(scs))
+	assert.True(t, scs[1].connected, "有可用的地址时空闲的地址也应建立连接")
+}
+
+func TestRoundRobin(t *testing.T) {
+	scs := newTestSubConns(connectivity.Ready, connectivity.Ready, connectivity.Ready)
+	p := RoundRobin().Build().UpdateState(toSubConns(scs))
+
+	counts := make(map[string]int)
+	addrs := pickAddrs(t, p, PickInfo{Ctx: context.Background()}, 9)
+	for i, addr := range addrs {
+		counts[addr]++
+		if i > 0 {
+			assert.NotEqual(t, addrs[i-1], addr, "轮询不应连续选择同一个地址")
+		}
+	}
+	for _, sc := range scs {
+		assert.Equal(t, 3, counts[sc.addr.Addr])
+	}
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	scs := newTestSubConns(connectivity.Ready, connectivity.Ready, connectivity.Ready)
+	scs[0].addr.Weight = 5
+	scs[1].addr.Weight = 1
+	scs[2].addr.Weight = 0 // 视为 1
+	p := WeightedRoundRobin().Build().UpdateState(toSubConns(scs))
+
+	counts := make(map[string]int)
+	for _, addr := range pickAddrs(t, p, PickInfo{Ctx: context.Background()}, 14) {
+		counts[addr]++
+	}
+	assert.Equal(t, 10, counts["10.0.0.1:50051"])
+	assert.Equal(t, 2, counts["10.0.0.2:50051"])
+	assert.Equal(t, 2, counts["10.0.0.3:50051"])
+
+	// 平滑加权轮询：权重为 5 的地址不会被连续选中 5 次
+	assert.Equal(t,
+		[]string{"10.0.0.1:50051", "10.0.0.1:50051", "10.0.0.2:50051", "10.0.0.1:50051", "10.0.0.3:50051", "10.0.0.1:50051", "10.0.0.1:50051"},
+		pickAddrs(t, p, PickInfo{Ctx: context.Background()}, 7))
+}
+
+func TestP2C(t *testing.T) {
+	tests := []struct {
+		name  string
+		loads []int
+		want  string
+	}{
+		{name: "只有一个地址", loads: []int{10}, want: "10.0.0.1:50051"},
+		{name: "选择负载较低的地址", loads: []int{5, 0}, want: "10.0.0.2:50051"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scs := make([]*testSubConn, len(tt.loads))
+			for i, load := range tt.loads {
+				scs[i] = &testSubConn{addr: resolver.Address{Addr: fmt.Sprintf("10.0.0.%d:50051", i+1)}, state: connectivity.Ready, load: load}
+			}
+			p := P2C().Build().UpdateState(toSubConns(scs))
+			for _, addr := range pickAddrs(t, p, PickInfo{Ctx: context.Background()}, 20) {
+				assert.Equal(t, tt.want, addr)
+			}
+		})
+	}
+}
+
+func TestP2C_AvoidBusiest(t *testing.T) {
+	scs := newTestSubConns(connectivity.Ready, connectivity.Ready, connectivity.Ready)
+	scs[0].load = 10
+	p := P2C().Build().UpdateState(toSubConns(scs))
+
+	// 负载最高的地址总会在两两比较中落选
+	for _, addr := range pickAddrs(t, p, PickInfo{Ctx: context.Background()}, 100) {
+		assert.NotEqual(t, "10.0.0.1:50051", addr)
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	scs := newTestSubConns(connectivity.Ready, connectivity.Ready, connectivity.Ready)
+	b := ConsistentHash("user-id").Build()
+	p := b.UpdateState(toSubConns(scs))
+
+	infoOf := func(userID string) PickInfo {
+		return PickInfo{Ctx: metadata.NewOutgoingContext(context.Background(), metadata.Pairs("user-id", userID))}
+	}
+
+	// 相同的值总是选择同一个地址
+	picked := make(map[string]string)
+	used := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		addrs := pickAddrs(t, p, infoOf(userID), 3)
+		assert.Equal(t, addrs[0], addrs[1])
+		assert.Equal(t, addrs[0], addrs[2])
+		picked[userID] = addrs[0]
+		used[addrs[0]] = true
+	}
+	assert.Len(t, used, 3, "不同的值应分散到所有地址")
+
+	// 移除一个地址后，原来选择其他地址的值不受影响
+	p = b.UpdateState(toSubConns(scs[:2]))
+	for userID, addr := range picked {
+		got := pickAddrs(t, p, infoOf(userID), 1)[0]
+		if addr != scs[2].addr.Addr {
+			assert.Equal(t, addr, got, userID)
+		}
+	}
+}
+
+func TestConsistentHash_NoKey(t *testing.T) {
+	scs := newTestSubConns(connectivity.Ready, connectivity.Ready)
+	p := ConsistentHash("user-id").Build().UpdateState(toSubConns(scs))
+
+	// 没有元数据时随机选择
+	used := make(map[string]bool)
+	for _, addr := range pickAddrs(t, p, PickInfo{Ctx: context.Background()}, 100) {
+		used[addr] = true
+	}
+	assert.Len(t, used, 2)
+}
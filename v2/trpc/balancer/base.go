@@ -0,0 +1,52 @@
+package balancer
+
+import "v2/trpc/connectivity"
+
+// baseBalancer 只在 Ready 的 SubConn 中选择，newPicker 为 Ready 的 SubConn 创建 Picker。
+// 有 Ready 的 SubConn 时，Idle 的 SubConn 也开始建立连接，让调用分摊到所有地址
+type baseBalancer struct {
+	newPicker func(ready []SubConn) Picker
+}
+
+func (b *baseBalancer) UpdateState(subConns []SubConn) Picker {
+	var ready, idle []SubConn
+	connecting := false
+	for _, sc := range subConns {
+		switch sc.State() {
+		case connectivity.Ready:
+			ready = append(ready, sc)
+		case connectivity.Idle:
+			idle = append(idle, sc)
+		case connectivity.Connecting:
+			connecting = true
+		}
+	}
+
+	switch {
+	case len(ready) > 0:
+		for _, sc := range idle {
+			sc.Connect()
+		}
+		return b.newPicker(ready)
+	case len(idle) > 0:
+		return idlePicker{subConns: idle}
+	case connecting:
+		return errPicker{err: ErrNoSubConnAvailable}
+	default:
+		return errPicker{err: ErrTransientFailure}
+	}
+}
+
+// baseBuilder 创建 baseBalancer
+type baseBuilder struct {
+	name      string
+	newPicker func(ready []SubConn) Picker
+}
+
+func (b baseBuilder) Build() Balancer {
+	return &baseBalancer{newPicker: b.newPicker}
+}
+
+func (b baseBuilder) Name() string {
+	return b.name
+}
@@ -0,0 +1,75 @@
+package balancer
+
+import (
+	"cmp"
+	"hash/fnv"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"v2/trpc/metadata"
+)
+
+// hashReplicas 每个地址在哈希环上的虚拟节点数
+const hashReplicas = 100
+
+// ConsistentHash 按调用的元数据 key 的值做一致性哈希，相同的值总是选择同一个地址，
+// 地址增减时只有少部分值会换到其他地址。没有该元数据的调用随机选择
+func ConsistentHash(key string) Builder {
+	return baseBuilder{
+		name:      "consistent_hash",
+		newPicker: func(ready []SubConn) Picker { return newHashPicker(key, ready) },
+	}
+}
+
+type ringEntry struct {
+	hash uint64
+	sc   SubConn
+}
+
+type hashPicker struct {
+	key      string
+	ring     []ringEntry // 按 hash 排序
+	subConns []SubConn
+}
+
+func newHashPicker(key string, ready []SubConn) Picker {
+	p := &hashPicker{key: key, subConns: ready, ring: make([]ringEntry, 0, len(ready)*hashReplicas)}
+	for _, sc := range ready {
+		addr := sc.Address().Addr
+		for i := 0; i < hashReplicas; i++ {
+			p.ring = append(p.ring, ringEntry{hash: hashString(addr + "#" + strconv.Itoa(i)), sc: sc})
+		}
+	}
+	slices.SortFunc(p.ring, func(a, b ringEntry) int { return cmp.Compare(a.hash, b.hash) })
+	return p
+}
+
+// Pick 选择哈希环上第一个不小于元数据值的哈希的虚拟节点
+func (p *hashPicker) Pick(info PickInfo) (SubConn, error) {
+	md, _ := metadata.FromOutgoingContext(info.Ctx)
+	value := md.Get(p.key)
+	if value == "" {
+		return p.subConns[rand.IntN(len(p.subConns))], nil
+	}
+
+	h := hashString(value)
+	i, _ := slices.BinarySearchFunc(p.ring, h, func(e ringEntry, h uint64) int { return cmp.Compare(e.hash, h) })
+	if i == len(p.ring) {
+		i = 0
+	}
+	return p.ring[i].sc, nil
+}
+
+// hashString FNV-1a 对只有末尾几个字符不同的字符串，哈希值的高位几乎相同，
+// 再经过 murmur3 的 fmix64 打散，让相近的值均匀分布在哈希环上
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,34 @@
+package balancer
+
+import "math/rand/v2"
+
+// P2C 随机选择两个可用的地址，使用进行中的调用和流较少的一个
+func P2C() Builder {
+	return baseBuilder{name: "p2c", newPicker: newP2CPicker}
+}
+
+type p2cPicker struct {
+	subConns []SubConn
+}
+
+func newP2CPicker(ready []SubConn) Picker {
+	return &p2cPicker{subConns: ready}
+}
+
+func (p *p2cPicker) Pick(PickInfo) (SubConn, error) {
+	n := len(p.subConns)
+	if n == 1 {
+		return p.subConns[0], nil
+	}
+
+	i := rand.IntN(n)
+	j := rand.IntN(n - 1)
+	if j >= i {
+		j++
+	}
+	a, b := p.subConns[i], p.subConns[j]
+	if b.Load() < a.Load() {
+		return b, nil
+	}
+	return a, nil
+}
@@ -0,0 +1,40 @@
+package balancer
+
+import "v2/trpc/connectivity"
+
+// PickFirst 按地址顺序使用第一个可用的地址，前面的地址连接失败时才使用后面的地址，
+// 前面的地址恢复后切换回去。连接池不需要保持最少连接数时，只有需要时才为后面的地址建立连接。客户端默认使用该策略
+func PickFirst() Builder {
+	return pickFirstBuilder{}
+}
+
+type pickFirstBuilder struct{}
+
+func (pickFirstBuilder) Build() Balancer { return pickFirstBalancer{} }
+
+func (pickFirstBuilder) Name() string { return "pick_first" }
+
+type pickFirstBalancer struct{}
+
+func (pickFirstBalancer) UpdateState(subConns []SubConn) Picker {
+	for _, sc := range subConns {
+		switch sc.State() {
+		case connectivity.Ready:
+			return pickFirstPicker{sc: sc}
+		case connectivity.Idle:
+			return idlePicker{subConns: []SubConn{sc}}
+		case connectivity.Connecting:
+			return errPicker{err: ErrNoSubConnAvailable}
+		}
+		// TransientFailure 时尝试下一个地址，连接失败的地址在后台继续重连
+	}
+	return errPicker{err: ErrTransientFailure}
+}
+
+type pickFirstPicker struct {
+	sc SubConn
+}
+
+func (p pickFirstPicker) Pick(PickInfo) (SubConn, error) {
+	return p.sc, nil
+}
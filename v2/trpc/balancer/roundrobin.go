@@ -0,0 +1,28 @@
+package balancer
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// RoundRobin 在所有可用的地址之间轮流选择
+func RoundRobin() Builder {
+	return baseBuilder{name: "round_robin", newPicker: newRoundRobinPicker}
+}
+
+type roundRobinPicker struct {
+	subConns []SubConn
+	next     atomic.Uint32
+}
+
+func newRoundRobinPicker(ready []SubConn) Picker {
+	p := &roundRobinPicker{subConns: ready}
+	// 从随机位置开始，避免所有客户端都先选择第一个地址
+	p.next.Store(rand.Uint32N(uint32(len(ready))))
+	return p
+}
+
+func (p *roundRobinPicker) Pick(PickInfo) (SubConn, error) {
+	n := p.next.Add(1) - 1
+	return p.subConns[n%uint32(len(p.subConns))], nil
+}
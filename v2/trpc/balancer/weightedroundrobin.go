@@ -0,0 +1,47 @@
+package balancer
+
+import "sync"
+
+// WeightedRoundRobin 按地址的权重（resolver.Address.Weight，不大于 0 时视为 1）轮流选择，
+// 使用平滑加权轮询，权重高的地址不会被连续选中
+func WeightedRoundRobin() Builder {
+	return baseBuilder{name: "weighted_round_robin", newPicker: newWeightedRoundRobinPicker}
+}
+
+type weightedSubConn struct {
+	sc      SubConn
+	weight  int
+	current int
+}
+
+type weightedRoundRobinPicker struct {
+	mu       sync.Mutex
+	subConns []*weightedSubConn
+	total    int
+}
+
+func newWeightedRoundRobinPicker(ready []SubConn) Picker {
+	p := &weightedRoundRobinPicker{subConns: make([]*weightedSubConn, len(ready))}
+	for i, sc := range ready {
+		weight := max(sc.Address().Weight, 1)
+		p.subConns[i] = &weightedSubConn{sc: sc, weight: weight}
+		p.total += weight
+	}
+	return p
+}
+
+// Pick 每次所有地址的 current 增加各自的权重，选择 current 最大的地址并减去总权重
+func (p *weightedRoundRobinPicker) Pick(PickInfo) (SubConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var best *weightedSubConn
+	for _, w := range p.subConns {
+		w.current += w.weight
+		if best == nil || w.current > best.current {
+			best = w
+		}
+	}
+	best.current -= p.total
+	return best.sc, nil
+}
@@ -0,0 +1,112 @@
+package trpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"v2/api"
+	"v2/trpc/codes"
+	"v2/trpc/status"
+)
+
+var clientStreamType = reflect.TypeOf((*api.ClientStream)(nil)).Elem()
+
+// BindClient 使用 reflect.MakeFunc 填充 client 中所有导出的函数字段，调用字段时通过 cc 调用 serviceName.字段名。
+// client 必须是结构体指针，函数字段的签名必须为 func(ctx context.Context, req *ReqType) (*RespType, error)，
+// 存在不符合约定的函数字段时返回错误，错误中列出所有不符合约定的字段，client 不会被修改。
+// 流式方法的字段（返回值为 api.ClientStream 的接口类型）无法通过反射实现，会被跳过并保持原值，
+// 需要调用流式方法时使用生成的 NewXXXClient。非函数字段和未导出的字段同样会被忽略：
+//
+//	var c pb.UserClient
+//	err := trpc.BindClient(conn, &c, "user_service")
+//	reply, err := c.User(ctx, &pb.ApplyUser{Uid: 1})
+//	// c.ListUsers 等流式方法的字段仍为 nil
+func BindClient(cc api.ClientConnInterface, client any, serviceName string) error {
+	if cc == nil {
+		return errors.New("cc 为nil")
+	}
+	if serviceName == "" || strings.Contains(serviceName, ".") {
+		return fmt.Errorf("服务名称 %q 不能为空或包含 \".\"", serviceName)
+	}
+
+	v := reflect.ValueOf(client)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("client 应为非nil的结构体指针，实际为 %T", client)
+	}
+	v = v.Elem()
+	t := v.Type()
+
+	funcs := make(map[int]reflect.Value)
+	var errs []error
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Type.Kind() != reflect.Func || isStreamFunc(field.Type) {
+			continue
+		}
+		if err := checkClientFunc(field.Type); err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s %w", t, field.Name, err))
+			continue
+		}
+		funcs[i] = makeClientFunc(cc, field.Type, serviceName+"."+field.Name)
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if len(funcs) == 0 {
+		return fmt.Errorf("%s 没有可绑定的函数字段", t)
+	}
+
+	for i, fn := range funcs {
+		v.Field(i).Set(fn)
+	}
+	return nil
+}
+
+// checkClientFunc 检查函数字段的签名是否为 func(context.Context, *ReqType) (*RespType, error)
+func checkClientFunc(t reflect.Type) error {
+	if t.IsVariadic() || t.NumIn() != 2 {
+		return errors.New("参数数量不正确，应为 (context.Context, *ReqType)")
+	}
+	if t.In(0) != contextType {
+		return fmt.Errorf("第一个参数应为 context.Context，实际为 %s", t.In(0))
+	}
+	if t.In(1).Kind() != reflect.Pointer {
+		return fmt.Errorf("第二个参数应为指针，实际为 %s", t.In(1))
+	}
+	if t.NumOut() != 2 {
+		return errors.New("返回值数量不正确，应为 (*RespType, error)")
+	}
+	if t.Out(0).Kind() != reflect.Pointer {
+		return fmt.Errorf("第一个返回值应为指针，实际为 %s", t.Out(0))
+	}
+	if t.Out(1) != errorType {
+		return fmt.Errorf("第二个返回值应为 error，实际为 %s", t.Out(1))
+	}
+	return nil
+}
+
+// makeClientFunc 创建调用 method 的函数，每次调用 new 一个新的响应，ctx 为 nil 时返回 codes.InvalidArgument
+func makeClientFunc(cc api.ClientConnInterface, t reflect.Type, method string) reflect.Value {
+	replyType := t.Out(0)
+	return reflect.MakeFunc(t, func(args []reflect.Value) []reflect.Value {
+		ctx, ok := args[0].Interface().(context.Context)
+		if !ok {
+			err := status.Errorf(codes.InvalidArgument, "调用 %s 的 ctx 不能为nil", method)
+			return []reflect.Value{reflect.Zero(replyType), reflect.ValueOf(&err).Elem()}
+		}
+		reply := reflect.New(replyType.Elem())
+		if err := cc.Invoke(ctx, method, args[1].Interface(), reply.Interface()); err != nil {
+			return []reflect.Value{reflect.Zero(replyType), reflect.ValueOf(&err).Elem()}
+		}
+		return []reflect.Value{reply, reflect.Zero(errorType)}
+	})
+}
+
+// isStreamFunc 报告函数字段是否为生成代码中流式方法的签名 func(context.Context, *ReqType) (XXXClient, error)
+func isStreamFunc(t reflect.Type) bool {
+	return t.NumOut() == 2 && t.Out(0).Kind() == reflect.Interface && t.Out(0).Implements(clientStreamType) &&
+		t.Out(1) == errorType
+}
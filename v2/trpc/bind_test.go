@@ -0,0 +1,146 @@
+//go:build unit
+
+package trpc
+
+import (
+	"context"
+	"testing"
+	"v2/pb"
+	"v2/trpc/codes"
+	"v2/trpc/status"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindClient(t *testing.T) {
+	server := newTestServer(t)
+	require.NoError(t, pb.RegisterHelloServer(server, &serverImpl{}))
+	require.NoError(t, pb.RegisterUserServer(server, &errorServiceImpl{}))
+	go server.Start()
+
+	client := newTestClient(t, server.listener.Addr().String())
+
+	var hello pb.HelloClient
+	require.NoError(t, BindClient(client, &hello, pb.HelloServiceName))
+	resp, err := hello.Hello(context.Background(), &pb.ApplyHello{Name: "Tan"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Tan!", resp.Msg)
+
+	// 服务端返回的错误原样返回
+	var user pb.UserClient
+	require.NoError(t, BindClient(client, &user, pb.UserServiceName))
+	reply, err := user.User(context.Background(), &pb.ApplyUser{Uid: 3})
+	assert.Nil(t, reply)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// 流式方法的字段被跳过
+	assert.Nil(t, user.ListUsers)
+	assert.Nil(t, user.AddUsers)
+	assert.Nil(t, user.GetUsers)
+
+	// ctx 为 nil 时返回 codes.InvalidArgument
+	reply, err = user.User(nil, &pb.ApplyUser{Uid: 1})
+	assert.Nil(t, reply)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// 新增的字段不需要任何模板代码
+	var extended struct {
+		Hello  func(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error)
+		Bye    func(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error)
+		Name   string
+		hidden func()
+	}
+	require.NoError(t, BindClient(client, &extended, pb.HelloServiceName))
+	assert.Nil(t, extended.hidden)
+	resp, err = extended.Hello(context.Background(), &pb.ApplyHello{Name: "Liu"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Liu!", resp.Msg)
+	_, err = extended.Bye(context.Background(), &pb.ApplyHello{Name: "Liu"})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestBindClient_Errors(t *testing.T) {
+	client := createTestClient(t)
+	defer client.Close()
+
+	type badArg struct {
+		Hello func(ctx context.Context, apply pb.ApplyHello) (*pb.ReplyHello, error)
+	}
+	type badResult struct {
+		Hello func(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, string)
+		Bye   func(apply *pb.ApplyHello) (*pb.ReplyHello, error)
+	}
+	type noFunc struct {
+		Name      string
+		ListUsers func(ctx context.Context, in *pb.ApplyList) (pb.User_ListUsersClient, error)
+	}
+
+	tests := []struct {
+		name        string
+		client      any
+		serviceName string
+		wantErr     []string
+	}{
+		{
+			name:        "非指针-失败",
+			client:      pb.HelloClient{},
+			serviceName: "hello_service",
+			wantErr:     []string{"结构体指针"},
+		},
+		{
+			name:        "nil指针-失败",
+			client:      (*pb.HelloClient)(nil),
+			serviceName: "hello_service",
+			wantErr:     []string{"结构体指针"},
+		},
+		{
+			name:        "空服务名称-失败",
+			client:      &pb.HelloClient{},
+			serviceName: "",
+			wantErr:     []string{"不能为空"},
+		},
+		{
+			name:        "服务名称包含点-失败",
+			client:      &pb.HelloClient{},
+			serviceName: "hello.service",
+			wantErr:     []string{"不能为空或包含"},
+		},
+		{
+			name:        "参数不是指针-失败",
+			client:      &badArg{},
+			serviceName: "hello_service",
+			wantErr:     []string{"badArg.Hello 第二个参数应为指针"},
+		},
+		{
+			name:        "列出所有错误的字段-失败",
+			client:      &badResult{},
+			serviceName: "hello_service",
+			wantErr:     []string{"badResult.Hello 第二个返回值应为 error", "badResult.Bye 参数数量不正确"},
+		},
+		{
+			name:        "没有函数字段-失败",
+			client:      &noFunc{},
+			serviceName: "hello_service",
+			wantErr:     []string{"没有可绑定的函数字段"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := BindClient(client, tt.client, tt.serviceName)
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.ErrorContains(t, err, want)
+			}
+		})
+	}
+
+	// 绑定失败时不修改 client，包括签名正确的字段
+	c := &struct {
+		Hello func(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error)
+		Bye   func(apply *pb.ApplyHello) (*pb.ReplyHello, error)
+	}{}
+	require.Error(t, BindClient(client, c, "hello_service"))
+	assert.Nil(t, c.Hello)
+}
@@ -0,0 +1,150 @@
+package trpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"v2/trpc/codec"
+	"v2/trpc/metadata"
+)
+
+// CallOption 单次调用的配置。
+// 生成的客户端代码的方法签名中没有 CallOption 参数，因此通过 WithCallOptions 放入 ctx 传递
+type CallOption func(*callInfo)
+
+// callInfo 单次调用的配置
+type callInfo struct {
+	header  *metadata.MD
+	trailer *metadata.MD
+	codec   codec.Codec
+
+	waitForReady bool
+}
+
+type callOptionsKey struct{}
+
+// WithCallOptions 将调用配置放入 ctx，追加在 ctx 中已有的配置之后
+func WithCallOptions(ctx context.Context, opts ...CallOption) context.Context {
+	prev, _ := ctx.Value(callOptionsKey{}).([]CallOption)
+	all := make([]CallOption, 0, len(prev)+len(opts))
+	all = append(all, prev...)
+	all = append(all, opts...)
+	return context.WithValue(ctx, callOptionsKey{}, all)
+}
+
+func callInfoFromContext(ctx context.Context) *callInfo {
+	c := &callInfo{}
+	opts, _ := ctx.Value(callOptionsKey{}).([]CallOption)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Header 调用结束后将服务端设置的响应头写入 md
+func Header(md *metadata.MD) CallOption {
+	return func(c *callInfo) {
+		c.header = md
+	}
+}
+
+// Trailer 调用结束后将服务端设置的响应尾写入 md
+func Trailer(md *metadata.MD) CallOption {
+	return func(c *callInfo) {
+		c.trailer = md
+	}
+}
+
+// CallCodec 本次调用使用的编码，覆盖客户端的 WithCodec 配置
+func CallCodec(c codec.Codec) CallOption {
+	return func(ci *callInfo) {
+		ci.codec = c
+	}
+}
+
+// WaitForReady 设置没有可用连接时的行为。默认为 false，客户端处于 TransientFailure 状态时调用立即以
+// codes.Unavailable 失败；为 true 时等待重新建立连接，直到连接可用或 ctx 结束
+func WaitForReady(waitForReady bool) CallOption {
+	return func(c *callInfo) {
+		c.waitForReady = waitForReady
+	}
+}
+
+var (
+	errNoServerCall = errors.New("ctx 中没有服务端调用信息")
+	errHeaderSent   = errors.New("响应头已发送")
+)
+
+// serverCall 服务端单次调用的状态，保存服务方法设置的响应头和响应尾
+type serverCall struct {
+	mu         sync.Mutex
+	header     metadata.MD
+	trailer    metadata.MD
+	headerSent bool // 流式调用发送第一条消息前会先发送响应头，之后不能再设置响应头
+}
+
+type serverCallKey struct{}
+
+func newServerCallContext(ctx context.Context, call *serverCall) context.Context {
+	return context.WithValue(ctx, serverCallKey{}, call)
+}
+
+func serverCallFromContext(ctx context.Context) (*serverCall, bool) {
+	call, ok := ctx.Value(serverCallKey{}).(*serverCall)
+	return call, ok
+}
+
+// SetHeader 服务方法设置响应头，多次调用时合并。流式调用的响应头发送后返回错误
+func SetHeader(ctx context.Context, md metadata.MD) error {
+	call, ok := serverCallFromContext(ctx)
+	if !ok {
+		return errNoServerCall
+	}
+	return call.setHeader(md)
+}
+
+// SetTrailer 服务方法设置响应尾，多次调用时合并，调用失败时也会返回给客户端
+func SetTrailer(ctx context.Context, md metadata.MD) error {
+	call, ok := serverCallFromContext(ctx)
+	if !ok {
+		return errNoServerCall
+	}
+	call.setTrailer(md)
+	return nil
+}
+
+func (c *serverCall) setHeader(md metadata.MD) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.headerSent {
+		return errHeaderSent
+	}
+	c.header = metadata.Join(c.header, md)
+	return nil
+}
+
+func (c *serverCall) setTrailer(md metadata.MD) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trailer = metadata.Join(c.trailer, md)
+}
+
+// sendHeader 标记响应头已发送，返回需要发送的响应头，已经发送过时返回 false
+func (c *serverCall) sendHeader() (metadata.MD, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.headerSent {
+		return nil, false
+	}
+	c.headerSent = true
+	return c.header, true
+}
+
+// metadata 返回服务方法设置的响应头和响应尾
+func (c *serverCall) metadata() (header, trailer metadata.MD) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.header, c.trailer
+}
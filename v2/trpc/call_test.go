@@ -0,0 +1,125 @@
+//go:build unit
+
+package trpc
+
+import (
+	"context"
+	"testing"
+	"v2/pb"
+	"v2/trpc/codes"
+	"v2/trpc/metadata"
+	"v2/trpc/status"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metadataServiceImpl 测试用的服务实现，回显收到的元数据并设置响应头和响应尾
+type metadataServiceImpl struct{}
+
+func (s *metadataServiceImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if err := SetHeader(ctx, metadata.Pairs("trace-id", md.Get("trace-id"))); err != nil {
+		return nil, err
+	}
+	if err := SetTrailer(ctx, metadata.Pairs("handled-by", "metadataServiceImpl")); err != nil {
+		return nil, err
+	}
+
+	if apply.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "空名字")
+	}
+	return &pb.ReplyHello{Msg: "Hello, " + md.Get("caller") + "!"}, nil
+}
+
+func TestMetadata(t *testing.T) {
+	server := startTestServer(t, &pb.HelloServiceDesc, &metadataServiceImpl{})
+
+	client := newTestClient(t, server.listener.Addr().String())
+
+	helloClient := pb.NewHelloClient(client)
+
+	tests := []struct {
+		name     string
+		apply    *pb.ApplyHello
+		wantMsg  string
+		wantCode codes.Code
+	}{
+		{
+			name:    "调用成功",
+			apply:   &pb.ApplyHello{Name: "Test"},
+			wantMsg: "Hello, metadata-test!",
+		},
+		{
+			name:     "调用失败-仍然返回响应头和响应尾",
+			apply:    &pb.ApplyHello{},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header, trailer metadata.MD
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "trace-id", "abc", "Caller", "metadata-test")
+			ctx = WithCallOptions(ctx, Header(&header), Trailer(&trailer))
+
+			resp, err := helloClient.Hello(ctx, tt.apply)
+			if tt.wantCode != codes.OK {
+				assert.Equal(t, tt.wantCode, status.Code(err))
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantMsg, resp.Msg)
+			}
+
+			assert.Equal(t, metadata.MD{"trace-id": "abc"}, header)
+			assert.Equal(t, metadata.MD{"handled-by": "metadataServiceImpl"}, trailer)
+		})
+	}
+}
+
+func TestMetadata_Interceptors(t *testing.T) {
+	// 服务端拦截器读取元数据做认证
+	auth := func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if md.Get("authorization") != "token" {
+			return nil, status.Error(codes.Unauthenticated, "认证失败")
+		}
+		return handler(ctx, req)
+	}
+	server := startTestServer(t, &pb.HelloServiceDesc, &serverImpl{}, ChainUnaryInterceptor(auth))
+
+	// 客户端拦截器统一添加认证信息
+	withToken := func(ctx context.Context, method string, req, reply any, cc *Client, invoker UnaryInvoker) error {
+		return invoker(metadata.AppendToOutgoingContext(ctx, "authorization", "token"), method, req, reply)
+	}
+
+	tests := []struct {
+		name     string
+		opts     []ClientOption
+		wantCode codes.Code
+	}{
+		{
+			name:     "没有认证信息",
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "拦截器添加认证信息",
+			opts:     []ClientOption{WithChainUnaryInterceptor(withToken)},
+			wantCode: codes.OK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, server.listener.Addr().String(), tt.opts...)
+
+			_, err := pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "Test"})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestSetHeader_NoServerCall(t *testing.T) {
+	assert.Error(t, SetHeader(context.Background(), metadata.Pairs("a", "1")))
+	assert.Error(t, SetTrailer(context.Background(), metadata.Pairs("a", "1")))
+}
@@ -2,18 +2,58 @@ package trpc
 
 import (
 	"context"
-	"encoding/json"
+	"encoding/binary"
 	"errors"
-	"net"
+	"fmt"
 	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"v2/api"
+	"v2/trpc/balancer"
+	"v2/trpc/codes"
+	"v2/trpc/connectivity"
+	"v2/trpc/metadata"
+	"v2/trpc/resolver"
+	"v2/trpc/status"
 )
 
+var ErrClientClosed = errors.New("客户端已关闭")
+
+// Client 可以被多个 goroutine 并发使用。
+//
+// 目标地址可以是 host:port，也可以是 scheme:///endpoint 格式的 URI（如 dns:///users.internal:50051），
+// URI 由 scheme 对应的 resolver 解析为一组地址，地址变化时客户端为新的地址建立连接，
+// 被移除的地址上进行中的调用和流结束后关闭连接。
+//
+// 每次调用由负载均衡策略（WithBalancer，默认为 balancer.PickFirst()）选择一个地址。
+// 每个地址有一个连接池，持有一条或多条连接，每次调用选择进行中的调用和流最少的连接。连接断开后从连接池中移除，
+// 并在后台按退避策略重连，重连期间的调用根据 WaitForReady 等待重连完成或立即失败，
+// 连接状态可以通过 GetState 和 WaitForStateChange 获取
 type Client struct {
-	conn net.Conn
+	opts     clientOptions
+	unaryInt UnaryClientInterceptor // 组合后的拦截器，没有拦截器时为 nil
+	network  string
+	resolver resolver.Resolver // 目标地址为 host:port 时为 nil
+	balancer balancer.Balancer
+
+	mu          sync.Mutex
+	addrs       []string             // resolver 最近一次推送的地址，保持推送的顺序
+	pools       map[string]*connPool // 每个地址的连接池
+	resolverErr error                // 最近一次解析失败的原因，解析成功后清空
+	picker      balancer.Picker      // 收到第一次地址推送之前为 nil
+	pickerCh    chan struct{}        // picker 更新时关闭并替换
+	state       connectivity.State
+	stateCh     chan struct{} // 状态变化时关闭并替换
+	closed      bool
 }
 
-func NewClient(network, targetAddr string) (*Client, error) {
-	if network != "tcp" {
+var errNoAddress = errors.New("没有可用的地址")
+
+// NewClient 创建客户端。network 支持 tcp、tcp4、tcp6 和 unix，unix 的目标地址为 socket 文件的路径。
+// targetAddr 为 host:port 或路径时立即建立连接，连接失败时返回错误；为 URI 时由 resolver 解析地址并在后台建立连接
+func NewClient(network, targetAddr string, opts ...ClientOption) (*Client, error) {
+	if !supportedNetwork(network) {
 		return nil, errors.New("不支持的协议")
 	}
 
@@ -21,13 +61,55 @@ func NewClient(network, targetAddr string) (*Client, error) {
 		return nil, errors.New("空地址")
 	}
 
-	conn, err := net.Dial(network, targetAddr)
+	c := &Client{
+		opts:     defaultClientOptions(),
+		network:  network,
+		pools:    make(map[string]*connPool),
+		stateCh:  make(chan struct{}),
+		pickerCh: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	if c.opts.minConns < 0 || c.opts.maxConns < 1 || c.opts.minConns > c.opts.maxConns {
+		return nil, fmt.Errorf("连接池大小无效: min=%d max=%d", c.opts.minConns, c.opts.maxConns)
+	}
+	c.unaryInt = chainUnaryClientInterceptors(c.opts.unaryInterceptors)
+	c.balancer = c.opts.balancer.Build()
+
+	if !strings.Contains(targetAddr, "://") {
+		pool := newConnPool(network, resolver.Address{Addr: targetAddr}, &c.opts, c.updateState)
+		c.addrs = []string{targetAddr}
+		c.pools[targetAddr] = pool
+		if err := pool.dialMin(); err != nil {
+			pool.close(ErrClientClosed)
+			return nil, err
+		}
+		c.updateState()
+		return c, nil
+	}
+
+	target, err := resolver.ParseTarget(targetAddr)
 	if err != nil {
 		return nil, err
 	}
+	builder := resolver.Get(target.Scheme)
+	if builder == nil {
+		return nil, fmt.Errorf("不支持的 scheme: %s", target.Scheme)
+	}
+	r, err := builder.Build(target, &resolverConn{c: c})
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
 
-	c := &Client{
-		conn: conn,
+	c.mu.Lock()
+	c.resolver = r
+	closed := c.closed
+	c.updateStateLocked()
+	c.mu.Unlock()
+	if closed {
+		r.Close()
 	}
 	return c, nil
 }
@@ -45,22 +127,404 @@ func (c *Client) Invoke(ctx context.Context, method string, args any, reply any)
 		return errors.New("空请求")
 	}
 
-	data := NewApply(method, args)
-	if _, err := c.conn.Write(data); err != nil {
+	if c.unaryInt == nil {
+		return c.invoke(ctx, method, args, reply)
+	}
+	return c.unaryInt(ctx, method, args, reply, c, c.invoke)
+}
+
+// invoke 发送请求并等待响应，是拦截器链的最后一环
+func (c *Client) invoke(ctx context.Context, method string, args any, reply any) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	buf := make([]byte, 1024)
-	n, err := c.conn.Read(buf)
+	// 剩余的超时时间随请求发送给服务端，服务端据此设置处理请求的 ctx
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+		if timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+	}
+
+	ci := callInfoFromContext(ctx)
+	cc := c.opts.codec
+	if ci.codec != nil {
+		cc = ci.codec
+	}
+
+	// 在选择连接之前检查方法名并编码参数，失败时不占用请求序号
+	apply, err := NewApply(method, args, cc)
 	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Errorf(codes.Internal, "请求序列化失败: %v", err)
+	}
+
+	t, err := c.getTransport(ctx, method, ci.waitForReady)
+	if err != nil {
+		return err
+	}
+	seq, ch, err := t.register()
+	if err != nil {
+		return err
+	}
+	apply.Seq = seq
+	apply.Timeout = timeout
+	apply.Metadata, _ = metadata.FromOutgoingContext(ctx)
+	if err := t.send(ctx, frameUnary, cc.Name(), apply.Marshal()); err != nil {
+		t.unregister(seq)
 		return err
 	}
 
-	buf = buf[:n]
-	json.Unmarshal(buf, reply)
+	// 超时或取消时只放弃等待，迟到的响应会被 readLoop 丢弃，连接可以继续使用
+	var r *Reply
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return connError(t.closeErr())
+		}
+		r = resp
+	case <-ctx.Done():
+		t.unregister(seq)
+		return ctx.Err()
+	}
+
+	// 无论调用成功与否，都将响应头和响应尾交给调用方
+	if ci.header != nil {
+		*ci.header = r.Header
+	}
+	if ci.trailer != nil {
+		*ci.trailer = r.Trailer
+	}
+
+	if r.Code != codes.OK {
+		return status.Error(r.Code, r.Message)
+	}
+
+	if err := cc.Unmarshal(r.Data, reply); err != nil {
+		return status.Errorf(codes.Internal, "响应解析失败: %v", err)
+	}
+	return nil
+}
+
+// NewStream 打开一个流，method 的格式与 Invoke 相同。
+// 流在 RecvMsg 返回非 nil 的错误或 ctx 结束时结束，不再接收消息时应取消 ctx 以释放流
+func (c *Client) NewStream(ctx context.Context, desc *api.StreamDesc, method string) (api.ClientStream, error) {
+	if desc == nil {
+		return nil, errors.New("空流描述")
+	}
+	serviceName, methodName, err := splitMethod(method)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "方法名格式错误: %v", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+		if timeout <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+	}
+
+	ci := callInfoFromContext(ctx)
+	cc := c.opts.codec
+	if ci.codec != nil {
+		cc = ci.codec
+	}
+
+	t, err := c.getTransport(ctx, method, ci.waitForReady)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cs := &clientStream{
+		t:        t,
+		desc:     desc,
+		codec:    cc,
+		ctx:      ctx,
+		cancel:   cancel,
+		recv:     newRecvBuffer(recvBufferLimit(t.maxMessageSize)),
+		quota:    newSendQuota(),
+		headerCh: make(chan struct{}),
+	}
+	if err := t.registerStream(cs); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	apply := &Apply{
+		Seq:         cs.id,
+		ServiceName: serviceName,
+		MethodName:  methodName,
+		Timeout:     timeout,
+	}
+	apply.Metadata, _ = metadata.FromOutgoingContext(ctx)
+	if err := t.send(ctx, frameStreamOpen, cc.Name(), apply.Marshal()); err != nil {
+		t.removeStream(cs.id)
+		// ctx 结束时打开帧可能已经在后台写入，通知服务端取消可能已经打开的流
+		if ctx.Err() != nil {
+			go t.send(context.Background(), frameStreamCancel, cc.Name(), binary.AppendUvarint(nil, cs.id))
+		}
+		cancel()
+		return nil, err
+	}
+
+	go cs.watch()
+	return cs, nil
+}
+
+// getTransport 由负载均衡策略选择地址，再从该地址的连接池中选择连接。
+// 没有可用的地址时等待，所有地址都连接失败时，waitForReady 为 false 的调用返回 codes.Unavailable
+func (c *Client) getTransport(ctx context.Context, method string, waitForReady bool) (*clientTransport, error) {
+	info := balancer.PickInfo{Method: method, Ctx: ctx}
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return nil, ErrClientClosed
+		}
+		picker, pickerCh := c.picker, c.pickerCh
+		c.mu.Unlock()
+
+		if picker != nil {
+			sc, err := picker.Pick(info)
+			switch {
+			case err == nil:
+				pool, ok := sc.(*connPool)
+				if !ok {
+					return nil, status.Errorf(codes.Internal, "负载均衡策略返回了未知的连接: %T", sc)
+				}
+				t, err := pool.get(ctx, waitForReady)
+				if errors.Is(err, errPoolClosed) {
+					// 地址刚刚被移除或客户端刚刚关闭，等待新的 picker 重新选择
+					break
+				}
+				return t, err
+			case errors.Is(err, balancer.ErrNoSubConnAvailable):
+			case errors.Is(err, balancer.ErrTransientFailure):
+				if !waitForReady {
+					c.mu.Lock()
+					err := status.Errorf(codes.Unavailable, "连接不可用: %v", c.unavailableErr())
+					c.mu.Unlock()
+					return nil, err
+				}
+			default:
+				if _, ok := status.FromError(err); ok {
+					return nil, err
+				}
+				return nil, status.Errorf(codes.Unavailable, "选择连接失败: %v", err)
+			}
+		}
+
+		select {
+		case <-pickerCh:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// unavailableErr 没有可用连接的原因，调用方持有 c.mu
+func (c *Client) unavailableErr() error {
+	for _, addr := range c.addrs {
+		if err := c.pools[addr].err(); err != nil {
+			return err
+		}
+	}
+	if c.resolverErr != nil {
+		return c.resolverErr
+	}
+	return errNoAddress
+}
+
+// updateState 连接池的状态变化时调用
+func (c *Client) updateState() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updateStateLocked()
+}
+
+// updateStateLocked 汇总所有地址的状态：任意地址 Ready 时为 Ready，否则依次为 Connecting、Idle、TransientFailure。
+// 进入 TransientFailure 时通知 resolver 重新解析。收到过地址推送后同时生成新的 picker。调用方持有 c.mu
+func (c *Client) updateStateLocked() {
+	c.updatePickerLocked()
+
+	states := make(map[connectivity.State]bool)
+	for _, pool := range c.pools {
+		states[pool.State()] = true
+	}
+
+	var state connectivity.State
+	switch {
+	case c.closed:
+		state = connectivity.Shutdown
+	case states[connectivity.Ready]:
+		state = connectivity.Ready
+	case states[connectivity.Connecting]:
+		state = connectivity.Connecting
+	case states[connectivity.Idle]:
+		state = connectivity.Idle
+	case len(c.pools) == 0 && c.resolverErr == nil && c.addrs == nil:
+		// 还没有收到 resolver 的第一次推送
+		state = connectivity.Connecting
+	default:
+		state = connectivity.TransientFailure
+	}
+
+	if state == c.state {
+		return
+	}
+	c.state = state
+	close(c.stateCh)
+	c.stateCh = make(chan struct{})
+	if state == connectivity.TransientFailure && c.resolver != nil {
+		go c.resolver.ResolveNow()
+	}
+}
+
+// updatePickerLocked 按地址顺序将所有连接池交给负载均衡策略生成新的 picker，唤醒等待 picker 的调用，调用方持有 c.mu
+func (c *Client) updatePickerLocked() {
+	if c.closed || c.addrs == nil {
+		return
+	}
+	subConns := make([]balancer.SubConn, 0, len(c.addrs))
+	for _, addr := range c.addrs {
+		subConns = append(subConns, c.pools[addr])
+	}
+	c.picker = c.balancer.UpdateState(subConns)
+	close(c.pickerCh)
+	c.pickerCh = make(chan struct{})
+}
+
+// updateAddrs resolver 推送了新的地址列表，为新的地址创建连接池，排空被移除的地址的连接池
+func (c *Client) updateAddrs(addrs []resolver.Address) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrClientClosed
+	}
+
+	c.addrs = make([]string, 0, len(addrs))
+	pools := make(map[string]*connPool, len(addrs))
+	for _, a := range addrs {
+		if _, ok := pools[a.Addr]; ok {
+			continue
+		}
+		pool, ok := c.pools[a.Addr]
+		if ok {
+			pool.setAddress(a)
+		} else {
+			pool = newConnPool(c.network, a, &c.opts, c.updateState)
+			if c.opts.minConns > 0 {
+				pool.Connect()
+			}
+		}
+		c.addrs = append(c.addrs, a.Addr)
+		pools[a.Addr] = pool
+	}
+	for addr, pool := range c.pools {
+		if _, ok := pools[addr]; !ok {
+			pool.drain()
+		}
+	}
+	c.pools = pools
+
+	c.resolverErr = nil
+	if len(addrs) == 0 {
+		c.resolverErr = errNoAddress
+	}
+	c.updateStateLocked()
 	return nil
 }
 
+// resolverError resolver 解析失败，保留之前的地址
+func (c *Client) resolverError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resolverErr = err
+	c.updateStateLocked()
+}
+
+// GetState 返回客户端当前的连接状态
+func (c *Client) GetState() connectivity.State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// WaitForStateChange 等待连接状态不再是 sourceState，状态变化时返回 true，ctx 结束时返回 false
+func (c *Client) WaitForStateChange(ctx context.Context, sourceState connectivity.State) bool {
+	c.mu.Lock()
+	state, stateCh := c.state, c.stateCh
+	c.mu.Unlock()
+
+	if state != sourceState {
+		return true
+	}
+	select {
+	case <-stateCh:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Connect 在后台为处于 Idle 状态的地址建立连接，不等待连接建立完成
+func (c *Client) Connect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, pool := range c.pools {
+		pool.Connect()
+	}
+}
+
 func (c *Client) Close() error {
-	return c.conn.Close()
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	r, pools := c.resolver, c.pools
+	c.pools = nil
+	c.updateStateLocked()
+	// 唤醒等待 picker 的调用，让其返回 ErrClientClosed
+	close(c.pickerCh)
+	c.pickerCh = make(chan struct{})
+	c.mu.Unlock()
+
+	// resolver 关闭后不会再推送地址
+	if r != nil {
+		r.Close()
+	}
+	var firstErr error
+	for _, pool := range pools {
+		if err := pool.close(ErrClientClosed); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// resolverConn 实现 resolver.ClientConn，将 resolver 的推送转发给 Client
+type resolverConn struct {
+	c *Client
+}
+
+func (rc *resolverConn) UpdateState(s resolver.State) error {
+	return rc.c.updateAddrs(s.Addresses)
+}
+
+func (rc *resolverConn) ReportError(err error) {
+	rc.c.resolverError(err)
 }
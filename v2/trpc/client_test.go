@@ -5,25 +5,85 @@ package trpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 	"v2/pb"
+	"v2/trpc/balancer"
+	"v2/trpc/codec"
+	"v2/trpc/codes"
+	"v2/trpc/connectivity"
+	"v2/trpc/internal/bufconn"
+	"v2/trpc/metadata"
+	"v2/trpc/resolver"
+	"v2/trpc/status"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-// newTestServer 创建一个简单的测试 TCP 服务器
-func newTestServer(t *testing.T, addr string) net.Listener {
-	listener, err := net.Listen("tcp", addr)
+// testBufferSize 测试连接每个方向的缓冲区大小
+const testBufferSize = 1 << 20
+
+// testNet 测试使用的内存网络，测试不需要绑定端口。
+// 所有的主机都是本机，按端口查找监听，localhost:1 和 127.0.0.1:1 是同一个地址
+var testNet = struct {
+	sync.Mutex
+	listeners map[string]*bufconn.Listener
+	port      int
+}{listeners: make(map[string]*bufconn.Listener)}
+
+// listenTest 在内存网络上监听 addr，端口为 0 时分配一个新的端口，测试结束时关闭监听。
+// 监听关闭后可以在同一地址上重新监听
+func listenTest(t *testing.T, addr string) *bufconn.Listener {
+	host, port, err := net.SplitHostPort(addr)
 	require.NoError(t, err)
-	return listener
+
+	testNet.Lock()
+	if port == "0" {
+		testNet.port++
+		port = strconv.Itoa(testNet.port)
+	}
+	lis := bufconn.Listen(net.JoinHostPort(host, port), testBufferSize)
+	testNet.listeners[port] = lis
+	testNet.Unlock()
+
+	t.Cleanup(func() { lis.Close() })
+	return lis
+}
+
+// dialTest 连接内存网络上的监听，用作 WithContextDialer 的参数。地址上没有监听时返回错误
+func dialTest(ctx context.Context, addr string) (net.Conn, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	testNet.Lock()
+	lis, ok := testNet.listeners[port]
+	testNet.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("连接 %s 被拒绝", addr)
+	}
+	return lis.DialContext(ctx, addr)
+}
+
+// newTestClient 创建连接到内存网络上 target 的客户端，测试结束时关闭
+func newTestClient(t *testing.T, target string, opts ...ClientOption) *Client {
+	client, err := NewClient("tcp", target, append([]ClientOption{WithContextDialer(dialTest)}, opts...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
 }
 
 func TestNewClient(t *testing.T) {
 	// 启动测试服务器
-	server := newTestServer(t, "localhost:0")
+	server := startMockServer(t, mockHelloLoopHandle)
 	defer server.Close()
 
 	tests := []struct {
@@ -66,15 +126,15 @@ func TestNewClient(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client, err := NewClient(tt.network, tt.addr)
+			client, err := NewClient(tt.network, tt.addr, WithContextDialer(dialTest))
 
 			if tt.wantErr {
 				assert.Error(t, err)
 				assert.Nil(t, client)
 			} else {
-				assert.NoError(t, err)
-				assert.NotNil(t, client)
-				assert.NotNil(t, client.conn) // 验证连接已建立
+				require.NoError(t, err)
+				defer client.Close()
+				assert.Equal(t, 1, testPool(client).size()) // 验证连接已建立
 			}
 		})
 	}
@@ -82,18 +142,8 @@ func TestNewClient(t *testing.T) {
 
 // createTestClient 创建测试用的 Client
 func createTestClient(t *testing.T) *Client {
-	// 启动测试服务器
-	server, _ := net.Listen("tcp", "localhost:0")
-	go func() {
-		for {
-			conn, _ := server.Accept()
-			conn.Close()
-		}
-	}()
-
-	client, err := NewClient("tcp", server.Addr().String())
-	require.NoError(t, err)
-	return client
+	server := startMockServer(t, mockHelloLoopHandle)
+	return newTestClient(t, server.Addr().String())
 }
 
 func TestNewHelloClient(t *testing.T) {
@@ -156,9 +206,7 @@ func TestHelloClient_Hello(t *testing.T) {
 			defer server.Close()
 
 			// 创建客户端
-			client, err := NewClient("tcp", server.Addr().String())
-			require.NoError(t, err)
-			defer client.Close()
+			client := newTestClient(t, server.Addr().String())
 
 			helloClient := pb.NewHelloClient(client)
 
@@ -174,46 +222,54 @@ func TestHelloClient_Hello(t *testing.T) {
 	}
 }
 
-// startMockServer 启动一个模拟服务器
+// startMockServer 在内存网络上启动一个模拟服务器，每条连接由 handler 处理，handler 为 nil 时直接关闭连接
 func startMockServer(t *testing.T, handler func(net.Conn)) net.Listener {
-	listener, err := net.Listen("tcp", "localhost:0")
-	require.NoError(t, err)
-
-	if handler != nil {
-		go func() {
-			conn, err := listener.Accept()
-			if err != nil {
-				return
-			}
-			handler(conn)
-		}()
-	} else {
-		go func() {
-			conn, err := listener.Accept()
-			if err != nil {
-				return
-			}
-			conn.Close()
-		}()
+	if handler == nil {
+		handler = func(conn net.Conn) { conn.Close() }
 	}
 
+	listener := listenTest(t, "localhost:0")
+	go serveMock(listener, handler)
 	return listener
 }
 
-func mockHelloHandle(conn net.Conn) {
-	// 读取请求（暂时简化）
-	buf := make([]byte, 1024)
-	n, _ := conn.Read(buf)
-	buf = buf[:n]
+// serveMock 接受连接并交给 handler 处理，直到监听关闭
+func serveMock(lis net.Listener, handler func(net.Conn)) {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		go handler(conn)
+	}
+}
 
-	var a Apply
-	json.Unmarshal(buf, &a)
+// mockReadApply 读取一个请求帧并解析出请求
+func mockReadApply(conn net.Conn) (*Apply, error) {
+	f, err := readFrame(conn, DefaultMaxMessageSize)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalApply(f.payload)
+}
 
+// mockWriteHelloReply 根据 Hello 请求写回对应的响应
+func mockWriteHelloReply(conn net.Conn, a *Apply) {
 	var apply pb.ApplyHello
 	json.Unmarshal(a.Args, &apply)
-	reply := &pb.ReplyHello{Msg: fmt.Sprintf("Hello, %s!", apply.Name)}
-	resp, _ := json.Marshal(reply)
-	conn.Write(resp)
+	r, _ := NewReply(&pb.ReplyHello{Msg: fmt.Sprintf("Hello, %s!", apply.Name)}, codec.JSON{})
+	r.Seq = a.Seq
+	writeFrame(conn, 0, "json", r.Marshal(), DefaultMaxMessageSize)
+}
+
+func mockHelloHandle(conn net.Conn) {
+	a, err := mockReadApply(conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	mockWriteHelloReply(conn, a)
 	conn.Close()
 }
 
@@ -268,12 +324,10 @@ func TestClient_Invoke(t *testing.T) {
 			defer server.Close()
 
 			// 创建客户端
-			client, err := NewClient("tcp", server.Addr().String())
-			require.NoError(t, err)
-			defer client.Close()
+			client := newTestClient(t, server.Addr().String())
 
 			// 调用 Invoke
-			err = client.Invoke(context.Background(), tt.method, tt.apply, tt.reply)
+			err := client.Invoke(context.Background(), tt.method, tt.apply, tt.reply)
 
 			// 验证
 			if tt.wantErr {
@@ -288,3 +342,603 @@ func TestClient_Invoke(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_InvokeInvalidMethod(t *testing.T) {
+	server := startMockServer(t, mockHelloHandle)
+	defer server.Close()
+
+	client := newTestClient(t, server.Addr().String())
+
+	// 方法名格式错误时返回 InvalidArgument，不 panic，也不占用请求序号
+	for _, method := range []string{"nodot", "a.b.c", ".Hello", "hello_service."} {
+		err := client.Invoke(context.Background(), method, &pb.ApplyHello{Name: "Test"}, &pb.ReplyHello{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), method)
+	}
+	assert.Zero(t, testPool(client).Load())
+}
+
+// mockDropHandle 读取第一个请求后直接断开连接，模拟调用过程中连接断开
+func mockDropHandle(conn net.Conn) {
+	mockReadApply(conn)
+	conn.Close()
+}
+
+func TestClient_ConnectionLost(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(client *Client) error
+	}{
+		{
+			name: "一元调用",
+			call: func(client *Client) error {
+				return client.Invoke(context.Background(), "hello_service.Hello", &pb.ApplyHello{Name: "Test"}, &pb.ReplyHello{})
+			},
+		},
+		{
+			name: "流",
+			call: func(client *Client) error {
+				stream, err := client.NewStream(context.Background(), &pb.UserServiceDesc.Streams[0], pb.User_ListUsers_FullMethodName)
+				if err != nil {
+					return err
+				}
+				return stream.RecvMsg(&pb.ReplyUser{})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startMockServer(t, mockDropHandle)
+			defer server.Close()
+
+			client := newTestClient(t, server.Addr().String())
+
+			// 连接断开时返回 Unavailable，而不是 io.EOF 对应的 Unknown
+			err := tt.call(client)
+			assert.Equal(t, codes.Unavailable, status.Code(err), err)
+		})
+	}
+}
+
+func TestClient_ApplyTooLarge(t *testing.T) {
+	server := startMockServer(t, mockHelloLoopHandle)
+	defer server.Close()
+
+	client := newTestClient(t, server.Addr().String(), WithMaxMessageSize(1024))
+
+	large := &pb.ApplyHello{Name: strings.Repeat("x", 4096)}
+
+	// 请求超过客户端大小限制时返回 ResourceExhausted，请求不会被发送
+	err := client.Invoke(context.Background(), "hello_service.Hello", large, &pb.ReplyHello{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), err)
+
+	stream, err := client.NewStream(context.Background(), &pb.UserServiceDesc.Streams[0], pb.User_ListUsers_FullMethodName)
+	require.NoError(t, err)
+	err = stream.SendMsg(large)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), err)
+
+	// 连接仍然可用
+	var reply pb.ReplyHello
+	require.NoError(t, client.Invoke(context.Background(), "hello_service.Hello", &pb.ApplyHello{Name: "Small"}, &reply))
+	assert.Equal(t, "Hello, Small!", reply.Msg)
+}
+
+// mockReverseHelloHandle 先读取 n 个请求，再按相反的顺序返回响应
+func mockReverseHelloHandle(n int) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		defer conn.Close()
+
+		applies := make([]*Apply, 0, n)
+		for i := 0; i < n; i++ {
+			a, err := mockReadApply(conn)
+			if err != nil {
+				return
+			}
+			applies = append(applies, a)
+		}
+
+		for i := len(applies) - 1; i >= 0; i-- {
+			mockWriteHelloReply(conn, applies[i])
+		}
+	}
+}
+
+func TestClient_InvokeConcurrent(t *testing.T) {
+	const n = 20
+
+	server := startMockServer(t, mockReverseHelloHandle(n))
+	defer server.Close()
+
+	client := newTestClient(t, server.Addr().String())
+
+	helloClient := pb.NewHelloClient(client)
+
+	// 响应乱序返回，每个调用仍然应该拿到自己的响应
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("Caller%d", i)
+			resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: name})
+			if assert.NoError(t, err) {
+				assert.Equal(t, "Hello, "+name+"!", resp.Msg)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestClient_InvokeAfterClose(t *testing.T) {
+	server := startMockServer(t, mockHelloHandle)
+	defer server.Close()
+
+	client, err := NewClient("tcp", server.Addr().String(), WithContextDialer(dialTest))
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+
+	err = client.Invoke(context.Background(), "hello_service.Hello", &pb.ApplyHello{Name: "Test"}, &pb.ReplyHello{})
+	assert.ErrorIs(t, err, ErrClientClosed)
+}
+
+// mockDelayHelloHandle 第一个请求的响应在第二个请求之后才返回，模拟第一个调用超时
+func mockDelayHelloHandle(conn net.Conn) {
+	defer conn.Close()
+
+	var applies []*Apply
+	for i := 0; i < 2; i++ {
+		a, err := mockReadApply(conn)
+		if err != nil {
+			return
+		}
+		applies = append(applies, a)
+	}
+
+	mockWriteHelloReply(conn, applies[1])
+	mockWriteHelloReply(conn, applies[0])
+
+	// 等待客户端关闭连接
+	readFrame(conn, DefaultMaxMessageSize)
+}
+
+func TestClient_InvokeContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{
+			name: "超时-DeadlineExceeded",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name: "取消-Canceled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startMockServer(t, mockDelayHelloHandle)
+			defer server.Close()
+
+			client := newTestClient(t, server.Addr().String())
+
+			helloClient := pb.NewHelloClient(client)
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			// 第一个调用在服务端响应前超时或被取消
+			_, err := helloClient.Hello(ctx, &pb.ApplyHello{Name: "First"})
+			assert.ErrorIs(t, err, tt.wantErr)
+
+			// 连接仍然可用，且不会收到第一个调用迟到的响应
+			resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Second"})
+			require.NoError(t, err)
+			assert.Equal(t, "Hello, Second!", resp.Msg)
+		})
+	}
+}
+
+func TestClient_InvokeContextDone(t *testing.T) {
+	server := startMockServer(t, mockHelloHandle)
+	defer server.Close()
+
+	client := newTestClient(t, server.Addr().String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// 已经取消的 ctx 不会发出请求
+	err := client.Invoke(ctx, "hello_service.Hello", &pb.ApplyHello{Name: "Test"}, &pb.ReplyHello{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestClientTransport_SendContext(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctx       func() (context.Context, context.CancelFunc)
+		wantErr   error
+		wantFirst bool // 对端开始读取后是否收到第一个帧
+	}{
+		{
+			name: "写入阻塞时取消-帧在后台写完",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantErr:   context.Canceled,
+			wantFirst: true,
+		},
+		{
+			name: "写入阻塞时超时-没有写入任何数据",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name: "写入前已取消-不写入",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 对端先不读取，写入一直阻塞
+			conn, peerConn := net.Pipe()
+			defer peerConn.Close()
+			tr := newClientTransport(conn, DefaultMaxMessageSize, nil)
+			defer tr.close(errors.New("测试结束"))
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			done := make(chan error, 1)
+			go func() { done <- tr.send(ctx, frameUnary, "json", []byte("first")) }()
+
+			select {
+			case err := <-done:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case <-time.After(time.Second):
+				t.Fatal("ctx 结束后写入没有返回")
+			}
+
+			// 连接没有断开，对端只收到完整的帧
+			require.NoError(t, tr.closeErr())
+			go tr.send(context.Background(), frameUnary, "json", []byte("second"))
+			var got []string
+			for len(got) == 0 || got[len(got)-1] != "second" {
+				f, err := readFrame(peerConn, DefaultMaxMessageSize)
+				require.NoError(t, err)
+				got = append(got, string(f.payload))
+			}
+			if tt.wantFirst {
+				assert.Equal(t, []string{"first", "second"}, got)
+			} else {
+				assert.Equal(t, []string{"second"}, got)
+			}
+		})
+	}
+}
+
+// mockCountServer 记录请求数和断开的连接数的模拟服务器，
+// release 不为 nil 时等待 release 关闭再返回响应
+type mockCountServer struct {
+	net.Listener
+	requests atomic.Int32
+	closed   atomic.Int32
+}
+
+func startMockCountServer(t *testing.T, release <-chan struct{}) *mockCountServer {
+	s := &mockCountServer{}
+	s.Listener = startMockServer(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		var mu sync.Mutex
+		for {
+			a, err := mockReadApply(conn)
+			if err != nil {
+				s.closed.Add(1)
+				return
+			}
+			s.requests.Add(1)
+			go func() {
+				if release != nil {
+					<-release
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				mockWriteHelloReply(conn, a)
+			}()
+		}
+	})
+	return s
+}
+
+// manualResolver 测试用的 resolver，由测试代码推送地址
+type manualResolver struct {
+	scheme     string
+	initial    []string
+	cc         resolver.ClientConn
+	resolveNow atomic.Int32
+}
+
+var manualSchemes atomic.Int32
+
+// newManualResolver 注册一个新的 scheme，Build 时推送 initial
+func newManualResolver(initial ...string) *manualResolver {
+	r := &manualResolver{scheme: fmt.Sprintf("manual%d", manualSchemes.Add(1)), initial: initial}
+	resolver.Register(r)
+	return r
+}
+
+func (r *manualResolver) Scheme() string { return r.scheme }
+
+func (r *manualResolver) Build(target resolver.Target, cc resolver.ClientConn) (resolver.Resolver, error) {
+	r.cc = cc
+	if r.initial != nil {
+		r.update(r.initial...)
+	}
+	return r, nil
+}
+
+func (r *manualResolver) update(addrs ...string) {
+	state := resolver.State{Addresses: make([]resolver.Address, len(addrs))}
+	for i, addr := range addrs {
+		state.Addresses[i] = resolver.Address{Addr: addr}
+	}
+	r.cc.UpdateState(state)
+}
+
+func (r *manualResolver) ResolveNow() { r.resolveNow.Add(1) }
+
+func (r *manualResolver) Close() {}
+
+func TestNewClient_Target(t *testing.T) {
+	server := startMockServer(t, mockHelloLoopHandle)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Addr().String())
+
+	tests := []struct {
+		name    string
+		target  string
+		wantErr bool
+	}{
+		{name: "static", target: "static:///" + server.Addr().String()},
+		{name: "dns", target: "dns:///localhost:" + port},
+		{name: "未注册的scheme-失败", target: "consul:///users", wantErr: true},
+		{name: "static空地址-失败", target: "static:///", wantErr: true},
+		{name: "dns缺少端口-失败", target: "dns:///localhost", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient("tcp", tt.target, WithContextDialer(dialTest))
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, client)
+				return
+			}
+			require.NoError(t, err)
+			defer client.Close()
+
+			ctx := WithCallOptions(context.Background(), WaitForReady(true))
+			ctx, cancel := context.WithTimeout(ctx, time.Second)
+			defer cancel()
+			resp, err := pb.NewHelloClient(client).Hello(ctx, &pb.ApplyHello{Name: "Target"})
+			require.NoError(t, err)
+			assert.Equal(t, "Hello, Target!", resp.Msg)
+		})
+	}
+}
+
+func TestClient_ResolverMultipleAddrs(t *testing.T) {
+	release := make(chan struct{})
+	a := startMockCountServer(t, release)
+	defer a.Close()
+	b := startMockCountServer(t, release)
+	defer b.Close()
+
+	client := newTestClient(t, "static:///"+a.Addr().String()+","+b.Addr().String(), WithBalancer(balancer.P2C()))
+
+	// 每个地址各建立一条连接
+	require.Eventually(t, func() bool {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return len(client.pools) == 2 &&
+			client.pools[a.Addr().String()].State() == connectivity.Ready &&
+			client.pools[b.Addr().String()].State() == connectivity.Ready
+	}, time.Second, 5*time.Millisecond)
+
+	// 进行中的调用分摊到不同的地址
+	helloClient := pb.NewHelloClient(client)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Multi"})
+			assert.NoError(t, err)
+		}()
+		require.Eventually(t, func() bool { return a.requests.Load()+b.requests.Load() == int32(i+1) }, time.Second, 5*time.Millisecond)
+	}
+	assert.Equal(t, int32(2), a.requests.Load())
+	assert.Equal(t, int32(2), b.requests.Load())
+
+	close(release)
+	wg.Wait()
+}
+
+// waitPoolsReady 等待所有地址的连接池都建立连接
+func waitPoolsReady(t *testing.T, client *Client, addrs ...string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		for _, addr := range addrs {
+			if pool, ok := client.pools[addr]; !ok || pool.State() != connectivity.Ready {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestClient_PickFirst(t *testing.T) {
+	// 第一个地址无法连接
+	down := listenTest(t, "127.0.0.1:0")
+	downAddr := down.Addr().String()
+	down.Close()
+	a := startMockCountServer(t, nil)
+	defer a.Close()
+	b := startMockCountServer(t, nil)
+	defer b.Close()
+
+	// 连接池不保持最少连接数，需要时才建立连接
+	client := newTestClient(t, "static:///"+downAddr+","+a.Addr().String()+","+b.Addr().String(), WithPoolSize(0, 1))
+
+	// 跳过连接失败的地址，所有调用都使用第一个可用的地址
+	helloClient := pb.NewHelloClient(client)
+	ctx := WithCallOptions(context.Background(), WaitForReady(true))
+	for i := 0; i < 3; i++ {
+		_, err := helloClient.Hello(ctx, &pb.ApplyHello{Name: "PickFirst"})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(3), a.requests.Load())
+	assert.Equal(t, int32(0), b.requests.Load())
+
+	client.mu.Lock()
+	assert.Equal(t, connectivity.Idle, client.pools[b.Addr().String()].State(), "不需要时不应为后面的地址建立连接")
+	client.mu.Unlock()
+}
+
+func TestClient_RoundRobin(t *testing.T) {
+	a := startMockCountServer(t, nil)
+	defer a.Close()
+	b := startMockCountServer(t, nil)
+	defer b.Close()
+
+	client := newTestClient(t, "static:///"+a.Addr().String()+","+b.Addr().String(), WithBalancer(balancer.RoundRobin()))
+	waitPoolsReady(t, client, a.Addr().String(), b.Addr().String())
+
+	helloClient := pb.NewHelloClient(client)
+	for i := 0; i < 6; i++ {
+		_, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "RoundRobin"})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(3), a.requests.Load())
+	assert.Equal(t, int32(3), b.requests.Load())
+}
+
+func TestClient_ConsistentHash(t *testing.T) {
+	a := startMockCountServer(t, nil)
+	defer a.Close()
+	b := startMockCountServer(t, nil)
+	defer b.Close()
+
+	client := newTestClient(t, "static:///"+a.Addr().String()+","+b.Addr().String(), WithBalancer(balancer.ConsistentHash("user-id")))
+	waitPoolsReady(t, client, a.Addr().String(), b.Addr().String())
+
+	// 相同 user-id 的调用总是发送到同一个地址
+	helloClient := pb.NewHelloClient(client)
+	for i := 0; i < 10; i++ {
+		before := [2]int32{a.requests.Load(), b.requests.Load()}
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("user-id", fmt.Sprintf("user-%d", i)))
+		for j := 0; j < 3; j++ {
+			_, err := helloClient.Hello(ctx, &pb.ApplyHello{Name: "Hash"})
+			require.NoError(t, err)
+		}
+		got := [2]int32{a.requests.Load() - before[0], b.requests.Load() - before[1]}
+		assert.Contains(t, [][2]int32{{3, 0}, {0, 3}}, got)
+	}
+	assert.Positive(t, a.requests.Load())
+	assert.Positive(t, b.requests.Load())
+}
+
+func TestClient_ResolverUpdate(t *testing.T) {
+	release := make(chan struct{})
+	a := startMockCountServer(t, release)
+	defer a.Close()
+	b := startMockCountServer(t, nil)
+	defer b.Close()
+
+	r := newManualResolver(a.Addr().String())
+	client := newTestClient(t, r.scheme+":///users")
+
+	// 地址 a 上有一个进行中的调用
+	helloClient := pb.NewHelloClient(client)
+	done := make(chan error, 1)
+	go func() {
+		ctx := WithCallOptions(context.Background(), WaitForReady(true))
+		_, err := helloClient.Hello(ctx, &pb.ApplyHello{Name: "Draining"})
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return a.requests.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	// 地址变为 b 后，新的调用使用 b
+	r.update(b.Addr().String())
+	resp, err := helloClient.Hello(WithCallOptions(context.Background(), WaitForReady(true)), &pb.ApplyHello{Name: "New"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, New!", resp.Msg)
+	assert.Equal(t, int32(1), a.requests.Load())
+	assert.Equal(t, int32(1), b.requests.Load())
+
+	// a 上进行中的调用不受影响，结束后关闭 a 的连接
+	assert.Equal(t, int32(0), a.closed.Load())
+	close(release)
+	require.NoError(t, <-done)
+	require.Eventually(t, func() bool { return a.closed.Load() == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestClient_ResolverNoAddress(t *testing.T) {
+	server := startMockServer(t, mockHelloLoopHandle)
+	defer server.Close()
+
+	r := newManualResolver()
+	client := newTestClient(t, r.scheme+":///users")
+	assert.Equal(t, connectivity.Connecting, client.GetState())
+
+	// 没有地址时立即失败，并通知 resolver 重新解析
+	r.update()
+	assert.Equal(t, connectivity.TransientFailure, client.GetState())
+	helloClient := pb.NewHelloClient(client)
+	_, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "NoAddress"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.ErrorContains(t, err, errNoAddress.Error())
+	require.Eventually(t, func() bool { return r.resolveNow.Load() > 0 }, time.Second, 5*time.Millisecond)
+
+	// WaitForReady 的调用等到有地址后成功
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(WithCallOptions(context.Background(), WaitForReady(true)), time.Second)
+		defer cancel()
+		_, err := helloClient.Hello(ctx, &pb.ApplyHello{Name: "Later"})
+		done <- err
+	}()
+	r.update(server.Addr().String())
+	require.NoError(t, <-done)
+	assert.Equal(t, connectivity.Ready, client.GetState())
+}
+
+func TestClient_WithContextDialerError(t *testing.T) {
+	dialErr := errors.New("dial failed")
+	_, err := NewClient("tcp", "users.internal:50051", WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return nil, dialErr
+	}))
+	assert.ErrorIs(t, err, dialErr)
+}
@@ -0,0 +1,349 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// Binary 基于反射的紧凑二进制编码，不携带字段名和类型信息，要求收发双方使用相同的类型定义：
+//   - bool 编码为 1 个字节
+//   - 有符号整数使用 zigzag varint，无符号整数使用 varint
+//   - float32/float64 分别编码为 4/8 个字节（小端序）
+//   - string、[]byte、slice、array、map 先编码 varint 长度，再依次编码元素，map 按 key 编码后的字节排序
+//   - 指针先编码 1 个字节表示是否为 nil，非 nil 时再编码指向的值
+//   - struct 按定义顺序编码所有导出字段
+//
+// 不支持 interface、chan、func、complex 等类型
+type Binary struct{}
+
+var errBinaryShortBuffer = errors.New("binary: 数据不完整")
+
+func (Binary) Marshal(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	// 顶层的指针直接编码指向的值，与 Unmarshal 解码到指针指向的值对应
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, errors.New("binary: 不支持编码 nil")
+	}
+	return appendValue(nil, rv)
+}
+
+func (Binary) Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("binary: Unmarshal 需要非 nil 的指针，实际为 %T", v)
+	}
+
+	rest, err := readValue(data, rv.Elem())
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return fmt.Errorf("binary: 数据末尾有 %d 个多余的字节", len(rest))
+	}
+	return nil
+}
+
+func (Binary) Name() string {
+	return "binary"
+}
+
+func appendValue(buf []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(buf, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.AppendUvarint(buf, v.Uint()), nil
+	case reflect.Float32:
+		return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(v.Float()))), nil
+	case reflect.Float64:
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.Float())), nil
+	case reflect.String:
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		return append(buf, v.String()...), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			buf = binary.AppendUvarint(buf, uint64(v.Len()))
+			return append(buf, v.Bytes()...), nil
+		}
+		return appendElems(buf, v)
+	case reflect.Array:
+		return appendElems(buf, v)
+	case reflect.Map:
+		return appendMap(buf, v)
+	case reflect.Pointer:
+		if v.IsNil() {
+			return append(buf, 0), nil
+		}
+		return appendValue(append(buf, 1), v.Elem())
+	case reflect.Struct:
+		var err error
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if buf, err = appendValue(buf, v.Field(i)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("binary: 不支持的类型 %s", v.Type())
+}
+
+func appendElems(buf []byte, v reflect.Value) ([]byte, error) {
+	var err error
+	buf = binary.AppendUvarint(buf, uint64(v.Len()))
+	for i := 0; i < v.Len(); i++ {
+		if buf, err = appendValue(buf, v.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// appendMap 按 key 编码后的字节排序，保证相同的 map 编码结果相同
+func appendMap(buf []byte, v reflect.Value) ([]byte, error) {
+	type entry struct {
+		key, value []byte
+	}
+
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := appendValue(nil, iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		value, err := appendValue(nil, iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	buf = binary.AppendUvarint(buf, uint64(len(entries)))
+	for _, e := range entries {
+		buf = append(buf, e.key...)
+		buf = append(buf, e.value...)
+	}
+	return buf, nil
+}
+
+func readValue(data []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if len(data) < 1 {
+			return nil, errBinaryShortBuffer
+		}
+		v.SetBool(data[0] != 0)
+		return data[1:], nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, errBinaryShortBuffer
+		}
+		if v.OverflowInt(x) {
+			return nil, fmt.Errorf("binary: %d 超出 %s 的范围", x, v.Type())
+		}
+		v.SetInt(x)
+		return data[n:], nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		x, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errBinaryShortBuffer
+		}
+		if v.OverflowUint(x) {
+			return nil, fmt.Errorf("binary: %d 超出 %s 的范围", x, v.Type())
+		}
+		v.SetUint(x)
+		return data[n:], nil
+	case reflect.Float32:
+		if len(data) < 4 {
+			return nil, errBinaryShortBuffer
+		}
+		v.SetFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(data))))
+		return data[4:], nil
+	case reflect.Float64:
+		if len(data) < 8 {
+			return nil, errBinaryShortBuffer
+		}
+		v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(data)))
+		return data[8:], nil
+	case reflect.String:
+		b, rest, err := readBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		v.SetString(string(b))
+		return rest, nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, rest, err := readBytes(data)
+			if err != nil {
+				return nil, err
+			}
+			v.SetBytes(append([]byte(nil), b...))
+			return rest, nil
+		}
+		n, rest, err := readLen(data)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			v.SetZero()
+			return rest, nil
+		}
+		if err := checkCount(n, rest, minSize(v.Type().Elem())); err != nil {
+			return nil, err
+		}
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+		return readElems(rest, v, n)
+	case reflect.Array:
+		n, rest, err := readLen(data)
+		if err != nil {
+			return nil, err
+		}
+		if n != v.Len() {
+			return nil, fmt.Errorf("binary: 数组长度不匹配 %d != %d", n, v.Len())
+		}
+		return readElems(rest, v, n)
+	case reflect.Map:
+		return readMap(data, v)
+	case reflect.Pointer:
+		if len(data) < 1 {
+			return nil, errBinaryShortBuffer
+		}
+		if data[0] == 0 {
+			v.SetZero()
+			return data[1:], nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return readValue(data[1:], v.Elem())
+	case reflect.Struct:
+		var err error
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if data, err = readValue(data, v.Field(i)); err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("binary: 不支持的类型 %s", v.Type())
+}
+
+func readElems(data []byte, v reflect.Value, n int) ([]byte, error) {
+	var err error
+	for i := 0; i < n; i++ {
+		if data, err = readValue(data, v.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func readMap(data []byte, v reflect.Value) ([]byte, error) {
+	n, data, err := readLen(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		v.SetZero()
+		return data, nil
+	}
+
+	t := v.Type()
+	if err := checkCount(n, data, minSize(t.Key())+minSize(t.Elem())); err != nil {
+		return nil, err
+	}
+	m := reflect.MakeMapWithSize(t, n)
+	for i := 0; i < n; i++ {
+		key := reflect.New(t.Key()).Elem()
+		if data, err = readValue(data, key); err != nil {
+			return nil, err
+		}
+		value := reflect.New(t.Elem()).Elem()
+		if data, err = readValue(data, value); err != nil {
+			return nil, err
+		}
+		m.SetMapIndex(key, value)
+	}
+	v.Set(m)
+	return data, nil
+}
+
+// readLen 读取长度前缀，长度不能超过剩余数据的字节数，避免恶意数据导致分配过大的内存
+func readLen(data []byte) (int, []byte, error) {
+	x, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, errBinaryShortBuffer
+	}
+	data = data[n:]
+	if x > uint64(len(data)) {
+		return 0, nil, errBinaryShortBuffer
+	}
+	return int(x), data, nil
+}
+
+// checkCount 检查 n 个编码后至少 size 字节的元素能否放进剩余的数据，
+// 避免恶意数据中很大的元素数量导致按元素类型分配过大的内存
+func checkCount(n int, data []byte, size int) error {
+	if size > 0 && n > len(data)/size {
+		return errBinaryShortBuffer
+	}
+	return nil
+}
+
+// minSize 返回类型 t 的值编码后的最小字节数
+func minSize(t reflect.Type) int {
+	switch t.Kind() {
+	case reflect.Float32:
+		return 4
+	case reflect.Float64:
+		return 8
+	case reflect.Array:
+		// 长度前缀至少 1 个字节
+		return 1 + t.Len()*minSize(t.Elem())
+	case reflect.Struct:
+		size := 0
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				size += minSize(t.Field(i).Type)
+			}
+		}
+		return size
+	}
+	// bool、整数、长度前缀和指针的标记至少 1 个字节
+	return 1
+}
+
+func readBytes(data []byte) ([]byte, []byte, error) {
+	n, data, err := readLen(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data[:n], data[n:], nil
+}
@@ -0,0 +1,53 @@
+// Package codec 定义请求和响应消息体的编解码方式。
+// 编码名称随每一帧发送，服务端按照请求使用的编码解析请求并编码响应
+package codec
+
+import (
+	"strings"
+	"sync"
+)
+
+// Codec 消息体的编解码器，实现必须是并发安全的
+type Codec interface {
+	// Marshal 将 v 编码为字节
+	Marshal(v any) ([]byte, error)
+	// Unmarshal 将 data 解码到 v 中，v 必须是指针
+	Unmarshal(data []byte, v any) error
+	// Name 编码名称，不区分大小写，长度不能超过 255 个字节
+	Name() string
+}
+
+var (
+	mu     sync.RWMutex
+	codecs = make(map[string]Codec)
+)
+
+// Register 注册编解码器，名称相同时后注册的覆盖先注册的。
+// 只应该在 init 函数中调用
+func Register(c Codec) {
+	if c == nil {
+		panic("codec: Register 的 codec 为 nil")
+	}
+
+	name := strings.ToLower(c.Name())
+	if name == "" || len(name) > 255 {
+		panic("codec: 编码名称长度必须在 1 到 255 之间")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	codecs[name] = c
+}
+
+// Get 根据名称获取已注册的编解码器，不存在时返回 nil
+func Get(name string) Codec {
+	mu.RLock()
+	defer mu.RUnlock()
+	return codecs[strings.ToLower(name)]
+}
+
+func init() {
+	Register(JSON{})
+	Register(Binary{})
+	Register(Proto{})
+}
@@ -0,0 +1,244 @@
+//go:build unit
+
+package codec
+
+import (
+	"encoding/binary"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type inner struct {
+	Name string
+	Tags []string
+}
+
+type message struct {
+	Bool    bool
+	Int     int
+	Int8    int8
+	Int64   int64
+	Uint16  uint16
+	Float32 float32
+	Float64 float64
+	String  string
+	Bytes   []byte
+	Array   [3]int32
+	Map     map[string]int64
+	Ptr     *inner
+	NilPtr  *inner
+	Slice   []*inner
+	Nested  inner
+	private int
+}
+
+func newMessage() *message {
+	return &message{
+		Bool:    true,
+		Int:     -42,
+		Int8:    -8,
+		Int64:   1 << 40,
+		Uint16:  65535,
+		Float32: 3.5,
+		Float64: -2.25,
+		String:  "你好, trpc",
+		Bytes:   []byte{0, 1, 2},
+		Array:   [3]int32{1, -2, 3},
+		Map:     map[string]int64{"a": 1, "b": -2},
+		Ptr:     &inner{Name: "ptr", Tags: []string{"x", "y"}},
+		Slice:   []*inner{{Name: "s1"}, nil, {Name: "s2"}},
+		Nested:  inner{Name: "nested"},
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	tests := []struct {
+		name string
+		want Codec
+	}{
+		{name: "json", want: JSON{}},
+		{name: "JSON", want: JSON{}},
+		{name: "binary", want: Binary{}},
+		{name: "proto", want: Proto{}},
+		{name: "unknown", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Get(tt.name))
+		})
+	}
+
+	assert.Panics(t, func() { Register(nil) })
+}
+
+func TestJSON_RoundTrip(t *testing.T) {
+	want := newMessage()
+	data, err := JSON{}.Marshal(want)
+	require.NoError(t, err)
+
+	got := &message{}
+	require.NoError(t, JSON{}.Unmarshal(data, got))
+	assert.Equal(t, want, got)
+}
+
+func TestBinary_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		out  func() any
+	}{
+		{
+			name: "复杂结构体",
+			in:   newMessage(),
+			out:  func() any { return &message{} },
+		},
+		{
+			name: "零值结构体",
+			in:   &message{},
+			out:  func() any { return &message{} },
+		},
+		{
+			name: "基础类型",
+			in:   "hello",
+			out:  func() any { return new(string) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Binary{}.Marshal(tt.in)
+			require.NoError(t, err)
+
+			got := tt.out()
+			require.NoError(t, Binary{}.Unmarshal(data, got))
+			if s, ok := tt.in.(string); ok {
+				assert.Equal(t, s, *got.(*string))
+				return
+			}
+			assert.Equal(t, tt.in, got)
+		})
+	}
+}
+
+func TestBinary_Compact(t *testing.T) {
+	m := &inner{Name: "Tan", Tags: []string{"a"}}
+
+	jsonData, err := JSON{}.Marshal(m)
+	require.NoError(t, err)
+	binData, err := Binary{}.Marshal(m)
+	require.NoError(t, err)
+
+	// 不携带字段名，编码结果比 JSON 更小
+	assert.Less(t, len(binData), len(jsonData))
+	assert.Equal(t, []byte{3, 'T', 'a', 'n', 1, 1, 'a'}, binData)
+}
+
+func TestBinary_Errors(t *testing.T) {
+	data, err := Binary{}.Marshal(newMessage())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		err  func() error
+	}{
+		{
+			name: "不支持的类型",
+			err: func() error {
+				_, err := Binary{}.Marshal(&struct{ C chan int }{})
+				return err
+			},
+		},
+		{
+			name: "编码nil",
+			err: func() error {
+				_, err := Binary{}.Marshal(nil)
+				return err
+			},
+		},
+		{
+			name: "解码到非指针",
+			err: func() error {
+				return Binary{}.Unmarshal(data, message{})
+			},
+		},
+		{
+			name: "数据不完整",
+			err: func() error {
+				return Binary{}.Unmarshal(data[:len(data)-1], &message{})
+			},
+		},
+		{
+			name: "数据有多余字节",
+			err: func() error {
+				return Binary{}.Unmarshal(append(data, 0), &message{})
+			},
+		},
+		{
+			name: "长度超过剩余数据",
+			err: func() error {
+				return Binary{}.Unmarshal([]byte{0xff, 0xff, 0xff, 0x0f}, new([]int))
+			},
+		},
+		{
+			name: "元素数量超过剩余数据能编码的数量",
+			err: func() error {
+				// 4 个元素的 float64 至少需要 32 个字节
+				return Binary{}.Unmarshal([]byte{4, 0, 0, 0, 0, 0, 0, 0, 0}, new([]float64))
+			},
+		},
+		{
+			name: "map元素数量超过剩余数据能编码的数量",
+			err: func() error {
+				return Binary{}.Unmarshal([]byte{2, 1, 0}, new(map[int]float64))
+			},
+		},
+		{
+			name: "整数溢出",
+			err: func() error {
+				big, _ := Binary{}.Marshal(int64(1 << 20))
+				return Binary{}.Unmarshal(big, new(int8))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.err())
+		})
+	}
+}
+
+func TestBinary_LargeCount(t *testing.T) {
+	// 元素数量不超过剩余的字节数，但每个元素编码后至少 513 个字节，解码前应当拒绝而不是分配 32MB 的 slice
+	data := binary.AppendUvarint(nil, 1<<16)
+	data = append(data, make([]byte, 1<<16)...)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	var v [][64]float64
+	err := Binary{}.Unmarshal(data, &v)
+	runtime.ReadMemStats(&after)
+
+	assert.Error(t, err)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+}
+
+func TestProto_RoundTrip(t *testing.T) {
+	want := wrapperspb.String("Hello, Tan!")
+	data, err := Proto{}.Marshal(want)
+	require.NoError(t, err)
+
+	got := &wrapperspb.StringValue{}
+	require.NoError(t, Proto{}.Unmarshal(data, got))
+	assert.True(t, proto.Equal(want, got))
+
+	_, err = Proto{}.Marshal(&inner{})
+	assert.Error(t, err)
+	assert.Error(t, Proto{}.Unmarshal(data, &inner{}))
+}
@@ -0,0 +1,18 @@
+package codec
+
+import "encoding/json"
+
+// JSON 使用 encoding/json 编解码，默认的编码方式
+type JSON struct{}
+
+func (JSON) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSON) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (JSON) Name() string {
+	return "json"
+}
@@ -0,0 +1,31 @@
+package codec
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Proto 使用 protobuf 编解码，要求消息实现 proto.Message，
+// 例如 protoc-gen-go 生成的 grpc/proto 中的类型
+type Proto struct{}
+
+func (Proto) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("proto: %T 没有实现 proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (Proto) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("proto: %T 没有实现 proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func (Proto) Name() string {
+	return "proto"
+}
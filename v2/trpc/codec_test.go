@@ -0,0 +1,110 @@
+//go:build unit
+
+package trpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"v2/api"
+	"v2/pb"
+	"v2/trpc/codec"
+	"v2/trpc/codes"
+	"v2/trpc/status"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// upperJSON 测试用的自定义编码，服务端默认不支持
+type upperJSON struct{}
+
+func (upperJSON) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (upperJSON) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (upperJSON) Name() string                       { return "upper-json" }
+
+func TestCodec(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverOpts []ServerOption
+		clientOpts []ClientOption
+		callOpts   []CallOption
+		wantCode   codes.Code
+	}{
+		{
+			name: "默认JSON编码",
+		},
+		{
+			name:       "客户端使用Binary编码",
+			clientOpts: []ClientOption{WithCodec(codec.Binary{})},
+		},
+		{
+			name:     "单次调用使用Binary编码",
+			callOpts: []CallOption{CallCodec(codec.Binary{})},
+		},
+		{
+			name:       "服务端不支持的编码-失败",
+			clientOpts: []ClientOption{WithCodec(upperJSON{})},
+			wantCode:   codes.Unimplemented,
+		},
+		{
+			name:       "服务端通过CustomCodec支持自定义编码",
+			serverOpts: []ServerOption{CustomCodec(upperJSON{})},
+			clientOpts: []ClientOption{WithCodec(upperJSON{})},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startTestServer(t, &pb.HelloServiceDesc, &serverImpl{}, tt.serverOpts...)
+
+			client := newTestClient(t, server.listener.Addr().String(), tt.clientOpts...)
+
+			ctx := WithCallOptions(context.Background(), tt.callOpts...)
+			resp, err := pb.NewHelloClient(client).Hello(ctx, &pb.ApplyHello{Name: "Tan"})
+			if tt.wantCode != codes.OK {
+				assert.Equal(t, tt.wantCode, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "Hello, Tan!", resp.Msg)
+		})
+	}
+}
+
+// protoService 请求和响应都是 proto.Message 的测试服务
+type protoService interface {
+	Echo(ctx context.Context, apply *wrapperspb.StringValue) (*wrapperspb.StringValue, error)
+}
+
+var protoServiceDesc = api.ServiceDesc{
+	ServiceName: "proto_service",
+	HandlerType: (*protoService)(nil),
+}
+
+type protoServiceImpl struct{}
+
+func (s *protoServiceImpl) Echo(ctx context.Context, apply *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	return wrapperspb.String("Echo: " + apply.GetValue()), nil
+}
+
+func TestCodec_Proto(t *testing.T) {
+	server := startTestServer(t, &protoServiceDesc, &protoServiceImpl{})
+
+	client := newTestClient(t, server.listener.Addr().String(), WithCodec(codec.Proto{}))
+
+	reply := &wrapperspb.StringValue{}
+	err := client.Invoke(context.Background(), "proto_service.Echo", wrapperspb.String("Tan"), reply)
+	require.NoError(t, err)
+	assert.Equal(t, "Echo: Tan", reply.GetValue())
+
+	// 空消息使用 proto 编码后长度为 0，也是合法的请求
+	err = client.Invoke(context.Background(), "proto_service.Echo", &wrapperspb.StringValue{}, reply)
+	require.NoError(t, err)
+	assert.Equal(t, "Echo: ", reply.GetValue())
+
+	// 非 proto.Message 的参数无法使用 proto 编码
+	err = client.Invoke(context.Background(), "proto_service.Echo", &pb.ApplyHello{Name: "Tan"}, reply)
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
@@ -0,0 +1,70 @@
+// Package codes 定义 trpc 使用的错误码，取值与 gRPC 保持一致
+package codes
+
+import "strconv"
+
+type Code uint32
+
+const (
+	// OK 调用成功
+	OK Code = 0
+	// Canceled 调用被调用方取消
+	Canceled Code = 1
+	// Unknown 未知错误，服务方法返回的普通 error 会被转换为该错误码
+	Unknown Code = 2
+	// InvalidArgument 请求参数不合法
+	InvalidArgument Code = 3
+	// DeadlineExceeded 调用在完成前超时
+	DeadlineExceeded Code = 4
+	// NotFound 请求的资源不存在
+	NotFound Code = 5
+	// AlreadyExists 要创建的资源已经存在
+	AlreadyExists Code = 6
+	// PermissionDenied 没有权限执行该操作
+	PermissionDenied Code = 7
+	// ResourceExhausted 资源耗尽
+	ResourceExhausted Code = 8
+	// FailedPrecondition 系统状态不满足执行条件
+	FailedPrecondition Code = 9
+	// Aborted 操作被中止
+	Aborted Code = 10
+	// OutOfRange 操作超出有效范围
+	OutOfRange Code = 11
+	// Unimplemented 服务或方法未实现
+	Unimplemented Code = 12
+	// Internal 内部错误
+	Internal Code = 13
+	// Unavailable 服务当前不可用
+	Unavailable Code = 14
+	// DataLoss 数据丢失或损坏
+	DataLoss Code = 15
+	// Unauthenticated 缺少有效的认证信息
+	Unauthenticated Code = 16
+)
+
+var codeNames = map[Code]string{
+	OK:                 "OK",
+	Canceled:           "Canceled",
+	Unknown:            "Unknown",
+	InvalidArgument:    "InvalidArgument",
+	DeadlineExceeded:   "DeadlineExceeded",
+	NotFound:           "NotFound",
+	AlreadyExists:      "AlreadyExists",
+	PermissionDenied:   "PermissionDenied",
+	ResourceExhausted:  "ResourceExhausted",
+	FailedPrecondition: "FailedPrecondition",
+	Aborted:            "Aborted",
+	OutOfRange:         "OutOfRange",
+	Unimplemented:      "Unimplemented",
+	Internal:           "Internal",
+	Unavailable:        "Unavailable",
+	DataLoss:           "DataLoss",
+	Unauthenticated:    "Unauthenticated",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return "Code(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
@@ -0,0 +1,34 @@
+// Package connectivity 定义客户端的连接状态，取值与 gRPC 保持一致
+package connectivity
+
+import "strconv"
+
+type State int
+
+const (
+	// Idle 没有连接，也没有在建立连接，下一次调用时建立连接
+	Idle State = iota
+	// Connecting 正在建立连接
+	Connecting
+	// Ready 至少有一条可用的连接
+	Ready
+	// TransientFailure 建立连接失败，正在等待重试
+	TransientFailure
+	// Shutdown 客户端已关闭
+	Shutdown
+)
+
+var stateNames = map[State]string{
+	Idle:             "IDLE",
+	Connecting:       "CONNECTING",
+	Ready:            "READY",
+	TransientFailure: "TRANSIENT_FAILURE",
+	Shutdown:         "SHUTDOWN",
+}
+
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return "State(" + strconv.Itoa(int(s)) + ")"
+}
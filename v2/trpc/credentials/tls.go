@@ -0,0 +1,147 @@
+// Package credentials 创建服务端和客户端使用的 TLS 配置，
+// 证书和私钥从文件加载，文件更新后新建立的连接自动使用新的证书，不需要重启服务
+package credentials
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ServerTLSConfig 创建服务端的 TLS 配置，证书和私钥更新后自动重新加载。
+// clientCAFile 不为空时要求客户端提供证书，并使用其中的 CA 验证（mTLS），
+// 服务方法可以通过 peer.FromContext 获取客户端经过验证的证书
+func ServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+	if clientCAFile != "" {
+		pool, err := LoadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// ClientTLSConfig 创建客户端的 TLS 配置。caFile 为空时使用系统的根证书验证服务端；
+// certFile 和 keyFile 不为空时在 mTLS 中提供客户端证书，证书更新后自动重新加载。
+// 服务端名称默认为目标地址中的主机名
+func ClientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pool, err := LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		r, err := NewCertReloader(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = r.GetClientCertificate
+	}
+	return cfg, nil
+}
+
+// LoadCertPool 从 PEM 文件中加载 CA 证书
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("credentials: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("credentials: %s 中没有有效的证书", caFile)
+	}
+	return pool, nil
+}
+
+// CertReloader 从文件加载证书和私钥，每次握手时检查文件是否变化，变化时重新加载。
+// 重新加载失败时（例如证书和私钥只更新了一个）继续使用之前的证书，下一次握手时重试
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	certStat fileStat
+	keyStat  fileStat
+}
+
+// fileStat 用于判断文件是否变化
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewCertReloader 加载证书和私钥，加载失败时返回错误
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("credentials: 证书和私钥文件不能为空")
+	}
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load 文件变化时重新加载，返回当前的证书
+func (r *CertReloader) load() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	certStat, err1 := statFile(r.certFile)
+	keyStat, err2 := statFile(r.keyFile)
+	if err := errors.Join(err1, err2); err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("credentials: %w", err)
+	}
+	if r.cert != nil && certStat == r.certStat && keyStat == r.keyStat {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("credentials: 加载证书失败: %w", err)
+	}
+	r.cert, r.certStat, r.keyStat = &cert, certStat, keyStat
+	return r.cert, nil
+}
+
+// GetCertificate 用作服务端的 tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.load()
+}
+
+// GetClientCertificate 用作客户端的 tls.Config.GetClientCertificate
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.load()
+}
+
+func statFile(name string) (fileStat, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return fileStat{}, err
+	}
+	return fileStat{modTime: info.ModTime(), size: info.Size()}, nil
+}
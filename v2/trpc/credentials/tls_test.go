@@ -0,0 +1,172 @@
+//go:build unit
+
+package credentials
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"v2/trpc/internal/testcert"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// leafOf 解析证书链中的第一个证书
+func leafOf(t *testing.T, cert *tls.Certificate) *x509.Certificate {
+	t.Helper()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf
+}
+
+// touch 修改文件的修改时间，保证文件内容大小不变时也能检测到变化
+func touch(t *testing.T, path string, offset time.Duration) {
+	t.Helper()
+	mtime := time.Now().Add(offset)
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func TestLoadCertPool(t *testing.T) {
+	dir := t.TempDir()
+	ca := testcert.NewCA(t, "test-ca")
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "有效的CA证书", path: testcert.WriteFile(t, dir, "ca.pem", ca.PEM)},
+		{name: "文件不存在", path: filepath.Join(dir, "missing.pem"), wantErr: "no such file"},
+		{name: "没有有效的证书", path: testcert.WriteFile(t, dir, "bad.pem", []byte("not a cert")), wantErr: "没有有效的证书"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := LoadCertPool(tt.path)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, pool)
+		})
+	}
+}
+
+func TestNewCertReloader_Error(t *testing.T) {
+	dir := t.TempDir()
+	ca := testcert.NewCA(t, "test-ca")
+	certPEM, _ := ca.Issue(t, "server", "127.0.0.1")
+	_, otherKeyPEM := ca.Issue(t, "other", "127.0.0.1")
+	certFile := testcert.WriteFile(t, dir, "cert.pem", certPEM)
+	otherKeyFile := testcert.WriteFile(t, dir, "other-key.pem", otherKeyPEM)
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		wantErr  string
+	}{
+		{name: "文件名为空", certFile: certFile, wantErr: "不能为空"},
+		{name: "文件不存在", certFile: certFile, keyFile: filepath.Join(dir, "missing.pem"), wantErr: "no such file"},
+		{name: "证书和私钥不匹配", certFile: certFile, keyFile: otherKeyFile, wantErr: "加载证书失败"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCertReloader(tt.certFile, tt.keyFile)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestCertReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	ca := testcert.NewCA(t, "test-ca")
+	certPEM, keyPEM := ca.Issue(t, "server-v1", "127.0.0.1")
+	certFile := testcert.WriteFile(t, dir, "cert.pem", certPEM)
+	keyFile := testcert.WriteFile(t, dir, "key.pem", keyPEM)
+
+	r, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "server-v1", leafOf(t, cert).Subject.CommonName)
+
+	// 文件没有变化时不重新加载
+	same, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Same(t, cert, same)
+
+	// 只更新了证书，与私钥不匹配时继续使用之前的证书
+	certPEM, keyPEM = ca.Issue(t, "server-v2", "127.0.0.1")
+	testcert.WriteFile(t, dir, "cert.pem", certPEM)
+	touch(t, certFile, time.Second)
+	cert, err = r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "server-v1", leafOf(t, cert).Subject.CommonName)
+
+	// 私钥也更新后使用新的证书
+	testcert.WriteFile(t, dir, "key.pem", keyPEM)
+	touch(t, keyFile, time.Second)
+	cert, err = r.GetClientCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "server-v2", leafOf(t, cert).Subject.CommonName)
+
+	// 文件被删除时继续使用之前的证书
+	require.NoError(t, os.Remove(certFile))
+	cert, err = r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "server-v2", leafOf(t, cert).Subject.CommonName)
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := testcert.NewCA(t, "test-ca")
+	certPEM, keyPEM := ca.Issue(t, "server", "127.0.0.1")
+	certFile := testcert.WriteFile(t, dir, "cert.pem", certPEM)
+	keyFile := testcert.WriteFile(t, dir, "key.pem", keyPEM)
+	caFile := testcert.WriteFile(t, dir, "ca.pem", ca.PEM)
+
+	cfg, err := ServerTLSConfig(certFile, keyFile, "")
+	require.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, cfg.ClientAuth)
+	assert.NotNil(t, cfg.GetCertificate)
+
+	// 设置客户端 CA 时要求并验证客户端证书
+	cfg, err = ServerTLSConfig(certFile, keyFile, caFile)
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, cfg.ClientAuth)
+	assert.NotNil(t, cfg.ClientCAs)
+
+	_, err = ServerTLSConfig(certFile, keyFile, filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := testcert.NewCA(t, "test-ca")
+	certPEM, keyPEM := ca.Issue(t, "client", "127.0.0.1")
+	certFile := testcert.WriteFile(t, dir, "cert.pem", certPEM)
+	keyFile := testcert.WriteFile(t, dir, "key.pem", keyPEM)
+	caFile := testcert.WriteFile(t, dir, "ca.pem", ca.PEM)
+
+	// 不设置 CA 时使用系统根证书
+	cfg, err := ClientTLSConfig("", "", "")
+	require.NoError(t, err)
+	assert.Nil(t, cfg.RootCAs)
+	assert.Nil(t, cfg.GetClientCertificate)
+
+	cfg, err = ClientTLSConfig(caFile, certFile, keyFile)
+	require.NoError(t, err)
+	assert.NotNil(t, cfg.RootCAs)
+	assert.NotNil(t, cfg.GetClientCertificate)
+
+	// 只设置了证书或私钥中的一个
+	_, err = ClientTLSConfig(caFile, certFile, "")
+	assert.ErrorContains(t, err, "不能为空")
+}
@@ -0,0 +1,52 @@
+//go:build unit
+
+package trpc_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"v2/pb"
+	"v2/trpc"
+	"v2/trpc/trpctest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dialerHelloImpl 测试用服务实现
+type dialerHelloImpl struct{}
+
+func (s *dialerHelloImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	return &pb.ReplyHello{Msg: "Hello, " + apply.Name + "!"}, nil
+}
+
+func TestClient_WithContextDialer(t *testing.T) {
+	lis := trpctest.NewListener()
+	server := trpc.NewServerWithoutListener()
+	require.NoError(t, pb.RegisterHelloServer(server, &dialerHelloImpl{}))
+	go server.Serve(lis)
+	defer server.Stop()
+
+	var dialed []string
+	var mu sync.Mutex
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		mu.Lock()
+		dialed = append(dialed, addr)
+		mu.Unlock()
+		return lis.DialContext(ctx, addr)
+	}
+
+	// 自定义的传输不使用 network，地址原样传给 dialer
+	client, err := trpc.NewClient("tcp", "users.internal:50051", trpc.WithContextDialer(dialer))
+	require.NoError(t, err)
+	defer client.Close()
+
+	resp, err := pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "Pipe"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Pipe!", resp.Msg)
+	mu.Lock()
+	assert.Equal(t, []string{"users.internal:50051"}, dialed)
+	mu.Unlock()
+}
@@ -1,35 +1,47 @@
 package trpc
 
 import (
-	"encoding/json"
+	"encoding/binary"
+	"errors"
+	"sort"
 	"strings"
+	"time"
+	"v2/trpc/codec"
+	"v2/trpc/codes"
+	"v2/trpc/metadata"
+	"v2/trpc/status"
 )
 
+// 帧的 payload 由协议头和消息体两部分组成：协议头（Apply/Reply 中除 Args/Data 以外的字段）
+// 使用固定的二进制格式编码，消息体是使用 codec 编码后的请求参数或响应，直接拼接在协议头之后，
+// 因此消息体不会被二次编码
+//
+//	Apply: seq(uvarint) service(string) method(string) timeout(varint) metadata(md) | args
+//	Reply: seq(uvarint) code(uvarint) message(string) header(md) trailer(md) | data
+//
+// 其中 string 为 uvarint 长度 + 字节，md 为 uvarint 个数 + 按 key 排序的 key(string) value(string)
+
+var errShortPayload = errors.New("协议头不完整")
+
 type Apply struct {
+	Seq         uint64 // 请求序号，用于将响应与请求对应
 	ServiceName string
 	MethodName  string
-	Args        []byte
+	Args        []byte        // 使用 codec 编码后的参数
+	Timeout     time.Duration // 调用剩余的超时时间，0 表示没有超时
+	Metadata    metadata.MD   // 客户端随调用发送的元数据
 }
 
-func NewApply(method string, args any) []byte {
-	names := strings.Split(method, ".")
-	if len(names) != 2 {
-		panic("method must be service.method")
-	}
-
-	serviceName := names[0]
-	if serviceName == "" {
-		panic("serviceName is empty")
-	}
-
-	methodName := names[1]
-	if methodName == "" {
-		panic("methodName is empty")
+// NewApply 解析 method 并使用 c 编码参数，method 格式不正确时返回 codes.InvalidArgument
+func NewApply(method string, args any, c codec.Codec) (*Apply, error) {
+	serviceName, methodName, err := splitMethod(method)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "方法名格式错误: %v", err)
 	}
 
-	argsData, err := json.Marshal(args)
+	argsData, err := c.Marshal(args)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	apply := &Apply{
@@ -37,15 +49,195 @@ func NewApply(method string, args any) []byte {
 		MethodName:  methodName,
 		Args:        argsData,
 	}
+	return apply, nil
+}
 
-	data, err := json.Marshal(apply)
-	if err != nil {
-		panic(err)
+// splitMethod 将 service.method 格式的 method 拆分为服务名称和方法名称
+func splitMethod(method string) (string, string, error) {
+	names := strings.Split(method, ".")
+	if len(names) != 2 {
+		return "", "", errors.New("method must be service.method")
 	}
+	if names[0] == "" {
+		return "", "", errors.New("serviceName is empty")
+	}
+	if names[1] == "" {
+		return "", "", errors.New("methodName is empty")
+	}
+	return names[0], names[1], nil
+}
+
+func (a *Apply) Marshal() []byte {
+	buf := binary.AppendUvarint(nil, a.Seq)
+	buf = appendString(buf, a.ServiceName)
+	buf = appendString(buf, a.MethodName)
+	buf = binary.AppendVarint(buf, int64(a.Timeout))
+	buf = appendMetadata(buf, a.Metadata)
+	return append(buf, a.Args...)
+}
 
-	return data
+func UnmarshalApply(data []byte) (*Apply, error) {
+	var (
+		a       Apply
+		timeout int64
+		err     error
+	)
+	if a.Seq, data, err = readUvarint(data); err != nil {
+		return nil, err
+	}
+	if a.ServiceName, data, err = readString(data); err != nil {
+		return nil, err
+	}
+	if a.MethodName, data, err = readString(data); err != nil {
+		return nil, err
+	}
+	if timeout, data, err = readVarint(data); err != nil {
+		return nil, err
+	}
+	if a.Metadata, data, err = readMetadata(data); err != nil {
+		return nil, err
+	}
+	a.Timeout = time.Duration(timeout)
+	a.Args = data
+	return &a, nil
 }
 
 type Reply struct {
-	Data []byte
+	Seq     uint64      // 对应请求的序号
+	Code    codes.Code  // 错误码，codes.OK 表示调用成功
+	Message string      // 错误信息
+	Header  metadata.MD // 服务端设置的响应头
+	Trailer metadata.MD // 服务端设置的响应尾
+	Data    []byte      // 使用 codec 编码后的响应
+}
+
+// NewReply 使用 c 编码响应
+func NewReply(reply any, c codec.Codec) (*Reply, error) {
+	replyData, err := c.Marshal(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Reply{
+		Data: replyData,
+	}
+	return r, nil
+}
+
+// NewErrorReply 将调用失败的 Status 转换为响应
+func NewErrorReply(st *status.Status) *Reply {
+	r := &Reply{
+		Code:    st.Code(),
+		Message: st.Message(),
+	}
+	return r
+}
+
+func (r *Reply) Marshal() []byte {
+	buf := binary.AppendUvarint(nil, r.Seq)
+	buf = binary.AppendUvarint(buf, uint64(r.Code))
+	buf = appendString(buf, r.Message)
+	buf = appendMetadata(buf, r.Header)
+	buf = appendMetadata(buf, r.Trailer)
+	return append(buf, r.Data...)
+}
+
+func UnmarshalReply(data []byte) (*Reply, error) {
+	var (
+		r    Reply
+		code uint64
+		err  error
+	)
+	if r.Seq, data, err = readUvarint(data); err != nil {
+		return nil, err
+	}
+	if code, data, err = readUvarint(data); err != nil {
+		return nil, err
+	}
+	if r.Message, data, err = readString(data); err != nil {
+		return nil, err
+	}
+	if r.Header, data, err = readMetadata(data); err != nil {
+		return nil, err
+	}
+	if r.Trailer, data, err = readMetadata(data); err != nil {
+		return nil, err
+	}
+	r.Code = codes.Code(code)
+	r.Data = data
+	return &r, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendMetadata(buf []byte, md metadata.MD) []byte {
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf = binary.AppendUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		buf = appendString(buf, k)
+		buf = appendString(buf, md[k])
+	}
+	return buf
+}
+
+func readUvarint(data []byte) (uint64, []byte, error) {
+	x, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, errShortPayload
+	}
+	return x, data[n:], nil
+}
+
+func readVarint(data []byte) (int64, []byte, error) {
+	x, n := binary.Varint(data)
+	if n <= 0 {
+		return 0, nil, errShortPayload
+	}
+	return x, data[n:], nil
+}
+
+func readString(data []byte) (string, []byte, error) {
+	n, data, err := readUvarint(data)
+	if err != nil {
+		return "", nil, err
+	}
+	if n > uint64(len(data)) {
+		return "", nil, errShortPayload
+	}
+	return string(data[:n]), data[n:], nil
+}
+
+func readMetadata(data []byte) (metadata.MD, []byte, error) {
+	n, data, err := readUvarint(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n == 0 {
+		return nil, data, nil
+	}
+	// 每个键值对至少占 2 个字节，避免恶意数据导致分配过大的内存
+	if n > uint64(len(data))/2 {
+		return nil, nil, errShortPayload
+	}
+
+	md := make(metadata.MD, n)
+	for i := uint64(0); i < n; i++ {
+		var k, v string
+		if k, data, err = readString(data); err != nil {
+			return nil, nil, err
+		}
+		if v, data, err = readString(data); err != nil {
+			return nil, nil, err
+		}
+		md[k] = v
+	}
+	return md, data, nil
 }
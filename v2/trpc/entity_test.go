@@ -3,11 +3,16 @@
 package trpc
 
 import (
-	"encoding/json"
 	"testing"
+	"time"
 	"v2/pb"
+	"v2/trpc/codec"
+	"v2/trpc/codes"
+	"v2/trpc/metadata"
+	"v2/trpc/status"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewApply(t *testing.T) {
@@ -79,75 +84,181 @@ func TestNewApply(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			data, _ := json.Marshal(tt.want)
-			assert.Equalf(t, data, NewApply(tt.args.method, tt.args.args), "NewApply(%v, %v)", tt.args.method, tt.args.args)
+			apply, err := NewApply(tt.args.method, tt.args.args, codec.JSON{})
+			assert.NoError(t, err)
+			assert.Equalf(t, tt.want, apply, "NewApply(%v, %v)", tt.args.method, tt.args.args)
 		})
 	}
 }
 
-// TestNewApply_Panic 测试应该触发panic的场景
-func TestNewApply_Panic(t *testing.T) {
+// TestNewApply_InvalidMethod 测试 method 格式错误时返回 codes.InvalidArgument 的场景
+func TestNewApply_InvalidMethod(t *testing.T) {
 	tests := []struct {
-		name     string
-		method   string
-		args     any
-		panicMsg string
+		name   string
+		method string
+		args   any
 	}{
 		{
-			name:     "异常-method为空字符串",
-			method:   "",
-			args:     &pb.ApplyHello{Name: "Test"},
-			panicMsg: "method must be service.method",
+			name:   "异常-method为空字符串",
+			method: "",
+			args:   &pb.ApplyHello{Name: "Test"},
+		},
+		{
+			name:   "异常-method只有服务名（无点号）",
+			method: "HelloService",
+			args:   &pb.ApplyHello{Name: "Test"},
+		},
+		{
+			name:   "异常-method只有方法名（无点号）",
+			method: "Hello",
+			args:   &pb.ApplyHello{Name: "Test"},
+		},
+		{
+			name:   "异常-method有多个点号",
+			method: "Service.Sub.Method",
+			args:   &pb.ApplyHello{Name: "Test"},
+		},
+		{
+			name:   "异常-method格式为Service.",
+			method: "HelloService.",
+			args:   &pb.ApplyHello{Name: "Test"},
 		},
 		{
-			name:     "异常-method只有服务名（无点号）",
-			method:   "HelloService",
-			args:     &pb.ApplyHello{Name: "Test"},
-			panicMsg: "method must be service.method",
+			name:   "异常-method格式为.Method",
+			method: ".Hello",
+			args:   &pb.ApplyHello{Name: "Test"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apply, err := NewApply(tt.method, tt.args, codec.JSON{})
+			assert.Nil(t, apply)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+// TestNewApply_Error 参数无法序列化时返回错误
+func TestNewApply_Error(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  any
+		codec codec.Codec
+	}{
+		{
+			name:  "异常-args无法序列化（channel）",
+			args:  make(chan int),
+			codec: codec.JSON{},
 		},
 		{
-			name:     "异常-method只有方法名（无点号）",
-			method:   "Hello",
-			args:     &pb.ApplyHello{Name: "Test"},
-			panicMsg: "method must be service.method",
+			name:  "异常-args无法序列化（function）",
+			args:  func() {},
+			codec: codec.JSON{},
 		},
 		{
-			name:     "异常-method有多个点号",
-			method:   "Service.Sub.Method",
-			args:     &pb.ApplyHello{Name: "Test"},
-			panicMsg: "method must be service.method",
+			name:  "异常-args不是proto.Message",
+			args:  &pb.ApplyHello{Name: "Test"},
+			codec: codec.Proto{},
 		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewApply("Service.Method", tt.args, tt.codec)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestApply_Marshal(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply *Apply
+	}{
 		{
-			name:     "异常-method格式为Service.",
-			method:   "HelloService.",
-			args:     &pb.ApplyHello{Name: "Test"},
-			panicMsg: "method must be service.method",
+			name: "完整的请求",
+			apply: &Apply{
+				Seq:         2,
+				ServiceName: "hello_service",
+				MethodName:  "Hello",
+				Args:        []byte(`{"Name":"Tan"}`),
+				Timeout:     time.Second,
+				Metadata:    metadata.MD{"trace-id": "abc", "caller": "test"},
+			},
 		},
 		{
-			name:     "异常-method格式为.Method",
-			method:   ".Hello",
-			args:     &pb.ApplyHello{Name: "Test"},
-			panicMsg: "method must be service.method",
+			name: "没有参数和元数据",
+			apply: &Apply{
+				Seq:         1,
+				ServiceName: "hello_service",
+				MethodName:  "Hello",
+				Args:        []byte{},
+			},
 		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.apply.Marshal()
+			got, err := UnmarshalApply(data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.apply, got)
+
+			// 协议头不完整
+			_, err = UnmarshalApply(data[:len(data)-len(tt.apply.Args)-1])
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewReply(t *testing.T) {
+	r, err := NewReply(&pb.ReplyHello{Msg: "Hello, Tan!"}, codec.JSON{})
+	assert.NoError(t, err)
+	assert.Equal(t, &Reply{Data: []byte(`{"Msg":"Hello, Tan!"}`)}, r)
+
+	_, err = NewReply(make(chan int), codec.JSON{})
+	assert.Error(t, err)
+}
+
+func TestNewErrorReply(t *testing.T) {
+	r := NewErrorReply(status.New(codes.NotFound, "用户不存在"))
+	assert.Equal(t, &Reply{Code: codes.NotFound, Message: "用户不存在"}, r)
+}
+
+func TestReply_Marshal(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply *Reply
+	}{
 		{
-			name:     "异常-args无法序列化（channel）",
-			method:   "Service.Method",
-			args:     make(chan int),
-			panicMsg: "",
+			name: "成功的响应",
+			reply: &Reply{
+				Seq:     7,
+				Header:  metadata.MD{"h": "1"},
+				Trailer: metadata.MD{"t": "2"},
+				Data:    []byte(`{"Msg":"Hello, Tan!"}`),
+			},
 		},
 		{
-			name:     "异常-args无法序列化（function）",
-			method:   "Service.Method",
-			args:     func() {},
-			panicMsg: "",
+			name: "失败的响应",
+			reply: &Reply{
+				Seq:     3,
+				Code:    codes.NotFound,
+				Message: "用户不存在",
+				Data:    []byte{},
+			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Panicsf(t, func() {
-				NewApply(tt.method, tt.args)
-			}, "NewApply(%v, %v) should panic", tt.method, tt.args)
+			got, err := UnmarshalReply(tt.reply.Marshal())
+			require.NoError(t, err)
+			assert.Equal(t, tt.reply, got)
 		})
 	}
+
+	_, err := UnmarshalReply([]byte{0x80})
+	assert.Error(t, err)
 }
@@ -0,0 +1,128 @@
+package trpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// 帧格式（大端序）：
+//
+//	+---------+---------+--------+-----------+----------------+------------+---------+
+//	|  magic  | version | flags  | codec len | payload length | codec name | payload |
+//	| 2 bytes | 1 byte  | 1 byte |  1 byte   |    4 bytes     |  M bytes   | N bytes |
+//	+---------+---------+--------+-----------+----------------+------------+---------+
+//
+// 每个 Apply/Reply 都被编码为一个完整的帧，读取方先读定长消息头，
+// 再按照 codec len 和 payload length 读取编码名称和完整的消息体，从而解决 TCP 粘包/半包问题。
+// 编码名称指明了消息体中请求参数或响应所使用的 codec，flags 为帧的类型
+const (
+	frameMagic      uint16 = 0x5452 // "TR"
+	frameVersion    uint8  = 1
+	frameHeaderSize        = 9
+
+	// DefaultMaxMessageSize 默认允许的最大消息体大小（4MB）
+	DefaultMaxMessageSize = 4 << 20
+)
+
+// 帧类型，保存在帧头的 flags 中。
+// 一元调用只有一个请求帧和一个响应帧；流式调用由客户端打开，服务端结束，
+// 同一连接上的多个流通过流 ID（即打开流的 Apply 的 Seq）区分
+const (
+	frameUnary              uint8 = iota // 一元调用的请求或响应，payload 为 Apply/Reply
+	frameStreamOpen                      // 客户端打开流，payload 为 Apply，Args 为空
+	frameStreamHeader                    // 服务端发送响应头，payload 为 Reply，只有 Seq 和 Header
+	frameStreamMessage                   // 流中的一条消息，payload 为 streamID(uvarint) + codec 编码的消息
+	frameStreamCloseSend                 // 客户端不再发送消息，payload 为 streamID(uvarint)
+	frameStreamEnd                       // 服务端结束流，payload 为 Reply，没有 Data
+	frameStreamCancel                    // 客户端取消流，payload 为 streamID(uvarint)
+	frameStreamWindowUpdate              // 接收方归还流量控制额度，payload 为 streamID(uvarint) + 字节数(uvarint)
+)
+
+var (
+	ErrInvalidMagic       = errors.New("非法的帧魔数")
+	ErrUnsupportedVersion = errors.New("不支持的协议版本")
+	ErrMessageTooLarge    = errors.New("消息体超过最大限制")
+)
+
+// frame 一个完整的协议帧
+type frame struct {
+	flags   uint8
+	codec   string
+	payload []byte
+}
+
+// checkFrame 检查帧能否编码，不能编码时不写入任何数据
+func checkFrame(codecName string, payload []byte, maxSize int) error {
+	if len(payload) > maxSize {
+		return fmt.Errorf("%w: %d > %d", ErrMessageTooLarge, len(payload), maxSize)
+	}
+	if len(codecName) > math.MaxUint8 {
+		return fmt.Errorf("编码名称过长: %s", codecName)
+	}
+	return nil
+}
+
+// writeFrame 将 payload 编码为一个完整的帧写入 w，处理部分写入的情况
+func writeFrame(w io.Writer, flags uint8, codecName string, payload []byte, maxSize int) error {
+	if err := checkFrame(codecName, payload, maxSize); err != nil {
+		return err
+	}
+
+	buf := make([]byte, frameHeaderSize+len(codecName)+len(payload))
+	binary.BigEndian.PutUint16(buf[0:2], frameMagic)
+	buf[2] = frameVersion
+	buf[3] = flags
+	buf[4] = uint8(len(codecName))
+	binary.BigEndian.PutUint32(buf[5:9], uint32(len(payload)))
+	copy(buf[frameHeaderSize:], codecName)
+	copy(buf[frameHeaderSize+len(codecName):], payload)
+
+	for len(buf) > 0 {
+		n, err := w.Write(buf)
+		if err != nil {
+			return err
+		}
+		buf = buf[n:]
+	}
+	return nil
+}
+
+// readFrame 从 r 中读取一个完整的帧，处理部分读取的情况
+func readFrame(r io.Reader, maxSize int) (*frame, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	if magic := binary.BigEndian.Uint16(header[0:2]); magic != frameMagic {
+		return nil, fmt.Errorf("%w: %#x", ErrInvalidMagic, magic)
+	}
+
+	if version := header[2]; version != frameVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	length := binary.BigEndian.Uint32(header[5:9])
+	if uint64(length) > uint64(maxSize) {
+		return nil, fmt.Errorf("%w: %d > %d", ErrMessageTooLarge, length, maxSize)
+	}
+
+	codecLen := int(header[4])
+	body := make([]byte, codecLen+int(length))
+	if _, err := io.ReadFull(r, body); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	f := &frame{
+		flags:   header[3],
+		codec:   string(body[:codecLen]),
+		payload: body[codecLen:],
+	}
+	return f, nil
+}
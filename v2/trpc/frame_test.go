@@ -0,0 +1,170 @@
+//go:build unit
+
+package trpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// oneByteWriter 每次只写入一个字节，模拟部分写入
+type oneByteWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *oneByteWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return w.buf.Write(p[:1])
+}
+
+func TestFrame_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   uint8
+		codec   string
+		payload []byte
+	}{
+		{
+			name:    "普通消息",
+			codec:   "json",
+			payload: []byte(`{"Name":"Tan"}`),
+		},
+		{
+			name:    "空消息体",
+			codec:   "proto",
+			payload: []byte{},
+		},
+		{
+			name:    "没有编码名称",
+			payload: []byte("data"),
+		},
+		{
+			name:    "超过1024字节的大消息",
+			flags:   1,
+			codec:   "binary",
+			payload: []byte(strings.Repeat("a", 64*1024)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &oneByteWriter{}
+			require.NoError(t, writeFrame(w, tt.flags, tt.codec, tt.payload, DefaultMaxMessageSize))
+
+			// 每次只读取一个字节，模拟半包
+			f, err := readFrame(iotest.OneByteReader(&w.buf), DefaultMaxMessageSize)
+			require.NoError(t, err)
+			assert.Equal(t, tt.flags, f.flags)
+			assert.Equal(t, tt.codec, f.codec)
+			assert.Equal(t, tt.payload, f.payload)
+		})
+	}
+}
+
+func TestFrame_MultipleInOneStream(t *testing.T) {
+	// 多个帧连续写入同一个流（粘包），应该能够逐个读出
+	var buf bytes.Buffer
+	payloads := [][]byte{[]byte("first"), []byte("second"), []byte("third")}
+	for _, p := range payloads {
+		require.NoError(t, writeFrame(&buf, 0, "json", p, DefaultMaxMessageSize))
+	}
+
+	for _, want := range payloads {
+		f, err := readFrame(&buf, DefaultMaxMessageSize)
+		require.NoError(t, err)
+		assert.Equal(t, want, f.payload)
+	}
+
+	_, err := readFrame(&buf, DefaultMaxMessageSize)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestFrame_Errors(t *testing.T) {
+	valid := func() []byte {
+		var buf bytes.Buffer
+		_ = writeFrame(&buf, 0, "json", []byte("hello"), DefaultMaxMessageSize)
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name    string
+		data    func() []byte
+		maxSize int
+		wantErr error
+	}{
+		{
+			name: "非法魔数",
+			data: func() []byte {
+				b := valid()
+				b[0] = 0xff
+				return b
+			},
+			maxSize: DefaultMaxMessageSize,
+			wantErr: ErrInvalidMagic,
+		},
+		{
+			name: "不支持的版本",
+			data: func() []byte {
+				b := valid()
+				b[2] = frameVersion + 1
+				return b
+			},
+			maxSize: DefaultMaxMessageSize,
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name: "超过最大消息限制",
+			data: func() []byte {
+				b := valid()
+				binary.BigEndian.PutUint32(b[5:9], 1<<30)
+				return b
+			},
+			maxSize: DefaultMaxMessageSize,
+			wantErr: ErrMessageTooLarge,
+		},
+		{
+			name: "消息体不完整",
+			data: func() []byte {
+				b := valid()
+				return b[:len(b)-2]
+			},
+			maxSize: DefaultMaxMessageSize,
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "消息头不完整",
+			data: func() []byte {
+				return valid()[:3]
+			},
+			maxSize: DefaultMaxMessageSize,
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readFrame(bytes.NewReader(tt.data()), tt.maxSize)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestWriteFrame_TooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeFrame(&buf, 0, "json", make([]byte, 16), 8)
+	assert.ErrorIs(t, err, ErrMessageTooLarge)
+	assert.Zero(t, buf.Len())
+
+	err = writeFrame(&buf, 0, strings.Repeat("c", 256), nil, 8)
+	assert.Error(t, err)
+	assert.Zero(t, buf.Len())
+}
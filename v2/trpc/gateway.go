@@ -0,0 +1,179 @@
+package trpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"v2/trpc/codes"
+	"v2/trpc/metadata"
+	"v2/trpc/peer"
+	"v2/trpc/status"
+)
+
+const (
+	// MetadataHeaderPrefix 请求头中带有该前缀的字段作为元数据传给服务方法，
+	// 服务方法设置的响应头以同样的前缀返回，例如 Trpc-Metadata-Trace-Id 对应元数据 trace-id
+	MetadataHeaderPrefix = "Trpc-Metadata-"
+	// TrailerHeaderPrefix 服务方法设置的响应尾以该前缀放在 HTTP 响应头中返回
+	TrailerHeaderPrefix = "Trpc-Trailer-"
+)
+
+// httpStatus 错误码对应的 HTTP 状态码，与 gRPC 的 HTTP 映射保持一致
+var httpStatus = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499, // 客户端关闭了请求
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+// HTTPStatusFromCode 返回错误码对应的 HTTP 状态码，未知的错误码返回 500
+func HTTPStatusFromCode(c codes.Code) int {
+	if s, ok := httpStatus[c]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
+
+// gatewayError 调用失败时的响应体
+type gatewayError struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message"`
+}
+
+// gateway 将服务的一元方法以 HTTP/JSON 暴露
+type gateway struct {
+	s *Server
+}
+
+// HTTPHandler 返回将服务的一元方法以 HTTP/JSON 暴露的 http.Handler：
+// POST /{service}/{method}，例如 /user_service/User，请求体为 JSON 编码的请求参数，响应体为 JSON 编码的响应。
+// 调用与 TCP 上的请求经过相同的路径，包括拦截器和 panic 恢复；失败时返回错误码对应的 HTTP 状态码，
+// 响应体为 {"code": 5, "message": "..."}。
+// 请求头中以 MetadataHeaderPrefix 开头的字段作为元数据，服务方法设置的响应头和响应尾分别以
+// MetadataHeaderPrefix 和 TrailerHeaderPrefix 开头放在响应头中。
+// 调用后不能再注册服务；GracefulStop 会等待进行中的 HTTP 调用完成，关闭后的调用返回 503
+func (s *Server) HTTPHandler() http.Handler {
+	s.mu.Lock()
+	s.serving = true
+	s.mu.Unlock()
+	return &gateway{s: s}
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeGatewayError(w, http.StatusMethodNotAllowed, status.New(codes.Unimplemented, "只支持 POST 方法"))
+		return
+	}
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if !ok || serviceName == "" || methodName == "" || strings.Contains(methodName, "/") {
+		writeGatewayError(w, http.StatusNotFound, status.Newf(codes.NotFound, "路径应为 /{service}/{method}: %s", r.URL.Path))
+		return
+	}
+
+	// 服务正在关闭，拒绝新的请求
+	if !g.s.beginCall() {
+		st := status.New(codes.Unavailable, "服务正在关闭")
+		writeGatewayError(w, HTTPStatusFromCode(st.Code()), st)
+		return
+	}
+	defer g.s.callsWg.Done()
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(g.s.opts.maxMessageSize)))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeGatewayError(w, http.StatusRequestEntityTooLarge, status.Newf(codes.ResourceExhausted, "请求体超过 %d 字节", g.s.opts.maxMessageSize))
+			return
+		}
+		writeGatewayError(w, http.StatusBadRequest, status.Newf(codes.InvalidArgument, "读取请求体失败: %v", err))
+		return
+	}
+	if len(body) == 0 {
+		body = []byte("{}")
+	}
+
+	ctx := peer.NewContext(r.Context(), &peer.Peer{Addr: remoteAddr(r.RemoteAddr), TLS: r.TLS})
+	ctx = metadata.NewIncomingContext(ctx, metadataFromHeader(r.Header))
+	call := &serverCall{}
+	ctx = newServerCallContext(ctx, call)
+
+	data, err := g.call(ctx, body, serviceName, methodName)
+
+	header, trailer := call.metadata()
+	for k, v := range header {
+		w.Header().Set(MetadataHeaderPrefix+k, v)
+	}
+	for k, v := range trailer {
+		w.Header().Set(TrailerHeaderPrefix+k, v)
+	}
+	if err != nil {
+		st := status.Convert(err)
+		writeGatewayError(w, HTTPStatusFromCode(st.Code()), st)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// call 使用 JSON 编码调用服务方法，返回编码后的响应
+func (g *gateway) call(ctx context.Context, body []byte, serviceName, methodName string) ([]byte, error) {
+	c, err := g.s.getCodec("json")
+	if err != nil {
+		return nil, err
+	}
+	reply, err := g.s.call(ctx, c, body, serviceName, methodName)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.Marshal(reply)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "响应序列化失败: %v", err)
+	}
+	return data, nil
+}
+
+// metadataFromHeader 取出以 MetadataHeaderPrefix 开头的请求头，同名字段只取第一个值
+func metadataFromHeader(h http.Header) metadata.MD {
+	md := metadata.MD{}
+	for k, v := range h {
+		if key, ok := strings.CutPrefix(k, MetadataHeaderPrefix); ok && key != "" && len(v) > 0 {
+			md.Set(key, v[0])
+		}
+	}
+	return md
+}
+
+// remoteAddr 将 http.Request.RemoteAddr 转换为 net.Addr，无法解析时返回 nil
+func remoteAddr(addr string) net.Addr {
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return nil
+	}
+	return net.TCPAddrFromAddrPort(ap)
+}
+
+func writeGatewayError(w http.ResponseWriter, code int, st *status.Status) {
+	body, _ := json.Marshal(gatewayError{Code: st.Code(), Message: st.Message()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(body)
+}
@@ -0,0 +1,281 @@
+//go:build unit
+
+package trpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"v2/pb"
+	"v2/trpc/codes"
+	"v2/trpc/metadata"
+	"v2/trpc/peer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatewayDo 向 h 发送请求并返回响应
+func gatewayDo(h http.Handler, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// gatewayErrorOf 解析调用失败时的响应体
+func gatewayErrorOf(t *testing.T, rec *httptest.ResponseRecorder) gatewayError {
+	t.Helper()
+	var e gatewayError
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e), rec.Body.String())
+	return e
+}
+
+func TestGateway(t *testing.T) {
+	server := NewServerWithoutListener()
+	require.NoError(t, pb.RegisterHelloServer(server, &serverImpl{}))
+	require.NoError(t, pb.RegisterUserServer(server, &errorServiceImpl{}))
+	h := server.HTTPHandler()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string     // 调用成功时的响应体
+		wantCode   codes.Code // 调用失败时响应体中的错误码
+	}{
+		{name: "调用成功", method: http.MethodPost, path: "/hello_service/Hello", body: `{"Name": "Gateway"}`, wantStatus: http.StatusOK, wantBody: `{"Msg":"Hello, Gateway!"}`},
+		{name: "请求体为空时使用零值", method: http.MethodPost, path: "/hello_service/Hello", wantStatus: http.StatusOK, wantBody: `{"Msg":"Hello, !"}`},
+		{name: "服务方法返回NotFound", method: http.MethodPost, path: "/user_service/User", body: `{"Uid": 3}`, wantStatus: http.StatusNotFound, wantCode: codes.NotFound},
+		{name: "服务方法返回InvalidArgument", method: http.MethodPost, path: "/user_service/User", body: `{"Uid": 0}`, wantStatus: http.StatusBadRequest, wantCode: codes.InvalidArgument},
+		{name: "服务方法返回普通错误", method: http.MethodPost, path: "/user_service/User", body: `{"Uid": -1}`, wantStatus: http.StatusInternalServerError, wantCode: codes.Unknown},
+		{name: "请求体不是合法的JSON", method: http.MethodPost, path: "/hello_service/Hello", body: `{"Name":`, wantStatus: http.StatusBadRequest, wantCode: codes.InvalidArgument},
+		{name: "不存在的service", method: http.MethodPost, path: "/no_service/Hello", body: `{}`, wantStatus: http.StatusNotFound, wantCode: codes.NotFound},
+		{name: "不存在的method", method: http.MethodPost, path: "/hello_service/NoMethod", body: `{}`, wantStatus: http.StatusNotImplemented, wantCode: codes.Unimplemented},
+		{name: "流式方法不能通过HTTP调用", method: http.MethodPost, path: "/user_service/ListUsers", body: `{}`, wantStatus: http.StatusNotImplemented, wantCode: codes.Unimplemented},
+		{name: "路径格式错误", method: http.MethodPost, path: "/hello_service", body: `{}`, wantStatus: http.StatusNotFound, wantCode: codes.NotFound},
+		{name: "路径层级过多", method: http.MethodPost, path: "/hello_service/Hello/extra", body: `{}`, wantStatus: http.StatusNotFound, wantCode: codes.NotFound},
+		{name: "不支持GET", method: http.MethodGet, path: "/hello_service/Hello", wantStatus: http.StatusMethodNotAllowed, wantCode: codes.Unimplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := gatewayDo(h, tt.method, tt.path, tt.body, nil)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			if tt.wantStatus == http.StatusOK {
+				assert.JSONEq(t, tt.wantBody, rec.Body.String())
+				return
+			}
+			assert.Equal(t, tt.wantCode, gatewayErrorOf(t, rec).Code)
+		})
+	}
+}
+
+func TestGateway_Interceptor(t *testing.T) {
+	r := &recorder{}
+	server := NewServerWithoutListener(ChainUnaryInterceptor(serverRecordInterceptor(r, "first")))
+	require.NoError(t, pb.RegisterHelloServer(server, &serverImpl{}))
+
+	rec := gatewayDo(server.HTTPHandler(), http.MethodPost, "/hello_service/Hello", `{"Name": "Interceptor"}`, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"first before hello_service.Hello", "first after"}, r.get())
+}
+
+func TestGateway_Metadata(t *testing.T) {
+	var got metadata.MD
+	var gotPeer *peer.Peer
+	server := NewServerWithoutListener(ChainUnaryInterceptor(func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+		got, _ = metadata.FromIncomingContext(ctx)
+		gotPeer, _ = peer.FromContext(ctx)
+		SetHeader(ctx, metadata.Pairs("trace-id", got.Get("trace-id")))
+		SetTrailer(ctx, metadata.Pairs("cost", "1ms"))
+		return handler(ctx, req)
+	}))
+	require.NoError(t, pb.RegisterHelloServer(server, &serverImpl{}))
+
+	header := http.Header{}
+	header.Set("Trpc-Metadata-Trace-Id", "abc")
+	header.Set("Trpc-Metadata-Caller", "web")
+	header.Set("X-Other", "ignored")
+	rec := gatewayDo(server.HTTPHandler(), http.MethodPost, "/hello_service/Hello", `{"Name": "Metadata"}`, header)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// 只有带前缀的请求头作为元数据
+	assert.Equal(t, metadata.Pairs("trace-id", "abc", "caller", "web"), got)
+	// 响应头和响应尾通过 HTTP 响应头返回
+	assert.Equal(t, "abc", rec.Header().Get("Trpc-Metadata-Trace-Id"))
+	assert.Equal(t, "1ms", rec.Header().Get("Trpc-Trailer-Cost"))
+	// 对端地址来自 HTTP 请求
+	require.NotNil(t, gotPeer)
+	assert.Equal(t, "192.0.2.1:1234", gotPeer.Addr.String())
+	assert.Nil(t, gotPeer.TLS)
+}
+
+func TestGateway_ErrorWithMetadata(t *testing.T) {
+	server := NewServerWithoutListener(ChainUnaryInterceptor(func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+		SetTrailer(ctx, metadata.Pairs("retry-after", "1s"))
+		return handler(ctx, req)
+	}))
+	require.NoError(t, pb.RegisterUserServer(server, &errorServiceImpl{}))
+
+	// 调用失败时也返回响应尾
+	rec := gatewayDo(server.HTTPHandler(), http.MethodPost, "/user_service/User", `{"Uid": 0}`, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "1s", rec.Header().Get("Trpc-Trailer-Retry-After"))
+	assert.Equal(t, gatewayError{Code: codes.InvalidArgument, Message: "uid不能为0"}, gatewayErrorOf(t, rec))
+}
+
+func TestGateway_Panic(t *testing.T) {
+	server := NewServerWithoutListener()
+	require.NoError(t, pb.RegisterUserServer(server, &panicServiceImpl{}))
+
+	rec := gatewayDo(server.HTTPHandler(), http.MethodPost, "/user_service/User", `{"Uid": 0}`, nil)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, gatewayError{Code: codes.Internal, Message: "服务内部错误"}, gatewayErrorOf(t, rec))
+}
+
+func TestGateway_InterceptorPanic(t *testing.T) {
+	server := NewServerWithoutListener(ChainUnaryInterceptor(func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+		panic("interceptor panic")
+	}))
+	require.NoError(t, pb.RegisterHelloServer(server, &serverImpl{}))
+
+	rec := gatewayDo(server.HTTPHandler(), http.MethodPost, "/hello_service/Hello", `{"Name": "Panic"}`, nil)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, gatewayError{Code: codes.Internal, Message: "服务内部错误"}, gatewayErrorOf(t, rec))
+}
+
+func TestGateway_MessageTooLarge(t *testing.T) {
+	server := NewServerWithoutListener(MaxMessageSize(16))
+	require.NoError(t, pb.RegisterHelloServer(server, &serverImpl{}))
+
+	rec := gatewayDo(server.HTTPHandler(), http.MethodPost, "/hello_service/Hello", `{"Name": "`+strings.Repeat("x", 32)+`"}`, nil)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Equal(t, codes.ResourceExhausted, gatewayErrorOf(t, rec).Code)
+}
+
+func TestGateway_RegisterAfterHandler(t *testing.T) {
+	server := NewServerWithoutListener()
+	server.HTTPHandler()
+	assert.ErrorContains(t, pb.RegisterHelloServer(server, &serverImpl{}), "服务已启动")
+}
+
+// blockingHelloImpl 收到请求后等待 release
+type blockingHelloImpl struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingHelloImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	close(s.started)
+	<-s.release
+	return &pb.ReplyHello{Msg: "Hello, " + apply.Name + "!"}, nil
+}
+
+func TestGateway_GracefulStop(t *testing.T) {
+	impl := &blockingHelloImpl{started: make(chan struct{}), release: make(chan struct{})}
+	server := NewServerWithoutListener()
+	require.NoError(t, pb.RegisterHelloServer(server, impl))
+	h := server.HTTPHandler()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var rec *httptest.ResponseRecorder
+	go func() {
+		defer wg.Done()
+		rec = gatewayDo(h, http.MethodPost, "/hello_service/Hello", `{"Name": "Slow"}`, nil)
+	}()
+	<-impl.started
+
+	// GracefulStop 等待进行中的 HTTP 调用完成
+	stopped := make(chan error, 1)
+	go func() { stopped <- server.GracefulStop(context.Background()) }()
+	select {
+	case <-stopped:
+		t.Fatal("GracefulStop 没有等待进行中的调用")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 关闭后的调用返回 503
+	closed := gatewayDo(h, http.MethodPost, "/hello_service/Hello", `{"Name": "Late"}`, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, closed.Code)
+
+	close(impl.release)
+	require.NoError(t, <-stopped)
+	wg.Wait()
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+// ctxErrHelloImpl 测试用的服务实现，等待 ctx 结束后直接返回 ctx.Err()
+type ctxErrHelloImpl struct{}
+
+func (s *ctxErrHelloImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestGateway_ContextError(t *testing.T) {
+	server := NewServerWithoutListener()
+	require.NoError(t, pb.RegisterHelloServer(server, &ctxErrHelloImpl{}))
+	h := server.HTTPHandler()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		wantStatus int
+		wantCode   codes.Code
+	}{
+		{name: "客户端关闭请求", ctx: canceled, wantStatus: 499, wantCode: codes.Canceled},
+		{name: "请求超时", ctx: expired, wantStatus: http.StatusGatewayTimeout, wantCode: codes.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/hello_service/Hello", strings.NewReader(`{}`)).WithContext(tt.ctx)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			// 服务方法返回的 ctx.Err() 转换为对应的错误码，而不是 Unknown 和 500
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantCode, gatewayErrorOf(t, rec).Code)
+		})
+	}
+}
+
+func TestHTTPStatusFromCode(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want int
+	}{
+		{codes.OK, http.StatusOK},
+		{codes.InvalidArgument, http.StatusBadRequest},
+		{codes.Unauthenticated, http.StatusUnauthorized},
+		{codes.PermissionDenied, http.StatusForbidden},
+		{codes.NotFound, http.StatusNotFound},
+		{codes.DeadlineExceeded, http.StatusGatewayTimeout},
+		{codes.Unavailable, http.StatusServiceUnavailable},
+		{codes.Code(100), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			assert.Equal(t, tt.want, HTTPStatusFromCode(tt.code))
+		})
+	}
+}
@@ -1,13 +1,16 @@
 //go:build integration
 
-package trpc
+package trpc_test
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"sync"
 	"testing"
-	"time"
 	"v2/pb"
+	"v2/trpc"
+	"v2/trpc/trpctest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -20,101 +23,39 @@ func (s *testServerImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.R
 	return &pb.ReplyHello{Msg: "Hello, " + apply.Name + "!"}, nil
 }
 
+// startHelloServer 在内存监听上启动 Hello 服务，返回已连接的客户端，测试结束时关闭
+func startHelloServer(t *testing.T) *trpc.Client {
+	client, cleanup, err := trpctest.StartServer([]trpctest.Service{{Desc: &pb.HelloServiceDesc, Impl: &testServerImpl{}}}, nil)
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	return client
+}
+
 // TestIntegrationE2E 端到端集成测试
-// 启动一个真实server goroutine和一个client，验证完整通信流程
+// 启动一个真实的 server 和一个 client，验证完整通信流程
 func TestIntegrationE2E(t *testing.T) {
-	// 创建并启动服务器
-	server, err := NewServer("tcp", "localhost:0")
-	require.NoError(t, err)
-	require.NotNil(t, server)
-
-	// 注册服务
-	pb.RegisterHelloServer(server, &testServerImpl{})
-
-	// 创建用于同步的channel
-	serverReady := make(chan struct{}, 1)
-	serverErr := make(chan error, 1)
-
-	// 启动服务器goroutine
-	go func() {
-		serverReady <- struct{}{} // 通知服务器已准备就绪
-		if err := server.Start(); err != nil {
-			serverErr <- err
-		}
-	}()
-
-	// 等待服务器启动
-	<-serverReady
-	time.Sleep(50 * time.Millisecond) // 给服务器一点时间完全启动
-
-	// 获取服务器监听的地址
-	serverAddr := server.listener.Addr().String()
-	t.Logf("服务器监听地址: %s", serverAddr)
-
-	// 启动客户端goroutine
-	clientErr := make(chan error, 1)
-	var resp *pb.ReplyHello
-
-	go func() {
-		defer close(clientErr)
-
-		// 创建客户端
-		client, err := NewClient("tcp", serverAddr)
-		if err != nil {
-			clientErr <- err
-			return
-		}
-		defer client.Close()
-
-		// 创建 Hello 客户端
-		helloClient := pb.NewHelloClient(client)
-
-		// 调用 Hello 方法
-		resp, err = helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "IntegrationTest"})
-		if err != nil {
-			clientErr <- err
-			return
-		}
-		clientErr <- nil
-	}()
-
-	// 等待客户端完成
-	select {
-	case err := <-clientErr:
-		require.NoError(t, err)
-		assert.NotNil(t, resp)
-		assert.Equal(t, "Hello, IntegrationTest!", resp.Msg)
-	case <-time.After(5 * time.Second):
-		t.Fatal("客户端调用超时")
-	}
+	t.Parallel()
+	client := startHelloServer(t)
 
-	t.Log("端到端测试成功")
+	// 创建 Hello 客户端并调用 Hello 方法
+	helloClient := pb.NewHelloClient(client)
+	resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "IntegrationTest"})
+	require.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.Equal(t, "Hello, IntegrationTest!", resp.Msg)
 }
 
 // TestIntegrationConcurrent 并发集成测试
 // 验证多个客户端同时调用服务时的正确性
 func TestIntegrationConcurrent(t *testing.T) {
-	// 创建并启动服务器
-	server, err := NewServer("tcp", "localhost:0")
-	require.NoError(t, err)
+	t.Parallel()
 
-	// 注册服务
-	pb.RegisterHelloServer(server, &testServerImpl{})
-
-	// 启动服务器goroutine
-	serverReady := make(chan struct{}, 1)
-	go func() {
-		serverReady <- struct{}{}
-		_ = server.Start()
-	}()
-
-	// 等待服务器启动
-	<-serverReady
-	time.Sleep(50 * time.Millisecond)
-
-	// 获取服务器监听的地址
-	serverAddr := server.listener.Addr().String()
-	t.Logf("服务器监听地址: %s", serverAddr)
+	// 创建并启动服务器，多个客户端通过同一个内存监听建立连接
+	lis := trpctest.NewListener()
+	server := trpc.NewServerWithoutListener()
+	require.NoError(t, pb.RegisterHelloServer(server, &testServerImpl{}))
+	go server.Serve(lis)
+	defer server.Stop()
 
 	// 并发参数
 	concurrency := 10
@@ -133,7 +74,7 @@ func TestIntegrationConcurrent(t *testing.T) {
 
 			for j := 0; j < requests/concurrency; j++ {
 				// 创建客户端
-				client, err := NewClient("tcp", serverAddr)
+				client, err := trpc.NewClient("tcp", lis.Addr().String(), trpc.WithContextDialer(lis.DialContext))
 				if err != nil {
 					errors <- err
 					continue
@@ -181,52 +122,62 @@ func TestIntegrationConcurrent(t *testing.T) {
 // TestIntegrationSameClientMultipleCalls 同一客户端多次调用集成测试
 // 验证同一个客户端对象可以进行多次RPC调用
 func TestIntegrationSameClientMultipleCalls(t *testing.T) {
-	// 创建并启动服务器
-	server, err := NewServer("tcp", "localhost:0")
-	require.NoError(t, err)
-
-	// 注册服务
-	pb.RegisterHelloServer(server, &testServerImpl{})
-
-	// 启动服务器goroutine
-	serverReady := make(chan struct{}, 1)
-	go func() {
-		serverReady <- struct{}{}
-		_ = server.Start()
-	}()
-
-	// 等待服务器启动
-	<-serverReady
-	time.Sleep(50 * time.Millisecond)
-
-	// 获取服务器监听的地址
-	serverAddr := server.listener.Addr().String()
-	t.Logf("服务器监听地址: %s", serverAddr)
+	t.Parallel()
 
 	// 创建客户端（只创建一次）
-	client, err := NewClient("tcp", serverAddr)
-	require.NoError(t, err)
-	defer client.Close()
+	client := startHelloServer(t)
 
 	// 创建 Hello 客户端
 	helloClient := pb.NewHelloClient(client)
 
 	// 第一次调用
-	t.Log("第一次调用...")
 	resp1, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "FirstCall"})
 	require.NoError(t, err)
 	assert.Equal(t, "Hello, FirstCall!", resp1.Msg)
-	t.Logf("第一次调用成功: %s", resp1.Msg)
 
 	// 第二次调用（使用同一个client对象）
-	t.Log("第二次调用...")
 	resp2, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "SecondCall"})
 	require.NoError(t, err)
 	assert.Equal(t, "Hello, SecondCall!", resp2.Msg)
-	t.Logf("第二次调用成功: %s", resp2.Msg)
 
 	// 验证两次调用的响应是不同的
 	assert.NotEqual(t, resp1.Msg, resp2.Msg, "两次调用的响应应该不同")
+}
+
+// TestIntegrationLargeMessage 大消息集成测试
+// 验证超过 1024 字节的请求和响应能够被完整收发
+func TestIntegrationLargeMessage(t *testing.T) {
+	t.Parallel()
+	helloClient := pb.NewHelloClient(startHelloServer(t))
+
+	name := strings.Repeat("x", 64*1024)
+	resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: name})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, "+name+"!", resp.Msg)
+
+	// 大消息之后连接仍然可用
+	resp, err = helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Small"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Small!", resp.Msg)
+}
 
-	t.Log("同一客户端多次调用测试成功")
+// TestIntegrationSameClientConcurrentCalls 同一客户端并发调用集成测试
+// 验证多个 goroutine 共享同一个连接时，每个调用都能拿到自己的响应
+func TestIntegrationSameClientConcurrentCalls(t *testing.T) {
+	t.Parallel()
+	helloClient := pb.NewHelloClient(startHelloServer(t))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("Caller%d", i)
+			resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: name})
+			if assert.NoError(t, err) {
+				assert.Equal(t, "Hello, "+name+"!", resp.Msg)
+			}
+		}(i)
+	}
+	wg.Wait()
 }
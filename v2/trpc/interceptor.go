@@ -0,0 +1,72 @@
+package trpc
+
+import "context"
+
+// UnaryServerInfo 服务端拦截器可以获取到的调用信息
+type UnaryServerInfo struct {
+	// Server 服务的实现
+	Server any
+	// FullMethod 完整的方法名，格式为 service_name.method_name
+	FullMethod string
+}
+
+// UnaryHandler 实际调用服务方法的函数
+type UnaryHandler func(ctx context.Context, req any) (any, error)
+
+// UnaryServerInterceptor 服务端拦截器，在服务方法被调用前后执行。
+// 拦截器需要调用 handler 才能继续执行后续的拦截器和服务方法，
+// 不调用 handler 则直接以返回值作为本次调用的结果（例如认证失败）
+type UnaryServerInterceptor func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (resp any, err error)
+
+// UnaryInvoker 实际发起调用的函数
+type UnaryInvoker func(ctx context.Context, method string, req, reply any) error
+
+// UnaryClientInterceptor 客户端拦截器，在发起调用前后执行。
+// 拦截器需要调用 invoker 才能真正发出请求，调用结束后 reply 中是服务端的响应
+type UnaryClientInterceptor func(ctx context.Context, method string, req, reply any, cc *Client, invoker UnaryInvoker) error
+
+// chainUnaryServerInterceptors 将多个服务端拦截器组合为一个，按传入的顺序依次执行
+func chainUnaryServerInterceptors(interceptors []UnaryServerInterceptor) UnaryServerInterceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+
+	return func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+		return interceptors[0](ctx, req, info, chainedUnaryHandler(interceptors, 0, info, handler))
+	}
+}
+
+func chainedUnaryHandler(interceptors []UnaryServerInterceptor, curr int, info *UnaryServerInfo, final UnaryHandler) UnaryHandler {
+	if curr == len(interceptors)-1 {
+		return final
+	}
+	return func(ctx context.Context, req any) (any, error) {
+		return interceptors[curr+1](ctx, req, info, chainedUnaryHandler(interceptors, curr+1, info, final))
+	}
+}
+
+// chainUnaryClientInterceptors 将多个客户端拦截器组合为一个，按传入的顺序依次执行
+func chainUnaryClientInterceptors(interceptors []UnaryClientInterceptor) UnaryClientInterceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+
+	return func(ctx context.Context, method string, req, reply any, cc *Client, invoker UnaryInvoker) error {
+		return interceptors[0](ctx, method, req, reply, cc, chainedUnaryInvoker(interceptors, 0, cc, invoker))
+	}
+}
+
+func chainedUnaryInvoker(interceptors []UnaryClientInterceptor, curr int, cc *Client, final UnaryInvoker) UnaryInvoker {
+	if curr == len(interceptors)-1 {
+		return final
+	}
+	return func(ctx context.Context, method string, req, reply any) error {
+		return interceptors[curr+1](ctx, method, req, reply, cc, chainedUnaryInvoker(interceptors, curr+1, cc, final))
+	}
+}
@@ -0,0 +1,210 @@
+//go:build unit
+
+package trpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"v2/pb"
+	"v2/trpc/codes"
+	"v2/trpc/status"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder 记录拦截器的执行顺序
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) record(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, s)
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+func serverRecordInterceptor(r *recorder, name string) UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+		r.record(name + " before " + info.FullMethod)
+		resp, err := handler(ctx, req)
+		r.record(name + " after")
+		return resp, err
+	}
+}
+
+func clientRecordInterceptor(r *recorder, name string) UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *Client, invoker UnaryInvoker) error {
+		r.record(name + " before " + method)
+		err := invoker(ctx, method, req, reply)
+		r.record(name + " after")
+		return err
+	}
+}
+
+func TestServer_ChainUnaryInterceptor(t *testing.T) {
+	r := &recorder{}
+	server := startTestServer(t, &pb.HelloServiceDesc, &serverImpl{},
+		ChainUnaryInterceptor(serverRecordInterceptor(r, "first"), serverRecordInterceptor(r, "second")),
+		ChainUnaryInterceptor(serverRecordInterceptor(r, "third")),
+	)
+
+	client := newTestClient(t, server.listener.Addr().String())
+
+	resp, err := pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "Test"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Test!", resp.Msg)
+
+	assert.Equal(t, []string{
+		"first before hello_service.Hello",
+		"second before hello_service.Hello",
+		"third before hello_service.Hello",
+		"third after",
+		"second after",
+		"first after",
+	}, r.get())
+}
+
+func TestServer_UnaryInterceptorModify(t *testing.T) {
+	tests := []struct {
+		name        string
+		interceptor UnaryServerInterceptor
+		wantMsg     string
+		wantCode    codes.Code
+	}{
+		{
+			name: "拦截器拒绝请求",
+			interceptor: func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+				return nil, status.Error(codes.Unauthenticated, "缺少认证信息")
+			},
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name: "拦截器修改请求和响应",
+			interceptor: func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+				req.(*pb.ApplyHello).Name = "Modified"
+				resp, err := handler(ctx, req)
+				if err != nil {
+					return nil, err
+				}
+				resp.(*pb.ReplyHello).Msg += " (intercepted)"
+				return resp, nil
+			},
+			wantMsg: "Hello, Modified! (intercepted)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startTestServer(t, &pb.HelloServiceDesc, &serverImpl{}, ChainUnaryInterceptor(tt.interceptor))
+
+			client := newTestClient(t, server.listener.Addr().String())
+
+			resp, err := pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "Test"})
+			if tt.wantCode != codes.OK {
+				assert.Equal(t, tt.wantCode, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMsg, resp.Msg)
+		})
+	}
+}
+
+func TestServer_UnaryInterceptorPanic(t *testing.T) {
+	tests := []struct {
+		name        string
+		interceptor UnaryServerInterceptor
+	}{
+		{
+			name: "调用服务方法前panic",
+			interceptor: func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+				if req.(*pb.ApplyHello).Name == "panic" {
+					panic("interceptor panic")
+				}
+				return handler(ctx, req)
+			},
+		},
+		{
+			name: "调用服务方法后panic",
+			interceptor: func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+				resp, err := handler(ctx, req)
+				if req.(*pb.ApplyHello).Name == "panic" {
+					var m map[string]int
+					m["nil map"] = 1
+				}
+				return resp, err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startTestServer(t, &pb.HelloServiceDesc, &serverImpl{}, ChainUnaryInterceptor(tt.interceptor))
+
+			client := newTestClient(t, server.listener.Addr().String())
+			helloClient := pb.NewHelloClient(client)
+
+			_, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "panic"})
+			assert.Equal(t, codes.Internal, status.Code(err))
+			assert.Equal(t, "服务内部错误", status.Convert(err).Message())
+
+			// 拦截器 panic 后服务和连接仍然可用
+			resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Test"})
+			require.NoError(t, err)
+			assert.Equal(t, "Hello, Test!", resp.Msg)
+		})
+	}
+}
+
+func TestClient_ChainUnaryInterceptor(t *testing.T) {
+	server := startTestServer(t, &pb.HelloServiceDesc, &serverImpl{})
+
+	r := &recorder{}
+	var gotReq, gotReply any
+	inspect := func(ctx context.Context, method string, req, reply any, cc *Client, invoker UnaryInvoker) error {
+		err := invoker(ctx, method, req, reply)
+		gotReq, gotReply = req, reply
+		return err
+	}
+
+	client := newTestClient(t, server.listener.Addr().String(),
+		WithChainUnaryInterceptor(clientRecordInterceptor(r, "first"), clientRecordInterceptor(r, "second")),
+		WithChainUnaryInterceptor(inspect),
+	)
+
+	resp, err := pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "Test"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Test!", resp.Msg)
+
+	assert.Equal(t, []string{
+		"first before hello_service.Hello",
+		"second before hello_service.Hello",
+		"second after",
+		"first after",
+	}, r.get())
+	assert.Equal(t, &pb.ApplyHello{Name: "Test"}, gotReq)
+	assert.Equal(t, &pb.ReplyHello{Msg: "Hello, Test!"}, gotReply)
+}
+
+func TestClient_UnaryInterceptorShortCircuit(t *testing.T) {
+	server := startTestServer(t, &pb.HelloServiceDesc, &serverImpl{})
+
+	// 拦截器不调用 invoker，请求不会发出
+	reject := func(ctx context.Context, method string, req, reply any, cc *Client, invoker UnaryInvoker) error {
+		return status.Error(codes.PermissionDenied, "禁止调用")
+	}
+
+	client := newTestClient(t, server.listener.Addr().String(), WithChainUnaryInterceptor(reject))
+
+	_, err := pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "Test"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
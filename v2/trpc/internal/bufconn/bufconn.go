@@ -0,0 +1,318 @@
+// Package bufconn 实现内存中带缓冲的连接和对应的监听，供测试使用，不需要绑定端口。
+// 与 net.Pipe 不同，写入的数据先放入对端的接收缓冲区，缓冲区有空间时 Write 立即返回，
+// 缓冲区写满后 Write 阻塞，直到对端读取、写超时或连接关闭，与 TCP 连接的行为相近
+package bufconn
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Pipe 创建一对相连的连接，每个方向的缓冲区为 size 字节，两端的地址都是 addr
+func Pipe(addr string, size int) (net.Conn, net.Conn) {
+	ab, ba := newBuffer(size), newBuffer(size)
+	a := newConn(ba, ab, Addr(addr))
+	b := newConn(ab, ba, Addr(addr))
+	return a, b
+}
+
+// buffer 一个方向的有界缓冲区，一端写入，另一端读取
+type buffer struct {
+	mu      sync.Mutex
+	data    []byte
+	size    int
+	wclosed bool          // 写入端已关闭，读完缓冲区中的数据后读取返回 io.EOF
+	rclosed bool          // 读取端已关闭，不再接受写入
+	changed chan struct{} // 缓冲区变化时关闭并替换，唤醒等待的读写
+}
+
+func newBuffer(size int) *buffer {
+	return &buffer{size: size, changed: make(chan struct{})}
+}
+
+// notify 唤醒等待的读写，调用时需要持有 mu
+func (b *buffer) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// read 读取缓冲区中的数据，缓冲区为空时等待，直到有数据写入、写入端关闭、本端关闭或读超时
+func (b *buffer) read(p []byte, dl *deadline, done <-chan struct{}) (int, error) {
+	for {
+		if err := checkDone(dl, done); err != nil {
+			return 0, err
+		}
+
+		b.mu.Lock()
+		if len(b.data) > 0 {
+			n := copy(p, b.data)
+			b.data = b.data[n:]
+			if len(b.data) == 0 {
+				b.data = nil
+			}
+			b.notify()
+			b.mu.Unlock()
+			return n, nil
+		}
+		if b.wclosed {
+			b.mu.Unlock()
+			return 0, io.EOF
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-done:
+		case <-dl.wait():
+		}
+	}
+}
+
+// write 将 p 写入缓冲区，缓冲区满时等待对端读取，直到全部写入、对端关闭、本端关闭或写超时。
+// 返回时已经写入的部分对端仍然可以读到
+func (b *buffer) write(p []byte, dl *deadline, done <-chan struct{}) (int, error) {
+	n := 0
+	for {
+		if err := checkDone(dl, done); err != nil {
+			return n, err
+		}
+
+		b.mu.Lock()
+		if b.rclosed {
+			b.mu.Unlock()
+			return n, io.ErrClosedPipe
+		}
+		if free := b.size - len(b.data); free > 0 && len(p) > 0 {
+			m := min(free, len(p))
+			b.data = append(b.data, p[:m]...)
+			p = p[m:]
+			n += m
+			b.notify()
+		}
+		if len(p) == 0 {
+			b.mu.Unlock()
+			return n, nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-done:
+		case <-dl.wait():
+		}
+	}
+}
+
+// closeWrite 写入端关闭，读取端读完已经写入的数据后返回 io.EOF
+func (b *buffer) closeWrite() {
+	b.mu.Lock()
+	b.wclosed = true
+	b.notify()
+	b.mu.Unlock()
+}
+
+// closeRead 读取端关闭，丢弃未读取的数据，写入端的写入返回 io.ErrClosedPipe
+func (b *buffer) closeRead() {
+	b.mu.Lock()
+	b.rclosed = true
+	b.data = nil
+	b.notify()
+	b.mu.Unlock()
+}
+
+// checkDone 本端已关闭时返回 net.ErrClosed，超时时返回 os.ErrDeadlineExceeded
+func checkDone(dl *deadline, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return net.ErrClosed
+	default:
+	}
+	select {
+	case <-dl.wait():
+		return os.ErrDeadlineExceeded
+	default:
+	}
+	return nil
+}
+
+// deadline 读或写的截止时间，到达截止时间时关闭 wait 返回的 channel
+type deadline struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	cancel chan struct{}
+}
+
+func newDeadline() *deadline {
+	return &deadline{cancel: make(chan struct{})}
+}
+
+// set 设置截止时间，t 为零值时取消截止时间，已经过去的时间立即生效
+func (d *deadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// 定时器已经触发时等待其关闭 cancel
+	if d.timer != nil && !d.timer.Stop() {
+		<-d.cancel
+	}
+	d.timer = nil
+
+	closed := isClosed(d.cancel)
+	if t.IsZero() {
+		if closed {
+			d.cancel = make(chan struct{})
+		}
+		return
+	}
+	if dur := time.Until(t); dur > 0 {
+		if closed {
+			d.cancel = make(chan struct{})
+		}
+		cancel := d.cancel
+		d.timer = time.AfterFunc(dur, func() { close(cancel) })
+		return
+	}
+	if !closed {
+		close(d.cancel)
+	}
+}
+
+func (d *deadline) wait() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cancel
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+// conn 连接的一端，从 r 读取对端写入的数据，写入 w 供对端读取
+type conn struct {
+	r, w   *buffer
+	rd, wd *deadline
+	addr   net.Addr
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newConn(r, w *buffer, addr net.Addr) *conn {
+	return &conn{r: r, w: w, rd: newDeadline(), wd: newDeadline(), addr: addr, done: make(chan struct{})}
+}
+
+func (c *conn) Read(p []byte) (int, error) {
+	return c.r.read(p, c.rd, c.done)
+}
+
+func (c *conn) Write(p []byte) (int, error) {
+	return c.w.write(p, c.wd, c.done)
+}
+
+// Close 关闭连接：对端读完已经写入的数据后读取返回 io.EOF，对端的写入返回 io.ErrClosedPipe
+func (c *conn) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+		c.w.closeWrite()
+		c.r.closeRead()
+	})
+	return nil
+}
+
+func (c *conn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func (c *conn) SetDeadline(t time.Time) error {
+	c.rd.set(t)
+	c.wd.set(t)
+	return nil
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.rd.set(t)
+	return nil
+}
+
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.wd.set(t)
+	return nil
+}
+
+// Listener 内存中的监听，通过 Dial 或 DialContext 建立的连接由 Accept 返回
+type Listener struct {
+	addr  Addr
+	size  int
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+// Listen 创建地址为 addr 的监听，每条连接每个方向的缓冲区为 size 字节
+func Listen(addr string, size int) *Listener {
+	return &Listener{addr: Addr(addr), size: size, conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+// Accept 等待并返回下一个连接，监听关闭后返回 net.ErrClosed
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close 关闭监听，已经建立的连接不受影响
+func (l *Listener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.addr
+}
+
+// Dial 建立一条到监听的连接
+func (l *Listener) Dial() (net.Conn, error) {
+	return l.DialContext(context.Background(), "")
+}
+
+// DialContext 建立一条到监听的连接，忽略 addr。
+// 等待 Accept 时 ctx 结束返回 ctx.Err()，监听关闭后返回 net.ErrClosed
+func (l *Listener) DialContext(ctx context.Context, _ string) (net.Conn, error) {
+	client, server := Pipe(string(l.addr), l.size)
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		client.Close()
+		server.Close()
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		client.Close()
+		server.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// Addr 内存连接和监听的地址
+type Addr string
+
+func (Addr) Network() string { return "bufconn" }
+
+func (a Addr) String() string { return string(a) }
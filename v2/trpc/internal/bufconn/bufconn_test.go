@@ -0,0 +1,188 @@
+//go:build unit
+
+package bufconn
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListener(t *testing.T) {
+	lis := Listen("bufconn", 16)
+	defer lis.Close()
+	assert.Equal(t, "bufconn", lis.Addr().String())
+	assert.Equal(t, "bufconn", lis.Addr().Network())
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if assert.NoError(t, err) {
+			accepted <- conn
+		}
+	}()
+
+	client, err := lis.Dial()
+	require.NoError(t, err)
+	defer client.Close()
+	server := <-accepted
+	defer server.Close()
+
+	// 两端可以双向读写，缓冲区有空间时写入不需要等待对端读取
+	_, err = client.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(server, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+
+	_, err = server.Write([]byte("pong"))
+	require.NoError(t, err)
+	_, err = io.ReadFull(client, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(buf))
+}
+
+func TestListener_Close(t *testing.T) {
+	lis := Listen("bufconn", 16)
+	require.NoError(t, lis.Close())
+	require.NoError(t, lis.Close(), "重复关闭不应返回错误")
+
+	_, err := lis.Accept()
+	assert.ErrorIs(t, err, net.ErrClosed)
+	_, err = lis.Dial()
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
+
+func TestListener_DialContextCanceled(t *testing.T) {
+	lis := Listen("bufconn", 16)
+	defer lis.Close()
+
+	// 没有调用 Accept 时等待到 ctx 结束
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := lis.DialContext(ctx, "ignored")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestPipe_Buffer(t *testing.T) {
+	a, b := Pipe("bufconn", 4)
+	defer a.Close()
+	defer b.Close()
+
+	// 缓冲区写满后阻塞到写超时，已经写入的部分对端可以读到
+	require.NoError(t, a.SetWriteDeadline(time.Now().Add(10*time.Millisecond)))
+	n, err := a.Write([]byte("abcdef"))
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Equal(t, 4, n)
+
+	buf := make([]byte, 8)
+	n, err = b.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "abcd", string(buf[:n]))
+
+	// 取消写超时后，对端读取腾出空间时写入继续
+	require.NoError(t, a.SetWriteDeadline(time.Time{}))
+	done := make(chan error, 1)
+	go func() {
+		_, err := a.Write([]byte("0123456789"))
+		done <- err
+	}()
+	got := make([]byte, 10)
+	_, err = io.ReadFull(b, got)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(got))
+	require.NoError(t, <-done)
+
+	// 没有数据时阻塞到读超时
+	require.NoError(t, b.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+	_, err = b.Read(buf)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestPipe_Close(t *testing.T) {
+	tests := []struct {
+		name string
+		op   func(a, b net.Conn) error
+		want error
+	}{
+		{
+			name: "对端读完数据后返回EOF",
+			op: func(a, b net.Conn) error {
+				buf := make([]byte, 8)
+				n, err := b.Read(buf)
+				if err != nil || string(buf[:n]) != "data" {
+					return err
+				}
+				_, err = b.Read(buf)
+				return err
+			},
+			want: io.EOF,
+		},
+		{
+			name: "对端写入失败",
+			op: func(a, b net.Conn) error {
+				_, err := b.Write([]byte("data"))
+				return err
+			},
+			want: io.ErrClosedPipe,
+		},
+		{
+			name: "本端读取失败",
+			op: func(a, b net.Conn) error {
+				_, err := a.Read(make([]byte, 8))
+				return err
+			},
+			want: net.ErrClosed,
+		},
+		{
+			name: "本端写入失败",
+			op: func(a, b net.Conn) error {
+				_, err := a.Write([]byte("data"))
+				return err
+			},
+			want: net.ErrClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Pipe("bufconn", 16)
+			defer b.Close()
+
+			_, err := a.Write([]byte("data"))
+			require.NoError(t, err)
+			require.NoError(t, a.Close())
+			require.NoError(t, a.Close(), "重复关闭不应返回错误")
+			assert.ErrorIs(t, tt.op(a, b), tt.want)
+		})
+	}
+}
+
+func TestPipe_CloseUnblocks(t *testing.T) {
+	a, b := Pipe("bufconn", 4)
+	defer b.Close()
+
+	// 阻塞中的读写在本端关闭时返回
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := a.Read(make([]byte, 8))
+		readErr <- err
+	}()
+	writeErr := make(chan error, 1)
+	go func() {
+		_, err := a.Write([]byte("abcdef"))
+		writeErr <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, a.Close())
+	assert.ErrorIs(t, <-readErr, net.ErrClosed)
+	assert.ErrorIs(t, <-writeErr, net.ErrClosed)
+}
@@ -0,0 +1,104 @@
+// Package metadata 定义随调用传递的元数据，例如链路追踪 ID、认证信息、调用方名称等
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MD 元数据，key 不区分大小写，统一保存为小写
+type MD map[string]string
+
+func New(m map[string]string) MD {
+	md := make(MD, len(m))
+	for k, v := range m {
+		md.Set(k, v)
+	}
+	return md
+}
+
+// Pairs 根据 key, value, key, value... 创建元数据，参数个数为奇数时 panic
+func Pairs(kv ...string) MD {
+	if len(kv)%2 == 1 {
+		panic(fmt.Sprintf("metadata: Pairs 的参数个数必须为偶数: %d", len(kv)))
+	}
+
+	md := make(MD, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		md.Set(kv[i], kv[i+1])
+	}
+	return md
+}
+
+func (md MD) Get(k string) string {
+	return md[strings.ToLower(k)]
+}
+
+func (md MD) Set(k, v string) {
+	md[strings.ToLower(k)] = v
+}
+
+func (md MD) Delete(k string) {
+	delete(md, strings.ToLower(k))
+}
+
+func (md MD) Len() int {
+	return len(md)
+}
+
+func (md MD) Copy() MD {
+	out := make(MD, len(md))
+	for k, v := range md {
+		out[k] = v
+	}
+	return out
+}
+
+// Join 合并多个元数据，相同的 key 以后面的为准
+func Join(mds ...MD) MD {
+	out := MD{}
+	for _, md := range mds {
+		for k, v := range md {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+type mdIncomingKey struct{}
+type mdOutgoingKey struct{}
+
+// NewIncomingContext 服务端将收到的元数据放入 ctx，供服务方法读取
+func NewIncomingContext(ctx context.Context, md MD) context.Context {
+	return context.WithValue(ctx, mdIncomingKey{}, md)
+}
+
+// FromIncomingContext 服务端获取客户端发送的元数据，返回的是副本，修改不会影响 ctx
+func FromIncomingContext(ctx context.Context) (MD, bool) {
+	md, ok := ctx.Value(mdIncomingKey{}).(MD)
+	if !ok {
+		return nil, false
+	}
+	return md.Copy(), true
+}
+
+// NewOutgoingContext 客户端设置随调用发送的元数据，会覆盖 ctx 中已有的元数据
+func NewOutgoingContext(ctx context.Context, md MD) context.Context {
+	return context.WithValue(ctx, mdOutgoingKey{}, md)
+}
+
+// AppendToOutgoingContext 在 ctx 已有的元数据上追加 key, value, key, value...
+func AppendToOutgoingContext(ctx context.Context, kv ...string) context.Context {
+	md, _ := FromOutgoingContext(ctx)
+	return NewOutgoingContext(ctx, Join(md, Pairs(kv...)))
+}
+
+// FromOutgoingContext 获取客户端将要发送的元数据，返回的是副本，修改不会影响 ctx
+func FromOutgoingContext(ctx context.Context) (MD, bool) {
+	md, ok := ctx.Value(mdOutgoingKey{}).(MD)
+	if !ok {
+		return nil, false
+	}
+	return md.Copy(), true
+}
@@ -0,0 +1,57 @@
+//go:build unit
+
+package metadata
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPairs(t *testing.T) {
+	md := Pairs("Trace-ID", "abc", "caller", "client")
+	assert.Equal(t, MD{"trace-id": "abc", "caller": "client"}, md)
+	assert.Equal(t, "abc", md.Get("TRACE-ID"))
+
+	assert.Panics(t, func() {
+		Pairs("key")
+	})
+}
+
+func TestJoin(t *testing.T) {
+	md := Join(Pairs("a", "1", "b", "2"), nil, Pairs("b", "3"))
+	assert.Equal(t, MD{"a": "1", "b": "3"}, md)
+}
+
+func TestOutgoingContext(t *testing.T) {
+	ctx := context.Background()
+	_, ok := FromOutgoingContext(ctx)
+	assert.False(t, ok)
+
+	ctx = NewOutgoingContext(ctx, Pairs("a", "1"))
+	ctx = AppendToOutgoingContext(ctx, "b", "2")
+	md, ok := FromOutgoingContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, MD{"a": "1", "b": "2"}, md)
+
+	// 修改返回值不影响 ctx 中的元数据
+	md.Set("c", "3")
+	md, _ = FromOutgoingContext(ctx)
+	assert.Equal(t, MD{"a": "1", "b": "2"}, md)
+}
+
+func TestIncomingContext(t *testing.T) {
+	ctx := context.Background()
+	_, ok := FromIncomingContext(ctx)
+	assert.False(t, ok)
+
+	ctx = NewIncomingContext(ctx, Pairs("a", "1"))
+	md, ok := FromIncomingContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, MD{"a": "1"}, md)
+
+	// 客户端的元数据不会出现在服务端的 ctx 中
+	_, ok = FromOutgoingContext(ctx)
+	assert.False(t, ok)
+}
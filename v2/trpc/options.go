@@ -0,0 +1,175 @@
+package trpc
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"time"
+	"v2/trpc/balancer"
+	"v2/trpc/codec"
+)
+
+// serverOptions 服务端配置
+type serverOptions struct {
+	maxMessageSize    int
+	unaryInterceptors []UnaryServerInterceptor
+	codecs            map[string]codec.Codec
+	panicHandler      PanicHandler
+	tlsConfig         *tls.Config
+}
+
+func defaultServerOptions() serverOptions {
+	return serverOptions{
+		maxMessageSize: DefaultMaxMessageSize,
+	}
+}
+
+// ServerOption 设置服务端配置
+type ServerOption func(*serverOptions)
+
+// MaxMessageSize 设置服务端允许收发的最大消息体大小，单位字节
+func MaxMessageSize(n int) ServerOption {
+	return func(o *serverOptions) {
+		o.maxMessageSize = n
+	}
+}
+
+// ChainUnaryInterceptor 添加服务端拦截器，多次调用时追加到已有拦截器之后，
+// 按添加的顺序执行，第一个拦截器在最外层
+func ChainUnaryInterceptor(interceptors ...UnaryServerInterceptor) ServerOption {
+	return func(o *serverOptions) {
+		o.unaryInterceptors = append(o.unaryInterceptors, interceptors...)
+	}
+}
+
+// CustomCodec 添加服务端支持的编码，优先于 codec.Register 注册的同名编码。
+// 服务端使用请求帧中的编码名称选择 codec，并使用同一个 codec 编码响应
+func CustomCodec(c codec.Codec) ServerOption {
+	return func(o *serverOptions) {
+		if o.codecs == nil {
+			o.codecs = make(map[string]codec.Codec)
+		}
+		o.codecs[strings.ToLower(c.Name())] = c
+	}
+}
+
+// PanicHandler 处理服务方法或拦截器中发生的 panic，p 为 recover 的返回值，
+// 返回的 error 会转换为 Status 返回给客户端。在 recover 所在的 goroutine 中调用，可以通过 debug.Stack 获取调用栈
+type PanicHandler func(ctx context.Context, p any) error
+
+// RecoveryHandler 设置服务方法或拦截器 panic 时的处理函数。
+// 默认记录 panic 和调用栈，并返回 codes.Internal 错误
+func RecoveryHandler(h PanicHandler) ServerOption {
+	return func(o *serverOptions) {
+		o.panicHandler = h
+	}
+}
+
+// TLS 设置服务端的 TLS 配置，默认不使用 TLS。可以通过 credentials.ServerTLSConfig 创建，
+// 设置 ClientAuth 时要求客户端提供证书（mTLS），服务方法可以通过 peer.FromContext 获取客户端的证书
+func TLS(cfg *tls.Config) ServerOption {
+	return func(o *serverOptions) {
+		o.tlsConfig = cfg
+	}
+}
+
+// clientOptions 客户端配置
+type clientOptions struct {
+	maxMessageSize    int
+	unaryInterceptors []UnaryClientInterceptor
+	codec             codec.Codec
+	minConns          int
+	maxConns          int
+	idleTimeout       time.Duration
+	backoff           BackoffConfig
+	balancer          balancer.Builder
+	tlsConfig         *tls.Config
+	dialer            func(ctx context.Context, addr string) (net.Conn, error)
+}
+
+func defaultClientOptions() clientOptions {
+	return clientOptions{
+		maxMessageSize: DefaultMaxMessageSize,
+		codec:          codec.JSON{},
+		minConns:       1,
+		maxConns:       1,
+		backoff:        DefaultBackoffConfig,
+		balancer:       balancer.PickFirst(),
+	}
+}
+
+// ClientOption 设置客户端配置
+type ClientOption func(*clientOptions)
+
+// WithMaxMessageSize 设置客户端允许收发的最大消息体大小，单位字节
+func WithMaxMessageSize(n int) ClientOption {
+	return func(o *clientOptions) {
+		o.maxMessageSize = n
+	}
+}
+
+// WithChainUnaryInterceptor 添加客户端拦截器，多次调用时追加到已有拦截器之后，
+// 按添加的顺序执行，第一个拦截器在最外层
+func WithChainUnaryInterceptor(interceptors ...UnaryClientInterceptor) ClientOption {
+	return func(o *clientOptions) {
+		o.unaryInterceptors = append(o.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithCodec 设置客户端请求使用的编码，默认为 JSON。
+// 服务端需要支持该编码，自定义的编码需要在服务端通过 codec.Register 或 CustomCodec 注册
+func WithCodec(c codec.Codec) ClientOption {
+	return func(o *clientOptions) {
+		o.codec = c
+	}
+}
+
+// WithPoolSize 设置连接池的最小和最大连接数，默认都为 1。
+// NewClient 时建立 min 条连接，所有连接都有调用进行中时才建立新连接，最多 max 条；
+// 每次调用选择进行中的调用和流最少的连接
+func WithPoolSize(min, max int) ClientOption {
+	return func(o *clientOptions) {
+		o.minConns = min
+		o.maxConns = max
+	}
+}
+
+// WithIdleTimeout 设置连接的最长空闲时间，超过后关闭连接，但至少保留最小连接数条连接。
+// 默认为 0，不关闭空闲连接
+func WithIdleTimeout(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.idleTimeout = d
+	}
+}
+
+// WithBackoff 设置连接断开或建立连接失败后重新建立连接的退避策略，默认为 DefaultBackoffConfig
+func WithBackoff(bc BackoffConfig) ClientOption {
+	return func(o *clientOptions) {
+		o.backoff = bc
+	}
+}
+
+// WithBalancer 设置在多个地址之间选择的负载均衡策略，默认为 balancer.PickFirst()
+func WithBalancer(b balancer.Builder) ClientOption {
+	return func(o *clientOptions) {
+		o.balancer = b
+	}
+}
+
+// WithTLS 设置客户端的 TLS 配置，默认不使用 TLS。可以通过 credentials.ClientTLSConfig 创建，
+// 没有设置 ServerName 时使用目标地址中的主机名验证服务端证书
+func WithTLS(cfg *tls.Config) ClientOption {
+	return func(o *clientOptions) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithContextDialer 设置建立连接的函数，addr 为目标地址或 resolver 解析出的地址，
+// 可以用于自定义的传输（例如 socket pair、内存中的连接），设置后忽略 NewClient 的 network。
+// 设置了 WithTLS 时在返回的连接上进行 TLS 握手
+func WithContextDialer(f func(ctx context.Context, addr string) (net.Conn, error)) ClientOption {
+	return func(o *clientOptions) {
+		o.dialer = f
+	}
+}
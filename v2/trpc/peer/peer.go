@@ -0,0 +1,39 @@
+// Package peer 定义调用的对端信息，服务方法可以通过 FromContext 获取客户端的地址和经过验证的证书
+package peer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+)
+
+// Peer 调用的对端
+type Peer struct {
+	// Addr 对端地址
+	Addr net.Addr
+	// TLS 连接的 TLS 状态，没有使用 TLS 时为 nil
+	TLS *tls.ConnectionState
+}
+
+// Certificate 对端经过验证的证书，没有使用 TLS 或对端没有提供证书时返回 nil。
+// 服务端只有要求并验证客户端证书（mTLS）时，客户端的证书才经过验证
+func (p *Peer) Certificate() *x509.Certificate {
+	if p.TLS == nil || len(p.TLS.VerifiedChains) == 0 || len(p.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return p.TLS.VerifiedChains[0][0]
+}
+
+type peerKey struct{}
+
+// NewContext 将对端信息放入 ctx
+func NewContext(ctx context.Context, p *Peer) context.Context {
+	return context.WithValue(ctx, peerKey{}, p)
+}
+
+// FromContext 服务端获取调用的对端信息
+func FromContext(ctx context.Context) (*Peer, bool) {
+	p, ok := ctx.Value(peerKey{}).(*Peer)
+	return p, ok
+}
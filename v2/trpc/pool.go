@@ -0,0 +1,438 @@
+package trpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+	"v2/trpc/codes"
+	"v2/trpc/connectivity"
+	"v2/trpc/resolver"
+	"v2/trpc/status"
+)
+
+// minConnectTimeout 后台建立一条连接的超时时间
+const minConnectTimeout = 20 * time.Second
+
+// errPoolClosed 连接池已关闭：客户端已关闭，或者地址已被 resolver 移除
+var errPoolClosed = errors.New("连接池已关闭")
+
+// connPool 同一个目标地址的连接池，实现 balancer.SubConn。
+// 所有连接都有调用进行中且连接数没有达到上限时才建立新连接，否则选择进行中的调用和流最少的连接。
+// 连接断开后立即从连接池中移除，连接数少于 minConns 时在后台按退避策略重连；
+// 空闲超过 idleTimeout 的连接在后台关闭，但至少保留 minConns 条
+type connPool struct {
+	network string
+	opts    *clientOptions
+	ctx     context.Context // 连接池关闭时取消，结束后台的重连和清理
+	cancel  context.CancelFunc
+
+	onStateChange func()
+
+	mu         sync.Mutex
+	address    resolver.Address // resolver 再次推送同一地址时可能更新权重
+	conns      []*clientTransport
+	dialing    int   // 调用中正在建立的新连接数，计入连接数上限
+	connecting bool  // 后台正在重连
+	failing    bool  // 后台重连失败，正在等待重试
+	lastErr    error // 最近一次重连失败的原因
+	state      connectivity.State
+	stateCh    chan struct{} // 状态变化时关闭并替换，用于唤醒等待状态变化的调用
+	closed     bool
+}
+
+// newConnPool 创建连接池，不建立连接，状态变化时在新的 goroutine 中调用 onStateChange
+func newConnPool(network string, address resolver.Address, opts *clientOptions, onStateChange func()) *connPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &connPool{
+		network:       network,
+		address:       address,
+		opts:          opts,
+		onStateChange: onStateChange,
+		ctx:           ctx,
+		cancel:        cancel,
+		stateCh:       make(chan struct{}),
+	}
+	if opts.idleTimeout > 0 {
+		go p.evictLoop()
+	}
+	return p
+}
+
+// dialMin 立即建立 minConns 条连接，任意一条连接失败时返回错误
+func (p *connPool) dialMin() error {
+	for i := 0; i < p.opts.minConns; i++ {
+		t, err := p.dial(p.ctx)
+		if err != nil {
+			return err
+		}
+		p.mu.Lock()
+		p.conns = append(p.conns, t)
+		p.updateState()
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+func (p *connPool) dial(ctx context.Context) (*clientTransport, error) {
+	addr := p.Address().Addr
+	var (
+		conn net.Conn
+		err  error
+	)
+	if p.opts.dialer != nil {
+		conn, err = p.opts.dialer(ctx, addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, p.network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.opts.tlsConfig != nil {
+		if conn, err = clientHandshake(ctx, conn, addr, p.opts.tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+	return newClientTransport(conn, p.opts.maxMessageSize, p.remove), nil
+}
+
+// clientHandshake 在连接上完成 TLS 握手，没有设置 ServerName 时使用 addr 中的主机名，失败时关闭连接
+func clientHandshake(ctx context.Context, conn net.Conn, addr string, cfg *tls.Config) (net.Conn, error) {
+	if cfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		cfg = cfg.Clone()
+		cfg.ServerName = host
+	}
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("TLS 握手失败: %w", err)
+	}
+	return tlsConn, nil
+}
+
+// get 选择一条可用的连接。没有可用连接时在后台建立连接并等待，
+// 后台建立连接失败时，waitForReady 为 false 的调用返回 codes.Unavailable
+func (p *connPool) get(ctx context.Context, waitForReady bool) (*clientTransport, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, errPoolClosed
+		}
+
+		if best, bestLoad := p.leastLoaded(); best != nil {
+			if bestLoad == 0 || len(p.conns)+p.dialing >= p.opts.maxConns {
+				best.lastUsed = time.Now()
+				p.mu.Unlock()
+				return best, nil
+			}
+			p.dialing++
+			p.mu.Unlock()
+			return p.grow(ctx, best), nil
+		}
+
+		// 没有可用的连接，等待后台建立连接
+		p.connectLocked()
+		if p.state == connectivity.TransientFailure && !waitForReady {
+			err := status.Errorf(codes.Unavailable, "连接不可用: %v", p.lastErr)
+			p.mu.Unlock()
+			return nil, err
+		}
+		stateCh := p.stateCh
+		p.mu.Unlock()
+
+		select {
+		case <-stateCh:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// grow 所有连接都有调用进行中时建立一条新连接，失败时使用负载最低的连接 best，只是负载较高
+func (p *connPool) grow(ctx context.Context, best *clientTransport) *clientTransport {
+	t, err := p.dial(ctx)
+
+	p.mu.Lock()
+	p.dialing--
+	if err != nil {
+		best.lastUsed = time.Now()
+		p.mu.Unlock()
+		return best
+	}
+	if p.closed {
+		p.mu.Unlock()
+		t.close(ErrClientClosed)
+		return best
+	}
+	p.conns = append(p.conns, t)
+	p.mu.Unlock()
+	return t
+}
+
+// leastLoaded 返回负载最低的可用连接，负载相同时选择靠前的连接，调用方持有 p.mu
+func (p *connPool) leastLoaded() (*clientTransport, int) {
+	var best *clientTransport
+	bestLoad := 0
+	for _, t := range p.conns {
+		if t.closeErr() != nil {
+			continue
+		}
+		if load := t.load(); best == nil || load < bestLoad {
+			best, bestLoad = t, load
+		}
+	}
+	return best, bestLoad
+}
+
+// remove 连接断开后从连接池中移除，连接数少于 minConns 时在后台重连
+func (p *connPool) remove(t *clientTransport) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := slices.Index(p.conns, t)
+	if i < 0 {
+		return
+	}
+	p.conns = slices.Delete(p.conns, i, i+1)
+	if len(p.conns) < p.opts.minConns {
+		p.connectLocked()
+	}
+	p.updateState()
+}
+
+// connectLocked 后台没有在重连时开始重连，调用方持有 p.mu
+func (p *connPool) connectLocked() {
+	if p.connecting || p.closed {
+		return
+	}
+	p.connecting = true
+	p.updateState()
+	go p.connectLoop()
+}
+
+// connectLoop 建立连接直到连接数达到 minConns（至少一条），每次失败后按退避策略等待
+func (p *connPool) connectLoop() {
+	target := max(p.opts.minConns, 1)
+	for retries := 0; ; {
+		p.mu.Lock()
+		if p.closed || len(p.conns) >= target {
+			p.connecting = false
+			p.failing = false
+			p.updateState()
+			p.mu.Unlock()
+			return
+		}
+		p.failing = false
+		p.updateState()
+		p.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(p.ctx, minConnectTimeout)
+		t, err := p.dial(ctx)
+		cancel()
+
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			if err == nil {
+				t.close(ErrClientClosed)
+			}
+			return
+		}
+		if err == nil {
+			p.conns = append(p.conns, t)
+			p.lastErr = nil
+			p.updateState()
+			p.mu.Unlock()
+			retries = 0
+			continue
+		}
+
+		p.lastErr = err
+		p.failing = true
+		p.updateState()
+		p.mu.Unlock()
+
+		timer := time.NewTimer(p.opts.backoff.backoff(retries))
+		select {
+		case <-timer.C:
+			retries++
+		case <-p.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// updateState 根据连接池的状态更新连接状态，状态变化时唤醒等待的调用，调用方持有 p.mu
+func (p *connPool) updateState() {
+	var state connectivity.State
+	switch {
+	case p.closed:
+		state = connectivity.Shutdown
+	case len(p.conns) > 0:
+		state = connectivity.Ready
+	case p.connecting && p.failing:
+		state = connectivity.TransientFailure
+	case p.connecting:
+		state = connectivity.Connecting
+	default:
+		state = connectivity.Idle
+	}
+
+	if state == p.state {
+		return
+	}
+	p.state = state
+	close(p.stateCh)
+	p.stateCh = make(chan struct{})
+	if p.onStateChange != nil {
+		go p.onStateChange()
+	}
+}
+
+func (p *connPool) Address() resolver.Address {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.address
+}
+
+func (p *connPool) setAddress(a resolver.Address) {
+	p.mu.Lock()
+	p.address = a
+	p.mu.Unlock()
+}
+
+func (p *connPool) State() connectivity.State {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// err 最近一次重连失败的原因
+func (p *connPool) err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastErr
+}
+
+// Load 所有连接上进行中的调用和流的数量
+func (p *connPool) Load() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := 0
+	for _, t := range p.conns {
+		n += t.load()
+	}
+	return n
+}
+
+// Connect 处于 Idle 状态时开始建立连接
+func (p *connPool) Connect() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state == connectivity.Idle {
+		p.connectLocked()
+	}
+}
+
+// evictLoop 定期关闭空闲的连接
+func (p *connPool) evictLoop() {
+	ticker := time.NewTicker(p.opts.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.evict(time.Now())
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// evict 关闭在 now 之前空闲超过 idleTimeout 的连接，至少保留 minConns 条连接
+func (p *connPool) evict(now time.Time) {
+	p.mu.Lock()
+	var idle []*clientTransport
+	keep := p.conns[:0]
+	for i, t := range p.conns {
+		if t.load() > 0 {
+			t.lastUsed = now
+		}
+		if now.Sub(t.lastUsed) >= p.opts.idleTimeout && len(keep)+len(p.conns)-i > p.opts.minConns {
+			idle = append(idle, t)
+			continue
+		}
+		keep = append(keep, t)
+	}
+	clear(p.conns[len(keep):])
+	p.conns = keep
+	p.updateState()
+	p.mu.Unlock()
+
+	// 连接已经从连接池中移除，关闭时不会再触发重连
+	for _, t := range idle {
+		t.close(ErrClientClosed)
+	}
+}
+
+// size 连接池中的连接数
+func (p *connPool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.conns)
+}
+
+// drain 地址被移除时调用，不再接受新的调用，连接在进行中的调用和流结束后关闭
+func (p *connPool) drain() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	p.cancel()
+	conns := p.conns
+	p.conns = nil
+	p.updateState()
+	p.mu.Unlock()
+
+	for _, t := range conns {
+		t.drain()
+	}
+}
+
+// close 关闭所有连接，之后 get 返回 errPoolClosed
+func (p *connPool) close(err error) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.cancel()
+	conns := p.conns
+	p.conns = nil
+	p.updateState()
+	p.mu.Unlock()
+
+	var firstErr error
+	for _, t := range conns {
+		if err := t.close(err); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,375 @@
+//go:build unit
+
+package trpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"v2/pb"
+	"v2/trpc/codes"
+	"v2/trpc/connectivity"
+	"v2/trpc/status"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockHoldHelloHandle 读取请求后等待 release 关闭再返回响应
+func mockHoldHelloHandle(release <-chan struct{}) func(net.Conn) {
+	return func(conn net.Conn) {
+		defer conn.Close()
+
+		var mu sync.Mutex
+		for {
+			a, err := mockReadApply(conn)
+			if err != nil {
+				return
+			}
+			go func() {
+				<-release
+				mu.Lock()
+				defer mu.Unlock()
+				mockWriteHelloReply(conn, a)
+			}()
+		}
+	}
+}
+
+// mockHelloLoopHandle 持续处理 Hello 请求
+func mockHelloLoopHandle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		a, err := mockReadApply(conn)
+		if err != nil {
+			return
+		}
+		mockWriteHelloReply(conn, a)
+	}
+}
+
+// poolLoad 连接池中所有连接进行中的调用和流的总数
+func poolLoad(p *connPool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, t := range p.conns {
+		n += t.load()
+	}
+	return n
+}
+
+// testPool 返回目标地址为 host:port 的客户端唯一的连接池
+func testPool(c *Client) *connPool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pools[c.addrs[0]]
+}
+
+// poolConns 返回连接池当前连接的副本
+func poolConns(p *connPool) []*clientTransport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*clientTransport(nil), p.conns...)
+}
+
+func TestNewClient_PoolSize(t *testing.T) {
+	server := startMockServer(t, mockHelloLoopHandle)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		min, max int
+		wantSize int
+		wantErr  bool
+	}{
+		{name: "默认一条连接", min: 1, max: 1, wantSize: 1},
+		{name: "预先建立最小连接数", min: 3, max: 5, wantSize: 3},
+		{name: "最小连接数为0-不建立连接", min: 0, max: 2, wantSize: 0},
+		{name: "最小连接数大于最大连接数-失败", min: 3, max: 2, wantErr: true},
+		{name: "最大连接数为0-失败", min: 0, max: 0, wantErr: true},
+		{name: "最小连接数为负数-失败", min: -1, max: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient("tcp", server.Addr().String(), WithContextDialer(dialTest), WithPoolSize(tt.min, tt.max))
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, client)
+				return
+			}
+			require.NoError(t, err)
+			defer client.Close()
+			assert.Equal(t, tt.wantSize, testPool(client).size())
+		})
+	}
+}
+
+func TestConnPool_LazyDial(t *testing.T) {
+	server := startMockServer(t, mockHelloLoopHandle)
+	defer server.Close()
+
+	client := newTestClient(t, server.Addr().String(), WithPoolSize(0, 2))
+	require.Equal(t, 0, testPool(client).size())
+
+	// 第一次调用时建立连接，没有进行中的调用时复用同一条连接
+	helloClient := pb.NewHelloClient(client)
+	for i := 0; i < 3; i++ {
+		resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Lazy"})
+		require.NoError(t, err)
+		assert.Equal(t, "Hello, Lazy!", resp.Msg)
+	}
+	assert.Equal(t, 1, testPool(client).size())
+}
+
+func TestConnPool_LeastLoaded(t *testing.T) {
+	release := make(chan struct{})
+	server := startMockServer(t, mockHoldHelloHandle(release))
+	defer server.Close()
+
+	client := newTestClient(t, server.Addr().String(), WithPoolSize(1, 3))
+
+	helloClient := pb.NewHelloClient(client)
+	var wg sync.WaitGroup
+	call := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Load"})
+			assert.NoError(t, err)
+		}()
+	}
+	totalLoad := func() int { return poolLoad(testPool(client)) }
+
+	// 已有的连接都在处理调用时建立新连接，直到达到最大连接数
+	for i := 1; i <= 3; i++ {
+		call()
+		require.Eventually(t, func() bool { return totalLoad() == i }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, i, testPool(client).size())
+	}
+
+	// 达到最大连接数后分摊到负载最低的连接
+	for i := 4; i <= 6; i++ {
+		call()
+		require.Eventually(t, func() bool { return totalLoad() == i }, time.Second, 5*time.Millisecond)
+	}
+	assert.Equal(t, 3, testPool(client).size())
+	testPool(client).mu.Lock()
+	for _, c := range testPool(client).conns {
+		assert.Equal(t, 2, c.load())
+	}
+	testPool(client).mu.Unlock()
+
+	close(release)
+	wg.Wait()
+}
+
+func TestConnPool_EvictBroken(t *testing.T) {
+	// 每条连接只处理一个请求就断开
+	var accepted atomic.Int32
+	server := startMockServer(t, func(conn net.Conn) {
+		accepted.Add(1)
+		mockHelloHandle(conn)
+	})
+	defer server.Close()
+
+	client := newTestClient(t, server.Addr().String())
+
+	helloClient := pb.NewHelloClient(client)
+	for i := 1; i <= 3; i++ {
+		resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Broken"})
+		require.NoError(t, err)
+		assert.Equal(t, "Hello, Broken!", resp.Msg)
+
+		// 客户端发现连接断开后移除连接，并在后台建立新连接
+		require.Eventually(t, func() bool { return accepted.Load() == int32(i+1) }, time.Second, 5*time.Millisecond)
+	}
+	require.Eventually(t, func() bool { return client.GetState() == connectivity.Ready }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 1, testPool(client).size())
+}
+
+func TestConnPool_EvictIdle(t *testing.T) {
+	release := make(chan struct{})
+	server := startMockServer(t, mockHoldHelloHandle(release))
+	defer server.Close()
+
+	client := newTestClient(t, server.Addr().String(), WithPoolSize(1, 3), WithIdleTimeout(time.Hour))
+
+	// 两个调用都没有结束，建立 2 条连接
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	helloClient := pb.NewHelloClient(client)
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := helloClient.Hello(ctx, &pb.ApplyHello{Name: "Idle"})
+			done <- err
+		}()
+		require.Eventually(t, func() bool { return poolLoad(testPool(client)) == i+1 }, time.Second, 5*time.Millisecond)
+	}
+	require.Equal(t, 2, testPool(client).size())
+
+	// 有调用进行中的连接不会被关闭
+	testPool(client).evict(time.Now().Add(2 * time.Hour))
+	assert.Equal(t, 2, testPool(client).size())
+
+	// 调用结束后空闲超时的连接被关闭，但保留最小连接数
+	close(release)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, <-done)
+	}
+	testPool(client).evict(time.Now().Add(4 * time.Hour))
+	assert.Equal(t, 1, testPool(client).size())
+
+	resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Idle"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Idle!", resp.Msg)
+}
+
+func TestConnPool_Close(t *testing.T) {
+	server := startMockServer(t, mockHelloLoopHandle)
+	defer server.Close()
+
+	client, err := NewClient("tcp", server.Addr().String(), WithContextDialer(dialTest), WithPoolSize(2, 2), WithIdleTimeout(time.Minute))
+	require.NoError(t, err)
+	pool := testPool(client)
+	require.NoError(t, client.Close())
+	assert.Equal(t, 0, pool.size())
+
+	_, err = client.NewStream(context.Background(), &pb.UserServiceDesc.Streams[0], "user_service.ListUsers")
+	assert.ErrorIs(t, err, ErrClientClosed)
+}
+
+// mockRestartServer 可以关闭后在同一地址重新启动的模拟服务器，用于模拟服务端重启
+type mockRestartServer struct {
+	t    *testing.T
+	addr string
+
+	mu    sync.Mutex
+	lis   net.Listener
+	conns []net.Conn
+}
+
+func newMockRestartServer(t *testing.T) *mockRestartServer {
+	s := &mockRestartServer{t: t, addr: "localhost:0"}
+	s.start()
+	s.addr = s.lis.Addr().String()
+	return s
+}
+
+func (s *mockRestartServer) start() {
+	lis := listenTest(s.t, s.addr)
+
+	s.mu.Lock()
+	s.lis = lis
+	s.mu.Unlock()
+	go serveMock(lis, func(conn net.Conn) {
+		// 监听已经关闭时不再处理，连接与 stop 关闭的连接一样断开
+		s.mu.Lock()
+		if s.lis != lis {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		mockHelloLoopHandle(conn)
+	})
+}
+
+// stop 关闭监听和所有连接
+func (s *mockRestartServer) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lis != nil {
+		s.lis.Close()
+		s.lis = nil
+	}
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// testBackoff 测试使用的退避策略，避免等待太久
+var testBackoff = BackoffConfig{BaseDelay: 10 * time.Millisecond, Multiplier: 1.6, Jitter: 0.2, MaxDelay: 50 * time.Millisecond}
+
+func TestClient_Reconnect(t *testing.T) {
+	server := newMockRestartServer(t)
+	defer server.stop()
+
+	client := newTestClient(t, server.addr, WithBackoff(testBackoff))
+	assert.Equal(t, connectivity.Ready, client.GetState())
+
+	helloClient := pb.NewHelloClient(client)
+	_, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Before"})
+	require.NoError(t, err)
+
+	// 服务端关闭后客户端在后台重连，重连失败时进入 TransientFailure
+	server.stop()
+	require.Eventually(t, func() bool { return client.GetState() == connectivity.TransientFailure }, time.Second, 5*time.Millisecond)
+
+	// 默认立即失败
+	_, err = helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "FailFast"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// WaitForReady 的调用等待到 ctx 结束
+	ctx, cancel := context.WithTimeout(WithCallOptions(context.Background(), WaitForReady(true)), 50*time.Millisecond)
+	defer cancel()
+	_, err = helloClient.Hello(ctx, &pb.ApplyHello{Name: "Timeout"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// WaitForReady 的调用在服务端重新启动后成功
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(WithCallOptions(context.Background(), WaitForReady(true)), 2*time.Second)
+		defer cancel()
+		resp, err := helloClient.Hello(ctx, &pb.ApplyHello{Name: "After"})
+		if err == nil {
+			assert.Equal(t, "Hello, After!", resp.Msg)
+		}
+		done <- err
+	}()
+	server.start()
+	require.NoError(t, <-done)
+	assert.Equal(t, connectivity.Ready, client.GetState())
+
+	resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Ready"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Ready!", resp.Msg)
+}
+
+func TestClient_WaitForStateChange(t *testing.T) {
+	server := startMockServer(t, mockHelloLoopHandle)
+	defer server.Close()
+
+	// 最小连接数为 0 时不建立连接
+	client, err := NewClient("tcp", server.Addr().String(), WithContextDialer(dialTest), WithPoolSize(0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, connectivity.Idle, client.GetState())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.False(t, client.WaitForStateChange(ctx, connectivity.Idle))
+
+	// Connect 后依次经过 Connecting 和 Ready
+	client.Connect()
+	state := connectivity.Idle
+	for state != connectivity.Ready {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		require.True(t, client.WaitForStateChange(ctx, state))
+		cancel()
+		next := client.GetState()
+		assert.Contains(t, []connectivity.State{connectivity.Connecting, connectivity.Ready}, next)
+		state = next
+	}
+
+	require.NoError(t, client.Close())
+	assert.Equal(t, connectivity.Shutdown, client.GetState())
+	assert.True(t, client.WaitForStateChange(context.Background(), connectivity.Ready))
+}
@@ -0,0 +1,133 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// dnsResolveInterval dns 定期重新解析的间隔
+const dnsResolveInterval = 30 * time.Second
+
+// dnsBuilder dns:///host:port 或 dns://dns-server:53/host:port，
+// 解析 host 得到的每个 IP 与 port 组成一个地址，定期和在连接失败时重新解析
+type dnsBuilder struct{}
+
+func (dnsBuilder) Scheme() string { return "dns" }
+
+func (dnsBuilder) Build(target Target, cc ClientConn) (Resolver, error) {
+	host, port, err := net.SplitHostPort(target.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("dns: 地址格式错误: %w", err)
+	}
+
+	lookup := net.DefaultResolver
+	if target.Authority != "" {
+		// 使用指定的 DNS 服务器
+		server := target.Authority
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		lookup = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	return newDNSResolver(host, port, cc, lookup.LookupHost, dnsResolveInterval), nil
+}
+
+func newDNSResolver(host, port string, cc ClientConn, lookup func(context.Context, string) ([]string, error), interval time.Duration) *dnsResolver {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &dnsResolver{
+		host:     host,
+		port:     port,
+		cc:       cc,
+		lookup:   lookup,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+		rn:       make(chan struct{}, 1),
+	}
+	r.wg.Add(1)
+	go r.watch()
+	return r
+}
+
+type dnsResolver struct {
+	host     string
+	port     string
+	cc       ClientConn
+	lookup   func(ctx context.Context, host string) ([]string, error)
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+	rn       chan struct{} // ResolveNow 的通知
+	wg       sync.WaitGroup
+}
+
+// watch 立即解析一次，之后定期或在 ResolveNow 时重新解析
+func (r *dnsResolver) watch() {
+	defer r.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var last []string
+	for {
+		select {
+		case <-timer.C:
+		case <-r.rn:
+			timer.Stop()
+		case <-r.ctx.Done():
+			return
+		}
+
+		addrs, err := r.resolve()
+		switch {
+		case err != nil:
+			r.cc.ReportError(err)
+		case !slices.Equal(addrs, last):
+			// 地址没有变化时不推送
+			state := State{Addresses: make([]Address, len(addrs))}
+			for i, addr := range addrs {
+				state.Addresses[i] = Address{Addr: addr}
+			}
+			if r.cc.UpdateState(state) == nil {
+				last = addrs
+			}
+		}
+		timer.Reset(r.interval)
+	}
+}
+
+func (r *dnsResolver) resolve() ([]string, error) {
+	ips, err := r.lookup(r.ctx, r.host)
+	if err != nil {
+		return nil, fmt.Errorf("dns: 解析 %s 失败: %w", r.host, err)
+	}
+
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip, r.port)
+	}
+	slices.Sort(addrs)
+	return addrs, nil
+}
+
+func (r *dnsResolver) ResolveNow() {
+	select {
+	case r.rn <- struct{}{}:
+	default:
+	}
+}
+
+func (r *dnsResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
@@ -0,0 +1,125 @@
+package resolver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileWatchInterval file 检查文件是否变化的间隔
+const fileWatchInterval = 5 * time.Second
+
+// fileBuilder file:///etc/trpc/users.json，从 JSON 文件中读取地址列表，文件修改后重新读取。文件格式：
+//
+//	{"addresses": [{"addr": "10.0.0.1:50051", "weight": 2}, {"addr": "10.0.0.2:50051"}]}
+type fileBuilder struct{}
+
+func (fileBuilder) Scheme() string { return "file" }
+
+func (fileBuilder) Build(target Target, cc ClientConn) (Resolver, error) {
+	path := target.URL.Path
+	if path == "" {
+		return nil, errors.New("file: 文件路径为空")
+	}
+
+	r := &fileResolver{
+		path:     path,
+		cc:       cc,
+		interval: fileWatchInterval,
+		done:     make(chan struct{}),
+		rn:       make(chan struct{}, 1),
+	}
+	// 第一次读取失败时直接返回错误，之后读取失败时保留之前的地址
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+// fileContent 地址文件的格式
+type fileContent struct {
+	Addresses []struct {
+		Addr   string `json:"addr"`
+		Weight int    `json:"weight"`
+	} `json:"addresses"`
+}
+
+type fileResolver struct {
+	path     string
+	cc       ClientConn
+	interval time.Duration
+	done     chan struct{}
+	rn       chan struct{} // ResolveNow 的通知
+	wg       sync.WaitGroup
+
+	modTime time.Time // 最后一次读取的文件的修改时间
+	size    int64
+}
+
+// load 文件的修改时间或大小变化时重新读取并推送地址
+func (r *fileResolver) load() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return fmt.Errorf("file: %w", err)
+	}
+	if info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return nil
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return fmt.Errorf("file: %w", err)
+	}
+	var content fileContent
+	if err := json.Unmarshal(data, &content); err != nil {
+		return fmt.Errorf("file: 解析 %s 失败: %w", r.path, err)
+	}
+
+	state := State{Addresses: make([]Address, 0, len(content.Addresses))}
+	for _, a := range content.Addresses {
+		if a.Addr == "" {
+			return fmt.Errorf("file: %s 中有空地址", r.path)
+		}
+		state.Addresses = append(state.Addresses, Address{Addr: a.Addr, Weight: a.Weight})
+	}
+	if err := r.cc.UpdateState(state); err != nil {
+		return err
+	}
+	r.modTime, r.size = info.ModTime(), info.Size()
+	return nil
+}
+
+func (r *fileResolver) watch() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-r.rn:
+		case <-r.done:
+			return
+		}
+		if err := r.load(); err != nil {
+			r.cc.ReportError(err)
+		}
+	}
+}
+
+func (r *fileResolver) ResolveNow() {
+	select {
+	case r.rn <- struct{}{}:
+	default:
+	}
+}
+
+func (r *fileResolver) Close() {
+	close(r.done)
+	r.wg.Wait()
+}
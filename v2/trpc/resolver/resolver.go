@@ -0,0 +1,117 @@
+// Package resolver 定义客户端的名称解析接口。
+//
+// 客户端的目标地址为 URI 时（如 dns:///users.internal:50051），根据 scheme 选择注册的 Builder 创建 Resolver，
+// Resolver 在地址变化时通过 ClientConn.UpdateState 将最新的地址列表推送给客户端。
+// 内置 static、dns、file 三种 scheme，第三方可以通过 Register 注册自定义的 scheme
+package resolver
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Address 解析得到的一个服务端地址
+type Address struct {
+	// Addr 服务端地址，格式为 host:port
+	Addr string
+	// Weight 权重，用于加权的负载均衡策略，不大于 0 时视为 1
+	Weight int
+}
+
+// State 解析的结果
+type State struct {
+	// Addresses 当前所有可用的地址，客户端会关闭不在列表中的地址的连接
+	Addresses []Address
+}
+
+// ClientConn 客户端提供给 Resolver 的回调，可以在任意 goroutine 中调用
+type ClientConn interface {
+	// UpdateState 推送最新的地址列表
+	UpdateState(State) error
+	// ReportError 报告解析失败，客户端保留之前的地址
+	ReportError(error)
+}
+
+// Target 解析后的目标地址，如 dns://8.8.8.8/users.internal:50051 中
+// Scheme 为 dns，Authority 为 8.8.8.8，Endpoint 为 users.internal:50051
+type Target struct {
+	Scheme    string
+	Authority string
+	Endpoint  string
+	URL       url.URL
+}
+
+// ParseTarget 解析 scheme://authority/endpoint 格式的目标地址
+func ParseTarget(target string) (Target, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return Target{}, fmt.Errorf("目标地址格式错误: %w", err)
+	}
+	if u.Scheme == "" || !strings.Contains(target, "://") {
+		return Target{}, fmt.Errorf("目标地址缺少 scheme: %s", target)
+	}
+
+	endpoint := u.Path
+	if endpoint == "" {
+		endpoint = u.Opaque
+	}
+	return Target{
+		Scheme:    u.Scheme,
+		Authority: u.Host,
+		Endpoint:  strings.TrimPrefix(endpoint, "/"),
+		URL:       *u,
+	}, nil
+}
+
+// Builder 为某个 scheme 创建 Resolver
+type Builder interface {
+	// Build 创建 Resolver，Resolver 应尽快通过 cc 推送第一次解析的结果
+	Build(target Target, cc ClientConn) (Resolver, error)
+	// Scheme 返回支持的 scheme，不区分大小写
+	Scheme() string
+}
+
+// Resolver 监听目标地址的变化
+type Resolver interface {
+	// ResolveNow 提示立即重新解析，客户端在连接失败时调用，可以忽略。不能阻塞
+	ResolveNow()
+	// Close 停止解析，之后不再调用 ClientConn
+	Close()
+}
+
+var (
+	mu       sync.RWMutex
+	builders = make(map[string]Builder)
+)
+
+// Register 注册 Builder，同一个 scheme 后注册的覆盖先注册的。
+// 只应该在 init 函数中调用
+func Register(b Builder) {
+	if b == nil {
+		panic("resolver: Register 的 Builder 为 nil")
+	}
+
+	scheme := strings.ToLower(b.Scheme())
+	if scheme == "" {
+		panic("resolver: scheme 不能为空")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	builders[scheme] = b
+}
+
+// Get 返回 scheme 对应的 Builder，没有注册时返回 nil
+func Get(scheme string) Builder {
+	mu.RLock()
+	defer mu.RUnlock()
+	return builders[strings.ToLower(scheme)]
+}
+
+func init() {
+	Register(staticBuilder{})
+	Register(dnsBuilder{})
+	Register(fileBuilder{})
+}
@@ -0,0 +1,296 @@
+//go:build unit
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClientConn 记录 resolver 推送的结果
+type testClientConn struct {
+	states chan State
+	errs   chan error
+
+	mu        sync.Mutex
+	updateErr error // UpdateState 返回的错误
+}
+
+func newTestClientConn() *testClientConn {
+	return &testClientConn{states: make(chan State, 10), errs: make(chan error, 10)}
+}
+
+func (cc *testClientConn) UpdateState(s State) error {
+	cc.mu.Lock()
+	err := cc.updateErr
+	cc.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	cc.states <- s
+	return nil
+}
+
+func (cc *testClientConn) ReportError(err error) {
+	cc.errs <- err
+}
+
+func (cc *testClientConn) waitState(t *testing.T) State {
+	t.Helper()
+	select {
+	case s := <-cc.states:
+		return s
+	case <-time.After(time.Second):
+		t.Fatal("没有收到地址更新")
+		return State{}
+	}
+}
+
+func addrsOf(s State) []string {
+	addrs := make([]string, len(s.Addresses))
+	for i, a := range s.Addresses {
+		addrs[i] = a.Addr
+	}
+	return addrs
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		want    Target
+		wantErr bool
+	}{
+		{
+			name:   "static",
+			target: "static:///a:1,b:2",
+			want:   Target{Scheme: "static", Endpoint: "a:1,b:2"},
+		},
+		{
+			name:   "dns",
+			target: "dns:///users.internal:50051",
+			want:   Target{Scheme: "dns", Endpoint: "users.internal:50051"},
+		},
+		{
+			name:   "dns-指定服务器",
+			target: "dns://8.8.8.8:53/users.internal:50051",
+			want:   Target{Scheme: "dns", Authority: "8.8.8.8:53", Endpoint: "users.internal:50051"},
+		},
+		{
+			name:   "file",
+			target: "file:///etc/trpc/users.json",
+			want:   Target{Scheme: "file", Endpoint: "etc/trpc/users.json"},
+		},
+		{
+			name:    "没有scheme-失败",
+			target:  "localhost:50051",
+			wantErr: true,
+		},
+		{
+			name:    "格式错误-失败",
+			target:  "dns://[::1/users",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTarget(tt.target)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.Scheme, got.Scheme)
+			assert.Equal(t, tt.want.Authority, got.Authority)
+			assert.Equal(t, tt.want.Endpoint, got.Endpoint)
+		})
+	}
+}
+
+type testBuilder struct{ scheme string }
+
+func (b testBuilder) Build(Target, ClientConn) (Resolver, error) { return nil, nil }
+
+func (b testBuilder) Scheme() string { return b.scheme }
+
+func TestRegister(t *testing.T) {
+	assert.NotNil(t, Get("static"))
+	assert.NotNil(t, Get("DNS"))
+	assert.NotNil(t, Get("file"))
+	assert.Nil(t, Get("consul"))
+
+	Register(testBuilder{scheme: "Consul"})
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(builders, "consul")
+		mu.Unlock()
+	})
+	assert.Equal(t, testBuilder{scheme: "Consul"}, Get("consul"))
+
+	assert.Panics(t, func() { Register(nil) })
+	assert.Panics(t, func() { Register(testBuilder{}) })
+}
+
+func TestStatic(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "多个地址", endpoint: "a:1,b:2", want: []string{"a:1", "b:2"}},
+		{name: "忽略空白", endpoint: " a:1, ,b:2 ", want: []string{"a:1", "b:2"}},
+		{name: "空地址-失败", endpoint: ",", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cc := newTestClientConn()
+			r, err := staticBuilder{}.Build(Target{Scheme: "static", Endpoint: tt.endpoint}, cc)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer r.Close()
+			assert.Equal(t, tt.want, addrsOf(cc.waitState(t)))
+		})
+	}
+}
+
+func TestDNS(t *testing.T) {
+	var mu sync.Mutex
+	ips := []string{"10.0.0.2", "10.0.0.1"}
+	var lookupErr error
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "users.internal", host)
+		return ips, lookupErr
+	}
+
+	cc := newTestClientConn()
+	r := newDNSResolver("users.internal", "50051", cc, lookup, time.Hour)
+	defer r.Close()
+
+	// 立即解析一次，地址排序后推送
+	assert.Equal(t, []string{"10.0.0.1:50051", "10.0.0.2:50051"}, addrsOf(cc.waitState(t)))
+
+	// 地址变化后 ResolveNow 推送新的地址
+	mu.Lock()
+	ips = []string{"10.0.0.3"}
+	mu.Unlock()
+	r.ResolveNow()
+	assert.Equal(t, []string{"10.0.0.3:50051"}, addrsOf(cc.waitState(t)))
+
+	// 解析失败时报告错误
+	mu.Lock()
+	lookupErr = errors.New("no such host")
+	mu.Unlock()
+	r.ResolveNow()
+	select {
+	case err := <-cc.errs:
+		assert.ErrorContains(t, err, "no such host")
+	case <-time.After(time.Second):
+		t.Fatal("没有报告解析错误")
+	}
+
+	// 地址没有变化时不推送
+	mu.Lock()
+	lookupErr = nil
+	mu.Unlock()
+	r.ResolveNow()
+	select {
+	case s := <-cc.states:
+		t.Fatalf("地址没有变化时推送了 %v", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDNS_Localhost(t *testing.T) {
+	target, err := ParseTarget("dns:///localhost:50051")
+	require.NoError(t, err)
+
+	cc := newTestClientConn()
+	r, err := dnsBuilder{}.Build(target, cc)
+	require.NoError(t, err)
+	defer r.Close()
+
+	addrs := addrsOf(cc.waitState(t))
+	assert.Contains(t, addrs, "127.0.0.1:50051")
+
+	_, err = dnsBuilder{}.Build(Target{Scheme: "dns", Endpoint: "localhost"}, cc)
+	assert.Error(t, err)
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	writeFile := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	writeFile(`{"addresses": [{"addr": "10.0.0.1:50051"}, {"addr": "10.0.0.2:50051"}]}`)
+
+	target, err := ParseTarget("file://" + path)
+	require.NoError(t, err)
+
+	cc := newTestClientConn()
+	r, err := fileBuilder{}.Build(target, cc)
+	require.NoError(t, err)
+	defer r.Close()
+	assert.Equal(t, []string{"10.0.0.1:50051", "10.0.0.2:50051"}, addrsOf(cc.waitState(t)))
+
+	// 文件没有变化时不推送
+	r.ResolveNow()
+	select {
+	case s := <-cc.states:
+		t.Fatalf("文件没有变化时推送了 %v", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 文件修改后推送新的地址和权重
+	writeFile(`{"addresses": [{"addr": "10.0.0.3:50051", "weight": 3}]}`)
+	r.ResolveNow()
+	assert.Equal(t, []Address{{Addr: "10.0.0.3:50051", Weight: 3}}, cc.waitState(t).Addresses)
+
+	// 文件格式错误时报告错误
+	writeFile(`{"addresses": [`)
+	r.ResolveNow()
+	select {
+	case err := <-cc.errs:
+		assert.ErrorContains(t, err, "解析")
+	case <-time.After(time.Second):
+		t.Fatal("没有报告文件格式错误")
+	}
+}
+
+func TestFile_BuildError(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte(`{"addresses": [{"addr": ""}]}`), 0o644))
+
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{name: "文件不存在-失败", target: "file://" + filepath.Join(dir, "missing.json")},
+		{name: "空地址-失败", target: "file://" + invalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := ParseTarget(tt.target)
+			require.NoError(t, err)
+			_, err = fileBuilder{}.Build(target, newTestClientConn())
+			assert.Error(t, err)
+		})
+	}
+}
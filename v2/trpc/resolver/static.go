@@ -0,0 +1,34 @@
+package resolver
+
+import (
+	"errors"
+	"strings"
+)
+
+// staticBuilder static:///a:1,b:2，地址列表固定为 endpoint 中逗号分隔的地址
+type staticBuilder struct{}
+
+func (staticBuilder) Scheme() string { return "static" }
+
+func (staticBuilder) Build(target Target, cc ClientConn) (Resolver, error) {
+	var addrs []Address
+	for _, addr := range strings.Split(target.Endpoint, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, Address{Addr: addr})
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("static: 地址列表为空")
+	}
+
+	if err := cc.UpdateState(State{Addresses: addrs}); err != nil {
+		return nil, err
+	}
+	return staticResolver{}, nil
+}
+
+type staticResolver struct{}
+
+func (staticResolver) ResolveNow() {}
+
+func (staticResolver) Close() {}
@@ -2,21 +2,49 @@ package trpc
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+	"v2/api"
+	"v2/trpc/codec"
+	"v2/trpc/codes"
+	"v2/trpc/metadata"
+	"v2/trpc/peer"
+	"v2/trpc/status"
 )
 
+var ErrServerClosed = errors.New("服务已关闭")
+
+// tlsHandshakeTimeout 服务端 TLS 握手的超时时间
+const tlsHandshakeTimeout = 10 * time.Second
+
 type Server struct {
-	listener net.Listener
-	services map[string]any
+	opts     serverOptions
+	unaryInt UnaryServerInterceptor // 组合后的拦截器，没有拦截器时为 nil
+	listener net.Listener           // NewServer 创建的监听，由 Start 使用；NewServerWithoutListener 创建时为 nil
+
+	mu        sync.Mutex
+	services  map[string]*service       // 注册的服务，Start 之后不再修改
+	serving   bool                      // 调用 Start 或 Serve 后不再接受服务注册
+	listeners map[net.Listener]struct{} // Serve 中正在接受连接的监听
+	closed    bool                      // 调用 Stop/GracefulStop 后不再接受新的连接和请求
+	conns     map[*serverConn]struct{}  // 存活的连接
+	connWg    sync.WaitGroup            // 等待所有连接的处理 goroutine 退出
+	callsWg   sync.WaitGroup            // 等待所有正在处理的请求完成
 }
 
-func NewServer(network, targetAddr string) (*Server, error) {
-	if network != "tcp" {
+// NewServer 在 network 的 targetAddr 上创建监听，调用 Start 后开始接受连接。
+// network 支持 tcp、tcp4、tcp6 和 unix，unix 的 targetAddr 为 socket 文件的路径
+func NewServer(network, targetAddr string, opts ...ServerOption) (*Server, error) {
+	if !supportedNetwork(network) {
 		return nil, errors.New("不支持的协议")
 	}
 
@@ -30,110 +58,586 @@ func NewServer(network, targetAddr string) (*Server, error) {
 		return nil, err
 	}
 
+	server := NewServerWithoutListener(opts...)
+	server.listener = listener
+	return server, nil
+}
+
+// NewServerWithoutListener 创建不带监听的服务，通过 Serve 在调用方创建的监听上接受连接
+func NewServerWithoutListener(opts ...ServerOption) *Server {
 	server := &Server{
-		listener: listener,
-		services: make(map[string]any),
+		opts:      defaultServerOptions(),
+		services:  make(map[string]*service),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*serverConn]struct{}),
 	}
-	return server, nil
+	for _, opt := range opts {
+		opt(&server.opts)
+	}
+	server.unaryInt = chainUnaryServerInterceptors(server.opts.unaryInterceptors)
+	return server
 }
 
-func (s *Server) RegisterService(serverName string, impl any) {
-	s.services[serverName] = impl
+// supportedNetwork 服务端和客户端支持的网络类型
+func supportedNetwork(network string) bool {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		return true
+	}
+	return false
 }
 
+// RegisterService 注册服务，只有 desc.HandlerType 接口中声明的方法可以被远程调用，
+// 注册时检查 impl 是否实现了该接口以及方法是否符合约定的签名。
+// 同名服务不能重复注册，Start 之后不能再注册服务
+func (s *Server) RegisterService(desc *api.ServiceDesc, impl any) error {
+	svc, err := newService(desc, impl)
+	if err != nil {
+		return err
+	}
+	serverName := svc.name
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.serving || s.closed {
+		return fmt.Errorf("服务已启动，不能注册service:%s", serverName)
+	}
+	if _, ok := s.services[serverName]; ok {
+		return fmt.Errorf("service:%s 重复注册", serverName)
+	}
+	s.services[serverName] = svc
+	return nil
+}
+
+// Start 在 NewServer 创建的监听上接受连接并处理请求，直到服务关闭。
+// 调用 Stop 或 GracefulStop 后返回 ErrServerClosed
 func (s *Server) Start() error {
-	if len(s.services) == 0 {
+	if s.listener == nil {
+		return errors.New("没有监听地址，请使用 Serve")
+	}
+	return s.Serve(s.listener)
+}
+
+// Serve 在 lis 上接受连接并处理请求，直到服务关闭或 lis 返回错误，返回时关闭 lis。
+// 可以在多个监听上同时调用，调用 Stop 或 GracefulStop 后返回 ErrServerClosed
+func (s *Server) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	s.serving = true
+	n := len(s.services)
+	closed := s.closed
+	if !closed {
+		s.listeners[lis] = struct{}{}
+	}
+	s.mu.Unlock()
+
+	if closed {
+		lis.Close()
+		return ErrServerClosed
+	}
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, lis)
+		s.mu.Unlock()
+		lis.Close()
+	}()
+
+	if n == 0 {
 		return errors.New("没有注册Services")
 	}
 
 	for {
-		conn, err := s.listener.Accept()
+		conn, err := lis.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		// 握手在处理连接的 goroutine 中进行，避免慢速的客户端阻塞 Accept
+		if s.opts.tlsConfig != nil {
+			conn = tls.Server(conn, s.opts.tlsConfig)
+		}
+		sc := newServerConn(conn, s.opts.maxMessageSize)
+		if !s.addConn(sc) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(sc)
+	}
+}
+
+// Stop 立即关闭服务：停止监听，断开所有连接，正在处理的请求的 ctx 会被取消
+func (s *Server) Stop() {
+	s.closeListeners()
+	s.closeConns()
+	s.connWg.Wait()
+}
+
+// GracefulStop 优雅关闭服务：停止监听和接受新的请求，等待正在处理的请求完成后断开所有连接。
+// ctx 结束时仍有请求未完成，则退化为 Stop 并返回 ctx.Err()
+func (s *Server) GracefulStop(ctx context.Context) error {
+	s.closeListeners()
+
+	done := make(chan struct{})
+	go func() {
+		s.callsWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.Stop()
+		return ctx.Err()
+	}
+
+	s.closeConns()
+	s.connWg.Wait()
+	return nil
+}
+
+// closeListeners 标记服务已关闭并关闭所有监听
+func (s *Server) closeListeners() {
+	s.mu.Lock()
+	s.closed = true
+	listeners := make([]net.Listener, 0, len(s.listeners)+1)
+	for lis := range s.listeners {
+		listeners = append(listeners, lis)
+	}
+	s.mu.Unlock()
+
+	if s.listener != nil {
+		listeners = append(listeners, s.listener)
+	}
+	for _, lis := range listeners {
+		lis.Close()
+	}
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) addConn(conn *serverConn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.connWg.Add(1)
+	return true
+}
+
+func (s *Server) removeConn(conn *serverConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, conn)
+	s.connWg.Done()
+}
+
+func (s *Server) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		conn.cancel()
+		conn.Close()
+	}
+}
+
+// beginCall 登记一个正在处理的请求，服务关闭后返回 false
+func (s *Server) beginCall() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.callsWg.Add(1)
+	return true
+}
+
+// serverConn 服务端的一个连接，同一连接上的请求并发处理，写入时加锁保证帧的完整性
+type serverConn struct {
+	net.Conn
+	maxMessageSize int
+	writeMu        sync.Mutex
+
+	// ctx 在连接断开时被取消，连接上所有请求的 ctx 都派生自它
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	streams map[uint64]*serverStream // 正在处理的流
+}
+
+func newServerConn(conn net.Conn, maxMessageSize int) *serverConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &serverConn{
+		Conn:           conn,
+		maxMessageSize: maxMessageSize,
+		ctx:            ctx,
+		cancel:         cancel,
+		streams:        make(map[uint64]*serverStream),
+	}
+}
+
+// send 将一元调用的响应编码为一个完整的帧写入连接
+func (sc *serverConn) send(codecName string, r *Reply) error {
+	return sc.sendFrame(frameUnary, codecName, r.Marshal())
+}
+
+// sendFrame 写入一个完整的帧，帧只写入了一部分时连接上的数据已经不完整，断开连接
+func (sc *serverConn) sendFrame(flags uint8, codecName string, payload []byte) error {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+
+	err := writeFrame(sc.Conn, flags, codecName, payload, sc.maxMessageSize)
+	if err != nil && !errors.Is(err, ErrMessageTooLarge) {
+		sc.Close()
+	}
+	return err
+}
+
+func (sc *serverConn) addStream(ss *serverStream) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.streams[ss.id] = ss
+}
+
+func (sc *serverConn) getStream(id uint64) *serverStream {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.streams[id]
+}
+
+func (sc *serverConn) removeStream(id uint64) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	delete(sc.streams, id)
+}
+
+func (s *Server) serveConn(conn *serverConn) {
+	log.Printf("新连接进来 localAddr %s remoteAddr %s\n", conn.LocalAddr(), conn.RemoteAddr())
+	defer func() {
+		log.Printf("连接断开 localAddr %s remoteAddr %s\n", conn.LocalAddr(), conn.RemoteAddr())
+		conn.cancel()
+		conn.Close()
+		s.removeConn(conn)
+	}()
+
+	p := &peer.Peer{Addr: conn.RemoteAddr()}
+	if tlsConn, ok := conn.Conn.(*tls.Conn); ok {
+		ctx, cancel := context.WithTimeout(conn.ctx, tlsHandshakeTimeout)
+		err := tlsConn.HandshakeContext(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("TLS 握手失败: %v", err)
+			return
+		}
+		state := tlsConn.ConnectionState()
+		p.TLS = &state
+	}
+	// 连接上所有请求的 ctx 都带有对端信息，之后才开始读取请求，不会与请求的处理并发
+	conn.ctx = peer.NewContext(conn.ctx, p)
+
+	for {
+		if err := s.recv(conn); err != nil {
+			log.Printf("Server recv error: %v", err)
+			return
+		}
+	}
+}
+
+// recv 读取一个完整的请求帧，一元调用和流在独立的 goroutine 中处理，
+// 响应通过 Seq 与请求对应，因此同一连接上的多个请求可以并发执行、乱序返回。
+// 流中的消息按照收到的顺序放入流的接收缓冲
+func (s *Server) recv(conn *serverConn) error {
+	f, err := readFrame(conn, s.opts.maxMessageSize)
+	if err != nil {
+		return err
+	}
+
+	switch f.flags {
+	case frameUnary:
+		a, err := UnmarshalApply(f.payload)
 		if err != nil {
 			return err
 		}
 
+		// 服务正在关闭，拒绝新的请求
+		if !s.beginCall() {
+			r := NewErrorReply(status.New(codes.Unavailable, "服务正在关闭"))
+			r.Seq = a.Seq
+			return conn.send(f.codec, r)
+		}
+
 		go func() {
-			log.Printf("新连接进来 localAddr %s remoteAddr %s\n", conn.LocalAddr(), conn.RemoteAddr())
-			defer func() {
-				log.Printf("连接断开 localAddr %s remoteAddr %s\n", conn.LocalAddr(), conn.RemoteAddr())
+			defer s.callsWg.Done()
+			if err := s.handle(conn, f.codec, a); err != nil {
+				log.Printf("Server handle error: %v", err)
 				conn.Close()
-			}()
-			for {
-				if err := s.recv(conn); err != nil {
-					log.Printf("Server recv error: %v", err)
-					return
-				}
 			}
 		}()
+	case frameStreamOpen:
+		a, err := UnmarshalApply(f.payload)
+		if err != nil {
+			return err
+		}
+		return s.openStream(conn, f.codec, a)
+	case frameStreamMessage:
+		id, data, err := readUvarint(f.payload)
+		if err != nil {
+			return err
+		}
+		// 流已经结束时丢弃；客户端没有遵守流量控制时取消服务方法的 ctx，以 codes.ResourceExhausted 结束流
+		if ss := conn.getStream(id); ss != nil && !ss.recv.put(data) {
+			ss.cancel()
+		}
+	case frameStreamWindowUpdate:
+		id, n, err := parseWindowUpdate(f.payload)
+		if err != nil {
+			return err
+		}
+		if ss := conn.getStream(id); ss != nil {
+			ss.quota.add(n)
+		}
+	case frameStreamCloseSend:
+		id, _, err := readUvarint(f.payload)
+		if err != nil {
+			return err
+		}
+		if ss := conn.getStream(id); ss != nil {
+			ss.recv.close(io.EOF)
+		}
+	case frameStreamCancel:
+		id, _, err := readUvarint(f.payload)
+		if err != nil {
+			return err
+		}
+		if ss := conn.getStream(id); ss != nil {
+			ss.cancel()
+		}
+	default:
+		return fmt.Errorf("未知的帧类型: %d", f.flags)
+	}
+	return nil
+}
+
+// openStream 创建流并在独立的 goroutine 中调用流式方法
+func (s *Server) openStream(conn *serverConn, codecName string, a *Apply) error {
+	// 服务正在关闭，拒绝新的流
+	if !s.beginCall() {
+		r := NewErrorReply(status.New(codes.Unavailable, "服务正在关闭"))
+		r.Seq = a.Seq
+		return conn.sendFrame(frameStreamEnd, codecName, r.Marshal())
 	}
+
+	// 客户端取消流时通过 cancel 结束服务方法的 ctx
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if a.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(conn.ctx, a.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(conn.ctx)
+	}
+	ctx = metadata.NewIncomingContext(ctx, a.Metadata)
+	call := &serverCall{}
+	ctx = newServerCallContext(ctx, call)
+
+	ss := &serverStream{
+		conn:      conn,
+		id:        a.Seq,
+		codecName: codecName,
+		ctx:       ctx,
+		cancel:    cancel,
+		call:      call,
+		recv:      newRecvBuffer(recvBufferLimit(conn.maxMessageSize)),
+		quota:     newSendQuota(),
+	}
+	conn.addStream(ss)
+
+	go func() {
+		defer s.callsWg.Done()
+		defer conn.removeStream(ss.id)
+		defer cancel()
+		if err := s.handleStream(ss, a.ServiceName, a.MethodName); err != nil {
+			log.Printf("Server handle stream error: %v", err)
+		}
+	}()
+	return nil
 }
 
-func (s *Server) recv(conn net.Conn) error {
-	// 读取请求（暂时简化）
-	buf := make([]byte, 1024)
-	n, err := conn.Read(buf)
+// handle 调用服务方法并将响应写回连接
+// 调用失败或响应超过大小限制时将错误转换为 Status 返回给客户端，连接保持可用；只有写入连接失败时返回错误
+func (s *Server) handle(conn *serverConn, codecName string, a *Apply) error {
+	ctx := conn.ctx
+	if a.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.Timeout)
+		defer cancel()
+	}
+
+	// 客户端发送的元数据通过 ctx 传给服务方法，服务方法设置的响应头和响应尾随响应返回
+	ctx = metadata.NewIncomingContext(ctx, a.Metadata)
+	call := &serverCall{}
+	ctx = newServerCallContext(ctx, call)
+
+	var r *Reply
+	c, err := s.getCodec(codecName)
+	if err == nil {
+		var reply any
+		reply, err = s.call(ctx, c, a.Args, a.ServiceName, a.MethodName)
+		if err == nil {
+			r, err = NewReply(reply, c)
+			if err != nil {
+				err = status.Errorf(codes.Internal, "响应序列化失败: %v", err)
+			}
+		}
+	}
+
 	if err != nil {
+		r = NewErrorReply(status.Convert(err))
+	}
+	r.Seq = a.Seq
+	r.Header, r.Trailer = call.metadata()
+	err = conn.send(codecName, r)
+	if !errors.Is(err, ErrMessageTooLarge) {
 		return err
 	}
-	buf = buf[:n]
 
-	var a Apply
-	if err := json.Unmarshal(buf, &a); err != nil {
-		return err
+	// 响应超过大小限制时帧没有写入，连接仍然完整，改为返回错误，不影响连接上的其他调用和流
+	er := NewErrorReply(status.Newf(codes.ResourceExhausted, "响应超过大小限制: %v", err))
+	er.Seq = a.Seq
+	return conn.send(codecName, er)
+}
+
+// handleStream 调用流式方法，方法返回后发送结束帧，
+// 结束帧中带有调用结果、还没有发送的响应头和响应尾；客户端没有遵守流量控制导致流被重置时结果为 codes.ResourceExhausted
+func (s *Server) handleStream(ss *serverStream, serviceName, methodName string) error {
+	c, err := s.getCodec(ss.codecName)
+	if err == nil {
+		ss.codec = c
+		err = s.callStream(ss, serviceName, methodName)
+	}
+	if overflow := ss.recv.overflowErr(); overflow != nil {
+		err = overflow
 	}
 
-	serviceName := a.ServiceName
-	methodName := a.MethodName
-	args := a.Args
-	reply, err := s.call(args, serviceName, methodName)
+	r := &Reply{}
 	if err != nil {
+		r = NewErrorReply(status.Convert(err))
+	}
+	r.Seq = ss.id
+	r.Header, _ = ss.call.sendHeader()
+	_, r.Trailer = ss.call.metadata()
+	err = ss.conn.sendFrame(frameStreamEnd, ss.codecName, r.Marshal())
+	if !errors.Is(err, ErrMessageTooLarge) {
 		return err
 	}
 
-	resp, _ := json.Marshal(reply)
-	conn.Write(resp)
-	return nil
+	// 响应头和响应尾过大时不带元数据结束流，连接保持可用
+	er := NewErrorReply(status.Newf(codes.ResourceExhausted, "响应超过大小限制: %v", err))
+	er.Seq = ss.id
+	return ss.conn.sendFrame(frameStreamEnd, ss.codecName, er.Marshal())
 }
 
-func (s *Server) call(args []byte, serviceName string, methodName string) (any, error) {
-	if len(args) <= 0 {
-		return nil, errors.New("没有传参数")
+// handlePanic 使用 RecoveryHandler 设置的处理函数处理 panic，没有设置时记录调用栈并返回 codes.Internal
+func (s *Server) handlePanic(ctx context.Context, p any) error {
+	if s.opts.panicHandler != nil {
+		return s.opts.panicHandler(ctx, p)
 	}
+	log.Printf("Server handler panic: %v\n%s", p, debug.Stack())
+	return status.Error(codes.Internal, "服务内部错误")
+}
+
+// getCodec 根据请求帧中的编码名称获取 codec，CustomCodec 添加的优先
+func (s *Server) getCodec(name string) (codec.Codec, error) {
+	if c, ok := s.opts.codecs[strings.ToLower(name)]; ok {
+		return c, nil
+	}
+	if c := codec.Get(name); c != nil {
+		return c, nil
+	}
+	return nil, status.Errorf(codes.Unimplemented, "不支持的编码:%s", name)
+}
 
-	service, ok := s.services[serviceName]
+// call 查找并调用一元方法，服务方法或拦截器 panic 时转换为错误返回
+func (s *Server) call(ctx context.Context, c codec.Codec, args []byte, serviceName string, methodName string) (resp any, err error) {
+	// Start 之后 services 不再修改，无需加锁
+	svc, ok := s.services[serviceName]
 	if !ok {
-		return nil, fmt.Errorf("不存在service:%s", serviceName)
+		return nil, status.Errorf(codes.NotFound, "不存在service:%s", serviceName)
 	}
 
-	serviceValue := reflect.ValueOf(service)
-	method := serviceValue.MethodByName(methodName)
-	if !method.IsValid() {
-		return nil, fmt.Errorf("service:%s 不存在method:%s", serviceName, methodName)
+	mt, ok := svc.methods[methodName]
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "service:%s 不存在method:%s", serviceName, methodName)
+	}
+	if !svc.implemented(mt.embedded) {
+		return nil, status.Errorf(codes.Unimplemented, "service:%s method:%s 未实现", serviceName, methodName)
 	}
 
-	ctx := context.Background()
-	// 通过反射获取方法的第二个参数类型，New出来，并通过json.Unmarshal给参数赋值
-	// 约定方法签名为：func(ctx context.Context, req *ReqType) (resp any, err error)
-	methodType := method.Type()
-	if methodType.NumIn() != 2 {
-		return nil, fmt.Errorf("service:%s method:%s 参数数量不正确", serviceName, methodName)
+	apply := reflect.New(mt.reqType)
+	if err := c.Unmarshal(args, apply.Interface()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "参数解析失败: %v", err)
 	}
 
-	// 第二个参数类型（通常是 *ReqType）
-	apply := reflect.New(methodType.In(1).Elem())
-	if err := json.Unmarshal(args, apply.Interface()); err != nil {
-		return nil, err
+	handler := func(ctx context.Context, req any) (resp any, err error) {
+		// 服务方法 panic 时转换为错误返回，服务和连接保持可用
+		defer func() {
+			if p := recover(); p != nil {
+				resp, err = nil, s.handlePanic(ctx, p)
+			}
+		}()
+		return svc.call(mt, ctx, req)
 	}
 
-	results := method.Call([]reflect.Value{reflect.ValueOf(ctx), apply})
-	if len(results) != 2 {
-		return nil, fmt.Errorf("service:%s method:%s Call Failed", serviceName, methodName)
+	if s.unaryInt == nil {
+		return handler(ctx, apply.Interface())
 	}
 
-	if err, ok := results[1].Interface().(error); ok && err != nil {
-		return nil, err
+	// 拦截器 panic 时同样转换为错误返回
+	defer func() {
+		if p := recover(); p != nil {
+			resp, err = nil, s.handlePanic(ctx, p)
+		}
+	}()
+	info := &UnaryServerInfo{
+		Server:     svc.impl,
+		FullMethod: serviceName + "." + methodName,
+	}
+	return s.unaryInt(ctx, apply.Interface(), info, handler)
+}
+
+// callStream 查找并调用流式方法，服务方法 panic 时转换为错误返回
+func (s *Server) callStream(ss *serverStream, serviceName string, methodName string) (err error) {
+	svc, ok := s.services[serviceName]
+	if !ok {
+		return status.Errorf(codes.NotFound, "不存在service:%s", serviceName)
 	}
 
-	reply := results[0].Interface()
-	return reply, nil
+	st, ok := svc.streams[methodName]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "service:%s 不存在stream:%s", serviceName, methodName)
+	}
+	if !svc.implemented(st.embedded) {
+		return status.Errorf(codes.Unimplemented, "service:%s method:%s 未实现", serviceName, methodName)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = s.handlePanic(ss.ctx, p)
+		}
+	}()
+	return st.desc.Handler(svc.impl, ss)
 }
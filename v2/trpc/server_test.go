@@ -4,9 +4,17 @@ package trpc
 
 import (
 	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+	"v2/api"
 	"v2/pb"
+	"v2/trpc/codes"
+	"v2/trpc/status"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,6 +39,18 @@ func TestNewServer(t *testing.T) {
 			addr:    "",
 			wantErr: true,
 		},
+		{
+			name:    "tcp4",
+			network: "tcp4",
+			addr:    "127.0.0.1:0",
+			wantErr: false,
+		},
+		{
+			name:    "unix socket",
+			network: "unix",
+			addr:    filepath.Join(t.TempDir(), "trpc.sock"),
+			wantErr: false,
+		},
 		{
 			name:    "不支持的协议-失败",
 			network: "udp",
@@ -42,6 +62,9 @@ func TestNewServer(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			server, err := NewServer(tt.network, tt.addr)
+			if server != nil {
+				defer server.Stop()
+			}
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -62,10 +85,19 @@ func (s *serverImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.Reply
 	return &pb.ReplyHello{Msg: "Hello, " + apply.Name + "!"}, nil
 }
 
-// createTestServer 创建测试服务器
-func createTestServer(t *testing.T) *Server {
-	server, err := NewServer("tcp", "localhost:0")
-	require.NoError(t, err)
+// newTestServer 创建监听在内存网络上的测试服务器，调用 Start 后开始接受连接，测试结束时关闭
+func newTestServer(t *testing.T, opts ...ServerOption) *Server {
+	server := NewServerWithoutListener(opts...)
+	server.listener = listenTest(t, "localhost:0")
+	t.Cleanup(server.Stop)
+	return server
+}
+
+// startTestServer 创建测试服务器，注册 impl 后在后台启动，客户端通过 newTestClient 连接 server.listener 的地址
+func startTestServer(t *testing.T, desc *api.ServiceDesc, impl any, opts ...ServerOption) *Server {
+	server := newTestServer(t, opts...)
+	require.NoError(t, server.RegisterService(desc, impl))
+	go server.Start()
 	return server
 }
 
@@ -80,14 +112,14 @@ func TestRegisterHelloServer(t *testing.T) {
 	}{
 		{
 			name:      "成功注册Hello服务",
-			server:    createTestServer(t),
+			server:    newTestServer(t),
 			service:   &serverImpl{}, // 实现了 IHelloService 的结构
 			wantErr:   false,
 			expectNil: false,
 		},
 		{
 			name:      "nil服务-失败",
-			server:    createTestServer(t),
+			server:    newTestServer(t),
 			service:   nil,
 			wantErr:   true,
 			expectNil: true,
@@ -113,11 +145,305 @@ func TestRegisterHelloServer(t *testing.T) {
 				}
 			}()
 
-			pb.RegisterHelloServer(tt.server, tt.service)
+			err := pb.RegisterHelloServer(tt.server, tt.service)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
 		})
 	}
 }
 
+// badArgService 第二个参数不是指针
+type badArgService interface {
+	Hello(ctx context.Context, apply pb.ApplyHello) (*pb.ReplyHello, error)
+}
+
+type badArgServiceImpl struct{}
+
+func (s *badArgServiceImpl) Hello(ctx context.Context, apply pb.ApplyHello) (*pb.ReplyHello, error) {
+	return nil, nil
+}
+
+// badResultService 第二个返回值不是 error，同时包含一个正确的方法
+type badResultService interface {
+	Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error)
+	Bye(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, string)
+}
+
+type badResultServiceImpl struct{}
+
+func (s *badResultServiceImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	return nil, nil
+}
+
+func (s *badResultServiceImpl) Bye(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, string) {
+	return nil, ""
+}
+
+// badCtxService 缺少 ctx 参数
+type badCtxService interface {
+	Hello(apply *pb.ApplyHello) (*pb.ReplyHello, error)
+}
+
+type badCtxServiceImpl struct{}
+
+func (s *badCtxServiceImpl) Hello(apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	return nil, nil
+}
+
+// emptyService 没有方法的服务接口
+type emptyService interface{}
+
+// helperServiceImpl 除了服务接口中的方法，还有其他导出方法，包括签名不符合约定的
+type helperServiceImpl struct {
+	serverImpl
+}
+
+func (s *helperServiceImpl) Reset(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	return &pb.ReplyHello{Msg: "reset"}, nil
+}
+
+func (s *helperServiceImpl) Close() error {
+	return nil
+}
+
+func TestServer_RegisterService(t *testing.T) {
+	tests := []struct {
+		name    string
+		desc    *api.ServiceDesc
+		impl    any
+		wantErr string
+	}{
+		{
+			name: "注册成功",
+			desc: &pb.HelloServiceDesc,
+			impl: &serverImpl{},
+		},
+		{
+			name: "实现中的其他方法不影响注册",
+			desc: &pb.HelloServiceDesc,
+			impl: &helperServiceImpl{},
+		},
+		{
+			name:    "nil服务描述-失败",
+			desc:    nil,
+			impl:    &serverImpl{},
+			wantErr: "服务描述为nil",
+		},
+		{
+			name:    "空服务名称-失败",
+			desc:    &api.ServiceDesc{HandlerType: (*pb.IHelloService)(nil)},
+			impl:    &serverImpl{},
+			wantErr: "服务名称为空",
+		},
+		{
+			name:    "HandlerType不是接口指针-失败",
+			desc:    &api.ServiceDesc{ServiceName: "hello_service", HandlerType: &serverImpl{}},
+			impl:    &serverImpl{},
+			wantErr: "HandlerType 应为接口指针",
+		},
+		{
+			name:    "没有实现服务接口-失败",
+			desc:    &pb.UserServiceDesc,
+			impl:    &serverImpl{},
+			wantErr: "没有实现",
+		},
+		{
+			name:    "nil指针-失败",
+			desc:    &pb.HelloServiceDesc,
+			impl:    (*serverImpl)(nil),
+			wantErr: "nil",
+		},
+		{
+			name:    "参数不是指针-失败",
+			desc:    &api.ServiceDesc{ServiceName: "bad_service", HandlerType: (*badArgService)(nil)},
+			impl:    &badArgServiceImpl{},
+			wantErr: "method:Hello 第二个参数应为指针",
+		},
+		{
+			name:    "返回值不是error-失败",
+			desc:    &api.ServiceDesc{ServiceName: "bad_service", HandlerType: (*badResultService)(nil)},
+			impl:    &badResultServiceImpl{},
+			wantErr: "method:Bye 第二个返回值应为 error",
+		},
+		{
+			name: "只暴露Methods中的方法",
+			desc: &api.ServiceDesc{
+				ServiceName: "bad_service",
+				HandlerType: (*badResultService)(nil),
+				Methods:     []api.MethodDesc{{MethodName: "Hello"}},
+			},
+			impl: &badResultServiceImpl{},
+		},
+		{
+			name: "Methods中的方法不在接口中-失败",
+			desc: &api.ServiceDesc{
+				ServiceName: "hello_service",
+				HandlerType: (*pb.IHelloService)(nil),
+				Methods:     []api.MethodDesc{{MethodName: "Bye"}},
+			},
+			impl:    &helperServiceImpl{},
+			wantErr: "method:Bye 不在",
+		},
+		{
+			name:    "缺少ctx参数-失败",
+			desc:    &api.ServiceDesc{ServiceName: "bad_service", HandlerType: (*badCtxService)(nil)},
+			impl:    &badCtxServiceImpl{},
+			wantErr: "method:Hello 参数数量不正确",
+		},
+		{
+			name:    "服务接口没有方法-失败",
+			desc:    &api.ServiceDesc{ServiceName: "empty_service", HandlerType: (*emptyService)(nil)},
+			impl:    &serverImpl{},
+			wantErr: "没有可调用的方法",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			err := server.RegisterService(tt.desc, tt.impl)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.Empty(t, server.services)
+			} else {
+				assert.NoError(t, err)
+				assert.Contains(t, server.services, tt.desc.ServiceName)
+			}
+		})
+	}
+}
+
+func TestServer_OnlyInterfaceMethods(t *testing.T) {
+	server := startTestServer(t, &pb.HelloServiceDesc, &helperServiceImpl{})
+
+	client := newTestClient(t, server.listener.Addr().String())
+
+	// 服务接口中的方法可以调用
+	reply := &pb.ReplyHello{}
+	err := client.Invoke(context.Background(), "hello_service.Hello", &pb.ApplyHello{Name: "Tan"}, reply)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Tan!", reply.Msg)
+
+	// 实现上的其他导出方法不能被远程调用
+	for _, method := range []string{"hello_service.Reset", "hello_service.Close"} {
+		err = client.Invoke(context.Background(), method, &pb.ApplyHello{Name: "Tan"}, reply)
+		assert.Equal(t, codes.Unimplemented, status.Code(err), method)
+	}
+}
+
+// nilEmbedServiceImpl 嵌入 nil 接口，没有实现 Hello
+type nilEmbedServiceImpl struct {
+	pb.IHelloService
+}
+
+// nilEmbedOverrideServiceImpl 嵌入 nil 接口，自己实现了 Hello
+type nilEmbedOverrideServiceImpl struct {
+	pb.IHelloService
+}
+
+func (s *nilEmbedOverrideServiceImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	return &pb.ReplyHello{Msg: "Hello, " + apply.Name + "!"}, nil
+}
+
+// valueServiceImpl 值接收者实现 Hello，同时嵌入 nil 接口
+type valueServiceImpl struct {
+	pb.IHelloService
+}
+
+func (s valueServiceImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	return &pb.ReplyHello{Msg: "Hello, " + apply.Name + "!"}, nil
+}
+
+// deepNilEmbedServiceImpl 通过多层嵌入的结构体提升 nil 接口的方法
+type deepNilEmbedServiceImpl struct {
+	*nilEmbedServiceImpl
+}
+
+// unimplementedServiceImpl 嵌入生成的 Unimplemented 类型
+type unimplementedServiceImpl struct {
+	pb.UnimplementedHelloService
+}
+
+func TestServer_UnimplementedMethod(t *testing.T) {
+	tests := []struct {
+		name     string
+		impl     pb.IHelloService
+		wantCode codes.Code
+	}{
+		{
+			name:     "嵌入nil接口且没有实现-Unimplemented",
+			impl:     &nilEmbedServiceImpl{},
+			wantCode: codes.Unimplemented,
+		},
+		{
+			name: "嵌入nil接口且自己实现了方法",
+			impl: &nilEmbedOverrideServiceImpl{},
+		},
+		{
+			name: "值接收者实现了方法",
+			impl: &valueServiceImpl{},
+		},
+		{
+			name: "嵌入非nil接口",
+			impl: &nilEmbedServiceImpl{IHelloService: &serverImpl{}},
+		},
+		{
+			name:     "多层嵌入nil接口-Unimplemented",
+			impl:     &deepNilEmbedServiceImpl{nilEmbedServiceImpl: &nilEmbedServiceImpl{}},
+			wantCode: codes.Unimplemented,
+		},
+		{
+			name:     "多层嵌入的结构体为nil-Unimplemented",
+			impl:     &deepNilEmbedServiceImpl{},
+			wantCode: codes.Unimplemented,
+		},
+		{
+			name:     "嵌入Unimplemented类型-Unimplemented",
+			impl:     &unimplementedServiceImpl{},
+			wantCode: codes.Unimplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startTestServer(t, &pb.HelloServiceDesc, tt.impl)
+
+			client := newTestClient(t, server.listener.Addr().String())
+
+			resp, err := pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "Tan"})
+			if tt.wantCode != codes.OK {
+				assert.Equal(t, tt.wantCode, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "Hello, Tan!", resp.Msg)
+		})
+	}
+}
+
+func TestServer_RegisterServiceDuplicate(t *testing.T) {
+	server := newTestServer(t)
+	require.NoError(t, pb.RegisterHelloServer(server, &serverImpl{}))
+
+	err := pb.RegisterHelloServer(server, &serverImpl{})
+	assert.ErrorContains(t, err, "重复注册")
+}
+
+func TestServer_RegisterServiceAfterStart(t *testing.T) {
+	server := startTestServer(t, &pb.HelloServiceDesc, &serverImpl{})
+
+	assert.Eventually(t, func() bool {
+		return pb.RegisterUserServer(server, &errorServiceImpl{}) != nil
+	}, time.Second, 10*time.Millisecond)
+
+	server.Stop()
+	err := pb.RegisterUserServer(server, &errorServiceImpl{})
+	assert.ErrorContains(t, err, "服务已启动")
+}
+
 func TestServer_Start(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -128,8 +454,8 @@ func TestServer_Start(t *testing.T) {
 		{
 			name: "成功启动服务并处理请求",
 			setupServer: func(t *testing.T) *Server {
-				s := createTestServer(t)
-				pb.RegisterHelloServer(s, &serverImpl{})
+				s := newTestServer(t)
+				require.NoError(t, pb.RegisterHelloServer(s, &serverImpl{}))
 				return s
 			},
 			wantErr:    false,
@@ -138,7 +464,7 @@ func TestServer_Start(t *testing.T) {
 		{
 			name: "未注册服务-启动失败",
 			setupServer: func(t *testing.T) *Server {
-				return createTestServer(t)
+				return newTestServer(t)
 			},
 			wantErr:    true,
 			testClient: false,
@@ -159,14 +485,10 @@ func TestServer_Start(t *testing.T) {
 				err := <-errChan
 				assert.Error(t, err)
 			} else {
-				// 等待服务启动
-				time.Sleep(100 * time.Millisecond)
-
+				// 服务开始 Accept 之前建立连接会等待，不需要等待服务启动
 				if tt.testClient {
 					// 测试客户端调用
-					client, err := NewClient("tcp", server.listener.Addr().String())
-					require.NoError(t, err)
-					defer client.Close()
+					client := newTestClient(t, server.listener.Addr().String())
 
 					helloClient := pb.NewHelloClient(client)
 					resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Test"})
@@ -177,3 +499,380 @@ func TestServer_Start(t *testing.T) {
 		})
 	}
 }
+
+// errorServiceImpl 测试用的服务实现，根据请求返回不同的错误
+type errorServiceImpl struct {
+	pb.UnimplementedUserService
+}
+
+func (s *errorServiceImpl) User(ctx context.Context, apply *pb.ApplyUser) (*pb.ReplyUser, error) {
+	switch apply.Uid {
+	case 0:
+		return nil, status.Error(codes.InvalidArgument, "uid不能为0")
+	case -1:
+		return nil, errors.New("普通错误")
+	}
+	return nil, status.Errorf(codes.NotFound, "uid:%d 不存在", apply.Uid)
+}
+
+func TestServer_ErrorReply(t *testing.T) {
+	server := startTestServer(t, &pb.UserServiceDesc, &errorServiceImpl{})
+
+	client := newTestClient(t, server.listener.Addr().String())
+
+	tests := []struct {
+		name     string
+		method   string
+		apply    any
+		wantCode codes.Code
+		wantMsg  string
+	}{
+		{
+			name:     "服务方法返回NotFound",
+			method:   "user_service.User",
+			apply:    &pb.ApplyUser{Uid: 3},
+			wantCode: codes.NotFound,
+			wantMsg:  "uid:3 不存在",
+		},
+		{
+			name:     "服务方法返回InvalidArgument",
+			method:   "user_service.User",
+			apply:    &pb.ApplyUser{Uid: 0},
+			wantCode: codes.InvalidArgument,
+			wantMsg:  "uid不能为0",
+		},
+		{
+			name:     "服务方法返回普通错误-Unknown",
+			method:   "user_service.User",
+			apply:    &pb.ApplyUser{Uid: -1},
+			wantCode: codes.Unknown,
+			wantMsg:  "普通错误",
+		},
+		{
+			name:     "不存在的service-NotFound",
+			method:   "no_service.User",
+			apply:    &pb.ApplyUser{Uid: 1},
+			wantCode: codes.NotFound,
+		},
+		{
+			name:     "不存在的method-Unimplemented",
+			method:   "user_service.NoMethod",
+			apply:    &pb.ApplyUser{Uid: 1},
+			wantCode: codes.Unimplemented,
+		},
+		{
+			name:     "参数类型错误-InvalidArgument",
+			method:   "user_service.User",
+			apply:    &struct{ Uid string }{Uid: "abc"},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	// 所有调用共用同一个连接，错误不应该导致连接断开
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.Invoke(context.Background(), tt.method, tt.apply, &pb.ReplyUser{})
+			require.Error(t, err)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantMsg != "" {
+				assert.Equal(t, tt.wantMsg, status.Convert(err).Message())
+			}
+		})
+	}
+}
+
+// panicServiceImpl 测试用的服务实现，Uid 为 0 时 panic
+type panicServiceImpl struct {
+	pb.UnimplementedUserService
+	users map[int64]*pb.User
+}
+
+func (s *panicServiceImpl) User(ctx context.Context, apply *pb.ApplyUser) (*pb.ReplyUser, error) {
+	if apply.Uid == 0 {
+		var m map[string]int
+		m["nil map"] = 1
+	}
+	return &pb.ReplyUser{User: s.users[apply.Uid]}, nil
+}
+
+func TestServer_PanicRecovery(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []ServerOption
+		wantCode codes.Code
+		wantMsg  string
+	}{
+		{
+			name:     "默认返回Internal",
+			wantCode: codes.Internal,
+			wantMsg:  "服务内部错误",
+		},
+		{
+			name: "自定义panic处理函数",
+			opts: []ServerOption{RecoveryHandler(func(ctx context.Context, p any) error {
+				return status.Errorf(codes.Unavailable, "recovered: %v", p)
+			})},
+			wantCode: codes.Unavailable,
+			wantMsg:  "recovered: assignment to entry in nil map",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &panicServiceImpl{users: map[int64]*pb.User{1: {Uid: 1, Name: "Tan"}}}
+			server := startTestServer(t, &pb.UserServiceDesc, service, tt.opts...)
+
+			client := newTestClient(t, server.listener.Addr().String())
+
+			userClient := pb.NewUserClient(client)
+			_, err := userClient.User(context.Background(), &pb.ApplyUser{Uid: 0})
+			require.Error(t, err)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantMsg, status.Convert(err).Message())
+
+			// panic 后服务和连接仍然可用
+			resp, err := userClient.User(context.Background(), &pb.ApplyUser{Uid: 1})
+			require.NoError(t, err)
+			assert.Equal(t, "Tan", resp.User.Name)
+		})
+	}
+}
+
+// deadlineServiceImpl 测试用的服务实现，返回服务端 ctx 的剩余超时时间
+type deadlineServiceImpl struct{}
+
+func (s *deadlineServiceImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return &pb.ReplyHello{Msg: "no deadline"}, nil
+	}
+	return &pb.ReplyHello{Msg: time.Until(deadline).Round(time.Second).String()}, nil
+}
+
+func TestServer_ContextDeadline(t *testing.T) {
+	server := startTestServer(t, &pb.HelloServiceDesc, &deadlineServiceImpl{})
+
+	client := newTestClient(t, server.listener.Addr().String())
+
+	helloClient := pb.NewHelloClient(client)
+
+	// 没有超时时间的调用，服务端 ctx 也没有超时时间
+	resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{})
+	require.NoError(t, err)
+	assert.Equal(t, "no deadline", resp.Msg)
+
+	// 服务端 ctx 的超时时间与客户端一致
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err = helloClient.Hello(ctx, &pb.ApplyHello{})
+	require.NoError(t, err)
+	assert.Equal(t, "5s", resp.Msg)
+}
+
+// blockingServiceImpl 测试用的服务实现，请求在 release 关闭或 ctx 结束前不会返回
+type blockingServiceImpl struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingServiceImpl() *blockingServiceImpl {
+	return &blockingServiceImpl{
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+}
+
+func (s *blockingServiceImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	s.started <- struct{}{}
+	select {
+	case <-s.release:
+		return &pb.ReplyHello{Msg: "Hello, " + apply.Name + "!"}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestServer_Stop(t *testing.T) {
+	server := newTestServer(t)
+	require.NoError(t, pb.RegisterHelloServer(server, &serverImpl{}))
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Start()
+	}()
+
+	client := newTestClient(t, server.listener.Addr().String())
+
+	helloClient := pb.NewHelloClient(client)
+	_, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Test"})
+	require.NoError(t, err)
+
+	server.Stop()
+	assert.ErrorIs(t, <-errChan, ErrServerClosed)
+
+	// 连接已经被断开
+	_, err = helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Test"})
+	assert.Error(t, err)
+
+	// 关闭后不能再启动
+	assert.ErrorIs(t, server.Start(), ErrServerClosed)
+}
+
+func TestServer_GracefulStop(t *testing.T) {
+	service := newBlockingServiceImpl()
+	server := newTestServer(t)
+	require.NoError(t, pb.RegisterHelloServer(server, service))
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Start()
+	}()
+
+	client := newTestClient(t, server.listener.Addr().String())
+
+	helloClient := pb.NewHelloClient(client)
+
+	// 发起一个正在处理中的请求
+	type result struct {
+		resp *pb.ReplyHello
+		err  error
+	}
+	inflight := make(chan result, 1)
+	go func() {
+		resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Inflight"})
+		inflight <- result{resp, err}
+	}()
+	<-service.started
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- server.GracefulStop(context.Background())
+	}()
+
+	// 关闭过程中不再接受新的请求
+	assert.ErrorIs(t, <-errChan, ErrServerClosed)
+	assert.Eventually(t, func() bool {
+		_, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "New"})
+		return status.Code(err) == codes.Unavailable
+	}, time.Second, 10*time.Millisecond)
+
+	// 正在处理的请求完成后，GracefulStop 才返回
+	select {
+	case <-stopped:
+		t.Fatal("请求未完成时 GracefulStop 不应该返回")
+	default:
+	}
+	close(service.release)
+
+	r := <-inflight
+	require.NoError(t, r.err)
+	assert.Equal(t, "Hello, Inflight!", r.resp.Msg)
+	assert.NoError(t, <-stopped)
+}
+
+func TestServer_GracefulStopTimeout(t *testing.T) {
+	service := newBlockingServiceImpl()
+	server := startTestServer(t, &pb.HelloServiceDesc, service)
+
+	client := newTestClient(t, server.listener.Addr().String())
+
+	helloClient := pb.NewHelloClient(client)
+
+	inflight := make(chan error, 1)
+	go func() {
+		_, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Inflight"})
+		inflight <- err
+	}()
+	<-service.started
+
+	// 请求一直不结束，超时后强制关闭，请求的 ctx 被取消，连接被断开
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, server.GracefulStop(ctx), context.DeadlineExceeded)
+	assert.Error(t, <-inflight)
+}
+
+func TestServer_Serve(t *testing.T) {
+	server := NewServerWithoutListener()
+	require.NoError(t, pb.RegisterHelloServer(server, &serverImpl{}))
+	assert.ErrorContains(t, server.Start(), "Serve")
+
+	// 同一个服务可以在多个监听上接受连接
+	lis1 := listenTest(t, "localhost:0")
+	lis2 := listenTest(t, "localhost:0")
+	errChan := make(chan error, 2)
+	go func() { errChan <- server.Serve(lis1) }()
+	go func() { errChan <- server.Serve(lis2) }()
+
+	for _, lis := range []net.Listener{lis1, lis2} {
+		client := newTestClient(t, lis.Addr().String())
+		resp, err := pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "Serve"})
+		require.NoError(t, err)
+		assert.Equal(t, "Hello, Serve!", resp.Msg)
+		client.Close()
+	}
+
+	// 关闭服务时关闭所有监听
+	server.Stop()
+	assert.ErrorIs(t, <-errChan, ErrServerClosed)
+	assert.ErrorIs(t, <-errChan, ErrServerClosed)
+	_, err := dialTest(context.Background(), lis1.Addr().String())
+	assert.Error(t, err)
+
+	// 关闭后 Serve 立即返回并关闭监听
+	lis3 := listenTest(t, "localhost:0")
+	assert.ErrorIs(t, server.Serve(lis3), ErrServerClosed)
+	_, err = lis3.Accept()
+	assert.Error(t, err)
+}
+
+func TestServer_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trpc.sock")
+	server, err := NewServer("unix", path)
+	require.NoError(t, err)
+	require.NoError(t, pb.RegisterHelloServer(server, &serverImpl{}))
+	go server.Start()
+
+	client, err := NewClient("unix", path)
+	require.NoError(t, err)
+	defer client.Close()
+
+	resp, err := pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "Unix"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Unix!", resp.Msg)
+
+	// 关闭后删除 socket 文件
+	server.Stop()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+// largeReplyImpl 测试用的服务实现，请求名为 large 时返回超过服务端大小限制的响应
+type largeReplyImpl struct{}
+
+func (s *largeReplyImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	if apply.Name == "large" {
+		return &pb.ReplyHello{Msg: strings.Repeat("x", 4096)}, nil
+	}
+	return &pb.ReplyHello{Msg: "Hello, " + apply.Name + "!"}, nil
+}
+
+func TestServer_ReplyTooLarge(t *testing.T) {
+	server := startTestServer(t, &pb.HelloServiceDesc, &largeReplyImpl{}, MaxMessageSize(1024))
+
+	client := newTestClient(t, server.listener.Addr().String())
+	helloClient := pb.NewHelloClient(client)
+
+	// 响应超过服务端大小限制时返回 ResourceExhausted
+	_, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "large"})
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// 连接没有被关闭，后续调用仍然使用同一个连接
+	conns := poolConns(testPool(client))
+	require.Len(t, conns, 1)
+	resp, err := helloClient.Hello(context.Background(), &pb.ApplyHello{Name: "Small"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Small!", resp.Msg)
+	assert.Equal(t, conns, poolConns(testPool(client)))
+}
@@ -0,0 +1,242 @@
+package trpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"v2/api"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// service 注册到服务端的一个服务，方法表在注册时通过反射构建，调用时不再查找方法
+type service struct {
+	name    string
+	impl    any
+	rcvr    reflect.Value
+	methods map[string]*methodType
+	streams map[string]*streamType
+}
+
+// methodType 一个符合约定签名的服务方法
+type methodType struct {
+	method  reflect.Method
+	reqType reflect.Type // 请求参数指向的类型，调用时 reflect.New 出来用于解码
+	// embedded 方法由嵌入的接口字段提供时，该字段的索引路径，调用前检查字段是否为 nil
+	embedded []int
+}
+
+// streamType 一个流式方法，通过 desc.Handler 调用
+type streamType struct {
+	desc     api.StreamDesc
+	embedded []int
+}
+
+// newService 根据 desc.HandlerType 声明的服务接口构建方法表，只有接口中的方法可以被远程调用，
+// 指定了 desc.Methods 时只有其中的方法可以被远程调用，impl 上的其他导出方法不会暴露给客户端。
+// 约定方法签名为：func(ctx context.Context, req *ReqType) (*RespType, error)，
+// 接口中存在不符合约定的方法时返回错误，错误中列出所有不符合约定的方法
+func newService(desc *api.ServiceDesc, impl any) (*service, error) {
+	if desc == nil {
+		return nil, errors.New("服务描述为nil")
+	}
+
+	name := desc.ServiceName
+	if name == "" {
+		return nil, errors.New("服务名称为空")
+	}
+
+	ht := reflect.TypeOf(desc.HandlerType)
+	if ht == nil || ht.Kind() != reflect.Pointer || ht.Elem().Kind() != reflect.Interface {
+		return nil, fmt.Errorf("service:%s 的 HandlerType 应为接口指针，例如 (*pb.IHelloService)(nil)", name)
+	}
+	iface := ht.Elem()
+
+	rcvr := reflect.ValueOf(impl)
+	if !rcvr.IsValid() || (rcvr.Kind() == reflect.Pointer && rcvr.IsNil()) {
+		return nil, fmt.Errorf("service:%s 的实现为nil", name)
+	}
+	if !rcvr.Type().Implements(iface) {
+		return nil, fmt.Errorf("service:%s 的实现 %s 没有实现 %s", name, rcvr.Type(), iface)
+	}
+
+	s := &service{
+		name:    name,
+		impl:    impl,
+		rcvr:    rcvr,
+		methods: make(map[string]*methodType),
+	}
+
+	s.streams = make(map[string]*streamType, len(desc.Streams))
+	for _, sd := range desc.Streams {
+		if _, ok := iface.MethodByName(sd.StreamName); !ok {
+			return nil, fmt.Errorf("service:%s stream:%s 不在 %s 中", name, sd.StreamName, iface)
+		}
+		if sd.Handler == nil {
+			return nil, fmt.Errorf("service:%s stream:%s 的 Handler 为nil", name, sd.StreamName)
+		}
+		s.streams[sd.StreamName] = &streamType{
+			desc:     sd,
+			embedded: embeddedInterfacePath(rcvr.Type(), sd.StreamName),
+		}
+	}
+
+	// 没有指定 Methods 时暴露接口中除流式方法以外的所有方法
+	names := make([]string, 0, iface.NumMethod())
+	for _, md := range desc.Methods {
+		if _, ok := iface.MethodByName(md.MethodName); !ok {
+			return nil, fmt.Errorf("service:%s method:%s 不在 %s 中", name, md.MethodName, iface)
+		}
+		names = append(names, md.MethodName)
+	}
+	if len(desc.Methods) == 0 {
+		for i := 0; i < iface.NumMethod(); i++ {
+			if _, ok := s.streams[iface.Method(i).Name]; !ok {
+				names = append(names, iface.Method(i).Name)
+			}
+		}
+	}
+
+	var errs []error
+	for _, methodName := range names {
+		method, _ := rcvr.Type().MethodByName(methodName)
+		if err := checkMethod(method.Type); err != nil {
+			errs = append(errs, fmt.Errorf("service:%s method:%s %w", name, method.Name, err))
+			continue
+		}
+		s.methods[method.Name] = &methodType{
+			method:   method,
+			reqType:  method.Type.In(2).Elem(),
+			embedded: embeddedInterfacePath(rcvr.Type(), method.Name),
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(s.methods) == 0 && len(s.streams) == 0 {
+		return nil, fmt.Errorf("service:%s 没有可调用的方法", name)
+	}
+	return s, nil
+}
+
+// checkMethod 检查方法签名是否符合约定，t 的第一个参数为接收者
+func checkMethod(t reflect.Type) error {
+	if t.IsVariadic() || t.NumIn() != 3 {
+		return errors.New("参数数量不正确，应为 (context.Context, *ReqType)")
+	}
+	if t.In(1) != contextType {
+		return fmt.Errorf("第一个参数应为 context.Context，实际为 %s", t.In(1))
+	}
+	if t.In(2).Kind() != reflect.Pointer {
+		return fmt.Errorf("第二个参数应为指针，实际为 %s", t.In(2))
+	}
+	if t.NumOut() != 2 {
+		return errors.New("返回值数量不正确，应为 (*RespType, error)")
+	}
+	if t.Out(0).Kind() != reflect.Pointer {
+		return fmt.Errorf("第一个返回值应为指针，实际为 %s", t.Out(0))
+	}
+	if t.Out(1) != errorType {
+		return fmt.Errorf("第二个返回值应为 error，实际为 %s", t.Out(1))
+	}
+	return nil
+}
+
+// implemented 报告方法是否有实现，embedded 为方法表中记录的嵌入字段的索引路径。
+// 方法由嵌入的接口字段提供且该字段为 nil 时（类似 gRPC 的 UnimplementedXServer 用法），调用会触发空指针 panic，视为未实现
+func (s *service) implemented(embedded []int) bool {
+	if embedded == nil {
+		return true
+	}
+	field, err := reflect.Indirect(s.rcvr).FieldByIndexErr(embedded)
+	return err == nil && !field.IsNil()
+}
+
+// embeddedInterfacePath 查找 t 的方法 name 是否由嵌入的接口字段提供，返回该字段的索引路径。
+// 方法由 t 自身或嵌入的结构体声明时返回 nil
+func embeddedInterfacePath(t reflect.Type, name string) []int {
+	if declaresMethod(t, name) {
+		return nil
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	// 按嵌入深度逐层查找，与 Go 方法提升的规则一致，浅层的优先
+	type candidate struct {
+		typ   reflect.Type
+		index []int
+	}
+	level := []candidate{{typ: t}}
+	for len(level) > 0 {
+		var next []candidate
+		for _, c := range level {
+			for i := 0; i < c.typ.NumField(); i++ {
+				f := c.typ.Field(i)
+				if !f.Anonymous {
+					continue
+				}
+				index := append(append([]int(nil), c.index...), i)
+
+				ft := f.Type
+				if ft.Kind() == reflect.Interface {
+					if _, ok := ft.MethodByName(name); ok {
+						return index
+					}
+					continue
+				}
+				if declaresMethod(ft, name) {
+					return nil
+				}
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					next = append(next, candidate{typ: ft, index: index})
+				}
+			}
+		}
+		level = next
+	}
+	return nil
+}
+
+// declaresMethod 报告方法 name 是否由 t 自身声明（值接收者或指针接收者），而不是从嵌入字段提升而来。
+// 提升的方法和值接收者方法的指针包装都是编译器生成的，其源文件为 <autogenerated>
+func declaresMethod(t reflect.Type, name string) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for _, typ := range []reflect.Type{t, reflect.PointerTo(t)} {
+		m, ok := typ.MethodByName(name)
+		if !ok {
+			continue
+		}
+		fn := runtime.FuncForPC(m.Func.Pointer())
+		if fn == nil {
+			continue
+		}
+		if file, _ := fn.FileLine(fn.Entry()); file != "<autogenerated>" {
+			return true
+		}
+	}
+	return false
+}
+
+// call 使用 req 调用方法，req 的类型由 reqType 保证
+func (s *service) call(mt *methodType, ctx context.Context, req any) (any, error) {
+	results := mt.method.Func.Call([]reflect.Value{s.rcvr, reflect.ValueOf(ctx), reflect.ValueOf(req)})
+	if err, _ := results[1].Interface().(error); err != nil {
+		return nil, err
+	}
+	return results[0].Interface(), nil
+}
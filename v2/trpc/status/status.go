@@ -0,0 +1,111 @@
+// Package status 实现服务端与客户端之间传递的结构化错误
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"v2/trpc/codes"
+)
+
+// Status 一次调用的结果，由错误码和错误信息组成
+type Status struct {
+	code    codes.Code
+	message string
+}
+
+func New(c codes.Code, msg string) *Status {
+	return &Status{code: c, message: msg}
+}
+
+func Newf(c codes.Code, format string, a ...any) *Status {
+	return New(c, fmt.Sprintf(format, a...))
+}
+
+// Error 返回一个带有错误码的 error，c 为 codes.OK 时返回 nil
+func Error(c codes.Code, msg string) error {
+	return New(c, msg).Err()
+}
+
+func Errorf(c codes.Code, format string, a ...any) error {
+	return Error(c, fmt.Sprintf(format, a...))
+}
+
+func (s *Status) Code() codes.Code {
+	if s == nil {
+		return codes.OK
+	}
+	return s.code
+}
+
+func (s *Status) Message() string {
+	if s == nil {
+		return ""
+	}
+	return s.message
+}
+
+// Err 将 Status 转换为 error，错误码为 codes.OK 时返回 nil
+func (s *Status) Err() error {
+	if s.Code() == codes.OK {
+		return nil
+	}
+	return &statusError{s: s}
+}
+
+// statusError 实现 error 接口，与 Status 分开是为了避免 (*Status)(nil) 被当作非 nil 的 error
+type statusError struct {
+	s *Status
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("rpc error: code = %s desc = %s", e.s.code, e.s.message)
+}
+
+func (e *statusError) Status() *Status {
+	return e.s
+}
+
+// Is 错误码和错误信息都相同时认为是同一个错误，便于使用 errors.Is 比较
+func (e *statusError) Is(target error) bool {
+	t, ok := target.(*statusError)
+	if !ok {
+		return false
+	}
+	return e.s.code == t.s.code && e.s.message == t.s.message
+}
+
+// FromError 从 err 中解析出 Status：
+//   - err 为 nil 时返回 codes.OK 的 Status
+//   - err 链中包含由本包创建的错误时返回对应的 Status
+//   - err 链中包含 context.Canceled 或 context.DeadlineExceeded 时返回 codes.Canceled 或 codes.DeadlineExceeded 的 Status，
+//     服务方法直接返回 ctx.Err() 时调用方得到对应的错误码
+//   - 其他情况返回 codes.Unknown 的 Status，且 ok 为 false
+func FromError(err error) (s *Status, ok bool) {
+	if err == nil {
+		return New(codes.OK, ""), true
+	}
+
+	var se interface{ Status() *Status }
+	if errors.As(err, &se) {
+		return se.Status(), true
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return New(codes.Canceled, err.Error()), true
+	case errors.Is(err, context.DeadlineExceeded):
+		return New(codes.DeadlineExceeded, err.Error()), true
+	}
+	return New(codes.Unknown, err.Error()), false
+}
+
+// Convert 与 FromError 相同，但忽略 ok
+func Convert(err error) *Status {
+	s, _ := FromError(err)
+	return s
+}
+
+// Code 返回 err 对应的错误码
+func Code(err error) codes.Code {
+	return Convert(err).Code()
+}
@@ -0,0 +1,121 @@
+//go:build unit
+
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"v2/trpc/codes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     codes.Code
+		msg      string
+		wantNil  bool
+		wantText string
+	}{
+		{
+			name:     "NotFound错误",
+			code:     codes.NotFound,
+			msg:      "用户不存在",
+			wantText: "rpc error: code = NotFound desc = 用户不存在",
+		},
+		{
+			name:    "OK-返回nil",
+			code:    codes.OK,
+			msg:     "",
+			wantNil: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Error(tt.code, tt.msg)
+			if tt.wantNil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantText)
+			assert.Equal(t, tt.code, Code(err))
+		})
+	}
+}
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+		wantMsg  string
+		wantOk   bool
+	}{
+		{
+			name:     "nil错误",
+			err:      nil,
+			wantCode: codes.OK,
+			wantOk:   true,
+		},
+		{
+			name:     "Status错误",
+			err:      Errorf(codes.InvalidArgument, "uid:%d 不合法", 0),
+			wantCode: codes.InvalidArgument,
+			wantMsg:  "uid:0 不合法",
+			wantOk:   true,
+		},
+		{
+			name:     "被包装的Status错误",
+			err:      fmt.Errorf("调用失败: %w", Error(codes.Internal, "内部错误")),
+			wantCode: codes.Internal,
+			wantMsg:  "内部错误",
+			wantOk:   true,
+		},
+		{
+			name:     "ctx取消",
+			err:      context.Canceled,
+			wantCode: codes.Canceled,
+			wantMsg:  "context canceled",
+			wantOk:   true,
+		},
+		{
+			name:     "被包装的ctx超时",
+			err:      fmt.Errorf("查询失败: %w", context.DeadlineExceeded),
+			wantCode: codes.DeadlineExceeded,
+			wantMsg:  "查询失败: context deadline exceeded",
+			wantOk:   true,
+		},
+		{
+			name:     "普通错误",
+			err:      errors.New("boom"),
+			wantCode: codes.Unknown,
+			wantMsg:  "boom",
+			wantOk:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ok := FromError(tt.err)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantCode, s.Code())
+			assert.Equal(t, tt.wantMsg, s.Message())
+		})
+	}
+}
+
+func TestStatusError_Is(t *testing.T) {
+	err := Error(codes.NotFound, "用户不存在")
+	assert.ErrorIs(t, err, Error(codes.NotFound, "用户不存在"))
+	assert.NotErrorIs(t, err, Error(codes.NotFound, "其他"))
+	assert.NotErrorIs(t, err, Error(codes.Internal, "用户不存在"))
+}
+
+func TestCode_String(t *testing.T) {
+	assert.Equal(t, "Unimplemented", codes.Unimplemented.String())
+	assert.Equal(t, "Code(100)", codes.Code(100).String())
+}
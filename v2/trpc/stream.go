@@ -0,0 +1,509 @@
+package trpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"v2/api"
+	"v2/trpc/codec"
+	"v2/trpc/codes"
+	"v2/trpc/metadata"
+	"v2/trpc/status"
+)
+
+var errSendClosed = errors.New("CloseSend 之后不能发送消息")
+
+// streamWindowSize 每个流每个方向的流量控制窗口（字节）。发送方最多发送一个窗口的消息而不等待接收方读取，
+// 窗口耗尽时 SendMsg 阻塞，接收方的 RecvMsg 累计读取半个窗口后通过 StreamWindowUpdate 帧归还额度。
+// 连接上的所有流共用一个读取连接的 goroutine，读取缓慢的流只会让自己的发送方等待，不会阻塞其他流和调用
+const streamWindowSize = 1 << 20
+
+// msgCost 一条消息占用的流量控制额度，加上帧头的大小，避免空消息不受限制
+func msgCost(data []byte) int {
+	return frameHeaderSize + len(data)
+}
+
+// recvBufferLimit 流中未读取的消息占用的额度上限：发送方在额度大于 0 时可以发送一条任意大小的消息，
+// 因此最多超出窗口一条最大的消息
+func recvBufferLimit(maxMessageSize int) int {
+	return streamWindowSize + frameHeaderSize + maxMessageSize
+}
+
+// recvBuffer 流中已收到但还没有被 RecvMsg 读取的消息，由读取连接的 goroutine 写入，
+// 只有一个读取方。结束后先返回已收到的消息，再返回结束的原因
+type recvBuffer struct {
+	limit    int // 未读取的消息占用的额度上限，发送方遵守流量控制时不会超过
+	mu       sync.Mutex
+	msgs     [][]byte
+	size     int // 未读取的消息占用的额度
+	unacked  int // 已读取但还没有归还给发送方的额度
+	err      error
+	overflow error // 发送方没有遵守流量控制导致超过上限时的错误
+	notify   chan struct{}
+}
+
+func newRecvBuffer(limit int) *recvBuffer {
+	return &recvBuffer{limit: limit, notify: make(chan struct{}, 1)}
+}
+
+// put 添加一条消息，接收已经结束时丢弃。发送方没有遵守流量控制导致超过上限时丢弃所有消息并结束接收，
+// 之后 get 返回 codes.ResourceExhausted，此时返回 false，调用方需要重置流
+func (b *recvBuffer) put(msg []byte) bool {
+	b.mu.Lock()
+	if b.err != nil {
+		b.mu.Unlock()
+		return true
+	}
+	if b.size+msgCost(msg) > b.limit {
+		b.overflow = status.Errorf(codes.ResourceExhausted, "发送方超过流量控制窗口，未读取的消息超过 %d 字节", b.limit)
+		b.err = b.overflow
+		b.msgs = nil
+		b.mu.Unlock()
+		b.wake()
+		return false
+	}
+	b.msgs = append(b.msgs, msg)
+	b.size += msgCost(msg)
+	b.mu.Unlock()
+	b.wake()
+	return true
+}
+
+// overflowErr 发送方超过流量控制窗口时返回 codes.ResourceExhausted 错误，否则返回 nil
+func (b *recvBuffer) overflowErr() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.overflow
+}
+
+// ack 返回需要归还给发送方的额度，累计读取不足半个窗口时返回 0，避免频繁发送 StreamWindowUpdate 帧
+func (b *recvBuffer) ack() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.unacked < streamWindowSize/2 {
+		return 0
+	}
+	n := b.unacked
+	b.unacked = 0
+	return n
+}
+
+// close 结束接收，已收到的消息仍然可以读取，多次调用时第一次的 err 生效
+func (b *recvBuffer) close(err error) {
+	b.mu.Lock()
+	if b.err == nil {
+		b.err = err
+	}
+	b.mu.Unlock()
+	b.wake()
+}
+
+// abort 结束接收并丢弃已收到的消息，用于流被取消或连接断开
+func (b *recvBuffer) abort(err error) {
+	b.mu.Lock()
+	if b.err == nil {
+		b.err = err
+		b.msgs = nil
+	}
+	b.mu.Unlock()
+	b.wake()
+}
+
+func (b *recvBuffer) wake() {
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+// get 返回下一条消息，没有消息时阻塞，直到收到消息、接收结束或 ctx 结束
+func (b *recvBuffer) get(ctx context.Context) ([]byte, error) {
+	for {
+		b.mu.Lock()
+		if len(b.msgs) > 0 {
+			msg := b.msgs[0]
+			b.msgs[0] = nil
+			b.msgs = b.msgs[1:]
+			b.size -= msgCost(msg)
+			b.unacked += msgCost(msg)
+			b.mu.Unlock()
+			return msg, nil
+		}
+		err := b.err
+		b.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-b.notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// sendQuota 流的发送额度，初始为一个窗口，发送消息时扣减，收到接收方的 StreamWindowUpdate 帧时增加
+type sendQuota struct {
+	mu     sync.Mutex
+	quota  int
+	notify chan struct{}
+}
+
+func newSendQuota() *sendQuota {
+	return &sendQuota{quota: streamWindowSize, notify: make(chan struct{}, 1)}
+}
+
+// acquire 等待额度大于 0 后扣减 n，额度可以扣成负数，保证任意大小的消息都能发送。ctx 结束时返回 ctx.Err()
+func (q *sendQuota) acquire(ctx context.Context, n int) error {
+	for {
+		q.mu.Lock()
+		if q.quota > 0 {
+			q.quota -= n
+			left := q.quota
+			q.mu.Unlock()
+			// 还有剩余额度时唤醒其他等待的发送方
+			if left > 0 {
+				q.wake()
+			}
+			return nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// add 接收方归还了 n 字节的额度
+func (q *sendQuota) add(n int) {
+	q.mu.Lock()
+	q.quota += n
+	q.mu.Unlock()
+	q.wake()
+}
+
+func (q *sendQuota) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// windowUpdatePayload StreamWindowUpdate 帧的 payload：流 ID + 归还的额度
+func windowUpdatePayload(id uint64, n int) []byte {
+	return binary.AppendUvarint(binary.AppendUvarint(nil, id), uint64(n))
+}
+
+// parseWindowUpdate 解析 StreamWindowUpdate 帧的 payload
+func parseWindowUpdate(payload []byte) (uint64, int, error) {
+	id, rest, err := readUvarint(payload)
+	if err != nil {
+		return 0, 0, err
+	}
+	n, _, err := readUvarint(rest)
+	if err != nil {
+		return 0, 0, err
+	}
+	if n > math.MaxInt32 {
+		return 0, 0, fmt.Errorf("流量控制额度过大: %d", n)
+	}
+	return id, int(n), nil
+}
+
+// streamPayload 流中消息帧的 payload：流 ID + codec 编码的消息
+func streamPayload(id uint64, data []byte) []byte {
+	buf := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(data)), id)
+	return append(buf, data...)
+}
+
+// clientStream 客户端的一个流，实现 api.ClientStream。
+// 流由服务端的结束帧结束，ctx 结束时客户端发送取消帧通知服务端
+type clientStream struct {
+	t      *clientTransport
+	id     uint64
+	desc   *api.StreamDesc
+	codec  codec.Codec
+	ctx    context.Context
+	cancel context.CancelFunc
+	recv   *recvBuffer
+	quota  *sendQuota
+
+	headerCh chan struct{} // 收到响应头或流结束时关闭
+
+	mu         sync.Mutex
+	header     metadata.MD
+	headerErr  error
+	headerDone bool
+	trailer    metadata.MD
+	done       bool // 流已结束
+	sendClosed bool
+}
+
+// setHeader 收到服务端的响应头
+func (cs *clientStream) setHeader(md metadata.MD) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.headerDone {
+		return
+	}
+	cs.headerDone = true
+	cs.header = md
+	close(cs.headerCh)
+}
+
+// finish 收到服务端的结束帧，RecvMsg 读完已收到的消息后返回 io.EOF 或调用失败的 Status
+func (cs *clientStream) finish(r *Reply) {
+	var err error = io.EOF
+	if r.Code != codes.OK {
+		err = status.Error(r.Code, r.Message)
+	}
+	cs.end(err, r.Header, r.Trailer, false)
+}
+
+// abort 流被取消或连接断开，丢弃已收到的消息
+func (cs *clientStream) abort(err error) {
+	cs.end(err, nil, nil, true)
+}
+
+func (cs *clientStream) end(err error, header, trailer metadata.MD, discard bool) {
+	cs.mu.Lock()
+	if cs.done {
+		cs.mu.Unlock()
+		return
+	}
+	cs.done = true
+	cs.trailer = trailer
+	if !cs.headerDone {
+		cs.headerDone = true
+		cs.header = header
+		if discard {
+			cs.headerErr = err
+		}
+		close(cs.headerCh)
+	}
+	cs.mu.Unlock()
+
+	if discard {
+		cs.recv.abort(err)
+	} else {
+		cs.recv.close(err)
+	}
+	cs.cancel()
+}
+
+// watch 等待 ctx 结束，流还没有结束时通知服务端取消。
+// 剩余超时时间随打开帧发送给了服务端，超时时服务端的 ctx 也会超时，不需要通知
+func (cs *clientStream) watch() {
+	<-cs.ctx.Done()
+	if cs.t.removeStream(cs.id) == nil {
+		return
+	}
+	err := cs.ctx.Err()
+	cs.abort(err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	cs.t.send(context.Background(), frameStreamCancel, cs.codec.Name(), binary.AppendUvarint(nil, cs.id))
+}
+
+func (cs *clientStream) Header() (metadata.MD, error) {
+	<-cs.headerCh
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.header, cs.headerErr
+}
+
+func (cs *clientStream) Trailer() metadata.MD {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.trailer
+}
+
+func (cs *clientStream) CloseSend() error {
+	cs.mu.Lock()
+	closed, done := cs.sendClosed, cs.done
+	cs.sendClosed = true
+	cs.mu.Unlock()
+
+	if closed || done || cs.ctx.Err() != nil {
+		return nil
+	}
+	err := cs.t.send(cs.ctx, frameStreamCloseSend, cs.codec.Name(), binary.AppendUvarint(nil, cs.id))
+	if err != nil {
+		// 发送期间流已经结束，调用方通过 RecvMsg 获取结束的原因
+		cs.mu.Lock()
+		done = cs.done
+		cs.mu.Unlock()
+		if done {
+			return nil
+		}
+	}
+	return err
+}
+
+func (cs *clientStream) Context() context.Context {
+	return cs.ctx
+}
+
+func (cs *clientStream) SendMsg(m any) error {
+	cs.mu.Lock()
+	closed, done := cs.sendClosed, cs.done
+	cs.mu.Unlock()
+
+	if closed {
+		return errSendClosed
+	}
+	// 流已经结束或 ctx 已结束，调用方通过 RecvMsg 获取结束的原因
+	if done || cs.ctx.Err() != nil {
+		return io.EOF
+	}
+
+	data, err := cs.codec.Marshal(m)
+	if err != nil {
+		return status.Errorf(codes.Internal, "请求序列化失败: %v", err)
+	}
+	payload := streamPayload(cs.id, data)
+	if err := cs.t.checkFrame(cs.codec.Name(), payload); err != nil {
+		return err
+	}
+	// 等待服务端归还额度，流结束时 cs.ctx 被取消
+	err = cs.quota.acquire(cs.ctx, msgCost(data))
+	if err == nil {
+		err = cs.t.send(cs.ctx, frameStreamMessage, cs.codec.Name(), payload)
+	}
+	if err != nil {
+		// 发送期间流已经结束，调用方通过 RecvMsg 获取结束的原因
+		cs.mu.Lock()
+		done = cs.done
+		cs.mu.Unlock()
+		if done {
+			return io.EOF
+		}
+		return err
+	}
+	return nil
+}
+
+// RecvMsg 读取下一条消息。服务端不是流式发送时只有一条响应，读取响应后等待流结束，
+// 流正常结束才返回 nil，没有响应或有多条响应时返回 codes.Internal
+func (cs *clientStream) RecvMsg(m any) error {
+	data, err := cs.recv.get(cs.ctx)
+	if err != nil {
+		if errors.Is(err, io.EOF) && !cs.desc.ServerStreams {
+			return status.Error(codes.Internal, "服务端没有返回响应")
+		}
+		return err
+	}
+	if n := cs.recv.ack(); n > 0 {
+		cs.t.send(cs.ctx, frameStreamWindowUpdate, cs.codec.Name(), windowUpdatePayload(cs.id, n))
+	}
+	if err := cs.codec.Unmarshal(data, m); err != nil {
+		return status.Errorf(codes.Internal, "响应解析失败: %v", err)
+	}
+	if cs.desc.ServerStreams {
+		return nil
+	}
+
+	_, err = cs.recv.get(cs.ctx)
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil
+	case err != nil:
+		return err
+	default:
+		cs.cancel()
+		return status.Error(codes.Internal, "服务端返回了多条响应")
+	}
+}
+
+// serverStream 服务端的一个流，实现 api.ServerStream。
+// ctx 在客户端取消流、超时或连接断开时结束
+type serverStream struct {
+	conn      *serverConn
+	id        uint64
+	codecName string
+	codec     codec.Codec // 调用服务方法前设置
+	ctx       context.Context
+	cancel    context.CancelFunc
+	call      *serverCall
+	recv      *recvBuffer
+	quota     *sendQuota
+}
+
+func (ss *serverStream) SetHeader(md metadata.MD) error {
+	return ss.call.setHeader(md)
+}
+
+func (ss *serverStream) SendHeader(md metadata.MD) error {
+	if err := ss.call.setHeader(md); err != nil {
+		return err
+	}
+	return ss.flushHeader()
+}
+
+func (ss *serverStream) SetTrailer(md metadata.MD) {
+	ss.call.setTrailer(md)
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+func (ss *serverStream) SendMsg(m any) error {
+	if err := ss.ctx.Err(); err != nil {
+		return err
+	}
+	if err := ss.flushHeader(); err != nil {
+		return err
+	}
+
+	data, err := ss.codec.Marshal(m)
+	if err != nil {
+		return status.Errorf(codes.Internal, "响应序列化失败: %v", err)
+	}
+	payload := streamPayload(ss.id, data)
+	if err := checkFrame(ss.codecName, payload, ss.conn.maxMessageSize); err != nil {
+		if errors.Is(err, ErrMessageTooLarge) {
+			return status.Errorf(codes.ResourceExhausted, "响应超过大小限制: %v", err)
+		}
+		return err
+	}
+	// 等待客户端归还额度，客户端取消或流结束时 ss.ctx 被取消
+	if err := ss.quota.acquire(ss.ctx, msgCost(data)); err != nil {
+		return err
+	}
+	return ss.conn.sendFrame(frameStreamMessage, ss.codecName, payload)
+}
+
+func (ss *serverStream) RecvMsg(m any) error {
+	data, err := ss.recv.get(ss.ctx)
+	if err != nil {
+		return err
+	}
+	if n := ss.recv.ack(); n > 0 {
+		ss.conn.sendFrame(frameStreamWindowUpdate, ss.codecName, windowUpdatePayload(ss.id, n))
+	}
+	if err := ss.codec.Unmarshal(data, m); err != nil {
+		return status.Errorf(codes.InvalidArgument, "参数解析失败: %v", err)
+	}
+	return nil
+}
+
+// flushHeader 第一次调用时发送响应头，之后不再发送
+func (ss *serverStream) flushHeader() error {
+	md, ok := ss.call.sendHeader()
+	if !ok {
+		return nil
+	}
+	r := &Reply{Seq: ss.id, Header: md}
+	return ss.conn.sendFrame(frameStreamHeader, ss.codecName, r.Marshal())
+}
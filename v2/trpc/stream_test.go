@@ -0,0 +1,805 @@
+//go:build unit
+
+package trpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"v2/api"
+	"v2/pb"
+	"v2/trpc/codes"
+	"v2/trpc/metadata"
+	"v2/trpc/status"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listServiceImpl 测试用的服务实现，ListUsers 的行为由 ApplyList.Sex 决定
+type listServiceImpl struct {
+	pb.UnimplementedUserService
+	canceled chan error   // ListUsers 阻塞或 GetUsers 接收失败时，ctx 结束的原因
+	flooded  atomic.Int64 // listFlood 已经发送的用户数
+}
+
+const (
+	listAll      = 0 // 发送所有用户，设置响应头和响应尾
+	listError    = 1 // 发送两个用户后返回错误
+	listPanic    = 2 // 发送一个用户后 panic
+	listBlocking = 3 // 发送一个用户后阻塞，直到 ctx 结束
+	listFlood    = 4 // 发送 floodUsers 个用户，总大小超过流量控制窗口
+)
+
+// floodUsers 流量控制测试中发送的消息数，每条消息约 1KB
+const floodUsers = 5000
+
+var floodName = strings.Repeat("x", 1024)
+
+func (s *listServiceImpl) User(ctx context.Context, apply *pb.ApplyUser) (*pb.ReplyUser, error) {
+	return &pb.ReplyUser{User: &pb.User{Uid: apply.Uid}}, nil
+}
+
+func (s *listServiceImpl) ListUsers(ctx context.Context, apply *pb.ApplyList, stream pb.User_ListUsersServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	switch apply.Sex {
+	case listAll:
+		if err := stream.SendHeader(metadata.Pairs("trace-id", md.Get("trace-id"))); err != nil {
+			return err
+		}
+		for uid := int64(1); uid <= 5; uid++ {
+			if err := stream.Send(&pb.User{Uid: uid}); err != nil {
+				return err
+			}
+		}
+		// 响应头发送后不能再设置
+		if err := stream.SetHeader(metadata.Pairs("late", "1")); !errors.Is(err, errHeaderSent) {
+			return status.Errorf(codes.Internal, "SetHeader 应返回 errHeaderSent，实际为 %v", err)
+		}
+		stream.SetTrailer(metadata.Pairs("count", "5"))
+		return nil
+	case listError:
+		stream.SetHeader(metadata.Pairs("trace-id", md.Get("trace-id")))
+		stream.SetTrailer(metadata.Pairs("count", "2"))
+		for uid := int64(1); uid <= 2; uid++ {
+			if err := stream.Send(&pb.User{Uid: uid}); err != nil {
+				return err
+			}
+		}
+		return status.Error(codes.NotFound, "没有更多用户")
+	case listPanic:
+		stream.Send(&pb.User{Uid: 1})
+		panic("list panic")
+	case listBlocking:
+		if err := stream.Send(&pb.User{Uid: 1}); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		s.canceled <- ctx.Err()
+		return ctx.Err()
+	case listFlood:
+		for uid := int64(1); uid <= floodUsers; uid++ {
+			if err := stream.Send(&pb.User{Uid: uid, Name: floodName}); err != nil {
+				return err
+			}
+			s.flooded.Add(1)
+		}
+		return nil
+	}
+	return status.Error(codes.InvalidArgument, "未知的 sex")
+}
+
+// AddUsers 返回收到的用户数量。收到 uid 为 -1 的用户时立即返回错误，为 -2 时不返回响应
+func (s *listServiceImpl) AddUsers(stream pb.User_AddUsersServer) error {
+	var count int64
+	for {
+		user, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&pb.ReplyAddUsers{Count: count})
+		}
+		if err != nil {
+			return err
+		}
+
+		switch user.Uid {
+		case -1:
+			return status.Error(codes.InvalidArgument, "非法的 uid")
+		case -2:
+			return nil
+		}
+		count++
+	}
+}
+
+// GetUsers 每收到一个 uid 返回一个用户，uid 为 0 时返回错误
+func (s *listServiceImpl) GetUsers(stream pb.User_GetUsersServer) error {
+	for {
+		apply, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if s.canceled != nil {
+				s.canceled <- err
+			}
+			return err
+		}
+
+		if apply.Uid == 0 {
+			return status.Error(codes.InvalidArgument, "uid 为空")
+		}
+		if err := stream.Send(&pb.ReplyUser{User: &pb.User{Uid: apply.Uid}}); err != nil {
+			return err
+		}
+	}
+}
+
+// recvAll 读取流中的所有用户，返回读取到的 uid 和结束时的错误
+func recvAll(stream pb.User_ListUsersClient) ([]int64, error) {
+	var uids []int64
+	for {
+		user, err := stream.Recv()
+		if err != nil {
+			return uids, err
+		}
+		uids = append(uids, user.Uid)
+	}
+}
+
+func TestStream_ServerStreaming(t *testing.T) {
+	server := startTestServer(t, &pb.UserServiceDesc, &listServiceImpl{})
+	client := newTestClient(t, server.listener.Addr().String())
+	userClient := pb.NewUserClient(client)
+
+	tests := []struct {
+		name        string
+		sex         int64
+		wantUids    []int64
+		wantCode    codes.Code
+		wantHeader  metadata.MD
+		wantTrailer metadata.MD
+	}{
+		{
+			name:        "收到所有消息后返回EOF",
+			sex:         listAll,
+			wantUids:    []int64{1, 2, 3, 4, 5},
+			wantCode:    codes.OK,
+			wantHeader:  metadata.Pairs("trace-id", "stream-test"),
+			wantTrailer: metadata.Pairs("count", "5"),
+		},
+		{
+			name:        "发送部分消息后返回错误",
+			sex:         listError,
+			wantUids:    []int64{1, 2},
+			wantCode:    codes.NotFound,
+			wantHeader:  metadata.Pairs("trace-id", "stream-test"),
+			wantTrailer: metadata.Pairs("count", "2"),
+		},
+		{
+			name:     "服务方法panic-返回Internal",
+			sex:      listPanic,
+			wantUids: []int64{1},
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			ctx = metadata.AppendToOutgoingContext(ctx, "trace-id", "stream-test")
+
+			stream, err := userClient.ListUsers(ctx, &pb.ApplyList{Sex: tt.sex})
+			require.NoError(t, err)
+
+			uids, endErr := recvAll(stream)
+			assert.Equal(t, tt.wantUids, uids)
+			if tt.wantCode == codes.OK {
+				assert.ErrorIs(t, endErr, io.EOF)
+			} else {
+				assert.Equal(t, tt.wantCode, status.Code(endErr))
+			}
+
+			header, err := stream.Header()
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantHeader, header)
+			assert.Equal(t, tt.wantTrailer, stream.Trailer())
+
+			// 流结束后继续读取返回同样的结果，ctx 已结束
+			_, err = stream.Recv()
+			assert.Equal(t, endErr, err)
+			assert.Error(t, stream.Context().Err())
+		})
+	}
+}
+
+func TestStream_Cancel(t *testing.T) {
+	impl := &listServiceImpl{canceled: make(chan error, 1)}
+	server := startTestServer(t, &pb.UserServiceDesc, impl)
+	client := newTestClient(t, server.listener.Addr().String())
+	userClient := pb.NewUserClient(client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := userClient.ListUsers(ctx, &pb.ApplyList{Sex: listBlocking})
+	require.NoError(t, err)
+
+	user, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), user.Uid)
+
+	// 客户端取消后，服务方法的 ctx 也被取消
+	cancel()
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, context.Canceled)
+	select {
+	case err := <-impl.canceled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("服务方法的 ctx 没有被取消")
+	}
+
+	// 连接仍然可用
+	reply, err := userClient.User(context.Background(), &pb.ApplyUser{Uid: 7})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), reply.User.Uid)
+}
+
+func TestStream_Timeout(t *testing.T) {
+	impl := &listServiceImpl{canceled: make(chan error, 1)}
+	server := startTestServer(t, &pb.UserServiceDesc, impl)
+	client := newTestClient(t, server.listener.Addr().String())
+	userClient := pb.NewUserClient(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	stream, err := userClient.ListUsers(ctx, &pb.ApplyList{Sex: listBlocking})
+	require.NoError(t, err)
+
+	_, err = recvAll(stream)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	select {
+	case err := <-impl.canceled:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("服务方法的 ctx 没有超时")
+	}
+}
+
+func TestRecvBuffer(t *testing.T) {
+	tests := []struct {
+		name     string
+		puts     int
+		wantOK   bool
+		wantMsgs int
+		wantErr  error // 读完消息后的错误
+	}{
+		{name: "未超过上限", puts: 2, wantOK: true, wantMsgs: 2, wantErr: io.EOF},
+		{
+			name:    "发送方超过流量控制窗口时丢弃所有消息",
+			puts:    3,
+			wantOK:  false,
+			wantErr: status.Errorf(codes.ResourceExhausted, "发送方超过流量控制窗口，未读取的消息超过 %d 字节", 2*msgCost([]byte{0})),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newRecvBuffer(2 * msgCost([]byte{0}))
+			ok := true
+			for i := 0; i < tt.puts; i++ {
+				ok = b.put([]byte{byte(i)})
+			}
+			assert.Equal(t, tt.wantOK, ok)
+			b.close(io.EOF)
+
+			msgs := 0
+			for {
+				_, err := b.get(context.Background())
+				if err != nil {
+					assert.Equal(t, tt.wantErr, err)
+					break
+				}
+				msgs++
+			}
+			assert.Equal(t, tt.wantMsgs, msgs)
+			if !tt.wantOK {
+				assert.Equal(t, tt.wantErr, b.overflowErr())
+			}
+		})
+	}
+}
+
+func TestRecvBuffer_Ack(t *testing.T) {
+	b := newRecvBuffer(recvBufferLimit(DefaultMaxMessageSize))
+	msg := make([]byte, streamWindowSize/4)
+	for i := 0; i < 3; i++ {
+		require.True(t, b.put(msg))
+	}
+
+	// 读取不足半个窗口时不归还额度
+	_, err := b.get(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, b.ack())
+
+	// 累计读取达到半个窗口后一次归还
+	_, err = b.get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2*msgCost(msg), b.ack())
+	assert.Zero(t, b.ack())
+}
+
+func TestSendQuota(t *testing.T) {
+	q := newSendQuota()
+
+	// 额度大于 0 时可以发送超过剩余额度的消息
+	require.NoError(t, q.acquire(context.Background(), streamWindowSize-1))
+	require.NoError(t, q.acquire(context.Background(), streamWindowSize))
+
+	// 额度耗尽时阻塞，直到 ctx 结束
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.acquire(ctx, 1), context.DeadlineExceeded)
+
+	// 接收方归还额度后继续发送
+	done := make(chan error, 1)
+	go func() { done <- q.acquire(context.Background(), 1) }()
+	q.add(streamWindowSize)
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("归还额度后没有唤醒发送方")
+	}
+}
+
+func TestStream_FlowControlSlowReader(t *testing.T) {
+	impl := &listServiceImpl{}
+	server := startTestServer(t, &pb.UserServiceDesc, impl)
+	client := newTestClient(t, server.listener.Addr().String())
+	userClient := pb.NewUserClient(client)
+
+	stream, err := userClient.ListUsers(context.Background(), &pb.ApplyList{Sex: listFlood})
+	require.NoError(t, err)
+
+	// 客户端不读取时服务端在窗口耗尽后阻塞，而不是重置流
+	time.Sleep(100 * time.Millisecond)
+	flooded := impl.flooded.Load()
+	assert.Less(t, flooded, int64(floodUsers))
+	assert.LessOrEqual(t, int(flooded-1)*msgCost([]byte(floodName)), streamWindowSize)
+
+	// 读取较慢的客户端仍然能收到所有消息
+	var uids []int64
+	for {
+		user, err := stream.Recv()
+		if err != nil {
+			assert.ErrorIs(t, err, io.EOF)
+			break
+		}
+		uids = append(uids, user.Uid)
+		if len(uids)%10 == 0 {
+			time.Sleep(10 * time.Microsecond)
+		}
+	}
+	require.Len(t, uids, floodUsers)
+	assert.Equal(t, int64(floodUsers), uids[floodUsers-1])
+}
+
+// slowAddImpl 测试用的服务实现，AddUsers 在 release 关闭前不读取消息
+type slowAddImpl struct {
+	pb.UnimplementedUserService
+	release chan struct{}
+}
+
+func (s *slowAddImpl) AddUsers(stream pb.User_AddUsersServer) error {
+	<-s.release
+	var count int64
+	for {
+		_, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&pb.ReplyAddUsers{Count: count})
+		}
+		if err != nil {
+			return err
+		}
+		count++
+	}
+}
+
+func TestStream_FlowControlSlowServer(t *testing.T) {
+	impl := &slowAddImpl{release: make(chan struct{})}
+	server := startTestServer(t, &pb.UserServiceDesc, impl)
+	client := newTestClient(t, server.listener.Addr().String())
+	userClient := pb.NewUserClient(client)
+
+	stream, err := userClient.AddUsers(context.Background())
+	require.NoError(t, err)
+
+	var sent atomic.Int64
+	done := make(chan error, 1)
+	var reply *pb.ReplyAddUsers
+	go func() {
+		for uid := int64(1); uid <= floodUsers; uid++ {
+			if err := stream.Send(&pb.User{Uid: uid, Name: floodName}); err != nil {
+				done <- err
+				return
+			}
+			sent.Add(1)
+		}
+		var err error
+		reply, err = stream.CloseAndRecv()
+		done <- err
+	}()
+
+	// 服务方法不读取时客户端在窗口耗尽后阻塞
+	time.Sleep(100 * time.Millisecond)
+	assert.Less(t, sent.Load(), int64(floodUsers))
+
+	// 服务方法开始读取后客户端继续发送，服务端收到所有消息
+	close(impl.release)
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("流没有结束")
+	}
+	assert.Equal(t, int64(floodUsers), reply.Count)
+}
+
+func TestStream_ConcurrentWithUnary(t *testing.T) {
+	impl := &listServiceImpl{canceled: make(chan error, 1)}
+	server := startTestServer(t, &pb.UserServiceDesc, impl)
+	client := newTestClient(t, server.listener.Addr().String())
+	userClient := pb.NewUserClient(client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	blocking, err := userClient.ListUsers(ctx, &pb.ApplyList{Sex: listBlocking})
+	require.NoError(t, err)
+	_, err = blocking.Recv()
+	require.NoError(t, err)
+
+	// 流没有结束时，同一连接上的一元调用和其他流不受影响
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stream, err := userClient.ListUsers(context.Background(), &pb.ApplyList{Sex: listAll})
+		if assert.NoError(t, err) {
+			uids, err := recvAll(stream)
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Len(t, uids, 5)
+		}
+	}()
+	for uid := int64(1); uid <= 10; uid++ {
+		reply, err := userClient.User(context.Background(), &pb.ApplyUser{Uid: uid})
+		require.NoError(t, err)
+		assert.Equal(t, uid, reply.User.Uid)
+	}
+	<-done
+}
+
+func TestStream_ClientStreaming(t *testing.T) {
+	server := startTestServer(t, &pb.UserServiceDesc, &listServiceImpl{})
+	client := newTestClient(t, server.listener.Addr().String())
+	userClient := pb.NewUserClient(client)
+
+	tests := []struct {
+		name      string
+		uids      []int64
+		wantCount int64
+		wantCode  codes.Code
+	}{
+		{
+			name:      "发送多条消息后收到一条响应",
+			uids:      []int64{1, 2, 3, 4, 5},
+			wantCount: 5,
+		},
+		{
+			name: "不发送消息",
+		},
+		{
+			name:     "服务端提前返回错误",
+			uids:     []int64{1, -1},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "服务端没有返回响应",
+			uids:     []int64{-2},
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			stream, err := userClient.AddUsers(ctx)
+			require.NoError(t, err)
+			for _, uid := range tt.uids {
+				// 服务端提前结束时 Send 返回 io.EOF，结果通过 CloseAndRecv 获取
+				if err := stream.Send(&pb.User{Uid: uid}); err != nil {
+					require.ErrorIs(t, err, io.EOF)
+				}
+			}
+
+			reply, err := stream.CloseAndRecv()
+			if tt.wantCode != codes.OK {
+				assert.Nil(t, reply)
+				assert.Equal(t, tt.wantCode, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCount, reply.Count)
+		})
+	}
+}
+
+func TestStream_ServerEndsDuringSend(t *testing.T) {
+	server := startTestServer(t, &pb.UserServiceDesc, &listServiceImpl{})
+	client := newTestClient(t, server.listener.Addr().String())
+	userClient := pb.NewUserClient(client)
+
+	// 同一连接上的一元调用不受流提前结束的影响
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	unaryErrs := make(chan error, 1)
+	go func() {
+		defer close(unaryErrs)
+		for ctx.Err() == nil {
+			if _, err := userClient.User(context.Background(), &pb.ApplyUser{Uid: 1}); err != nil {
+				unaryErrs <- err
+				return
+			}
+		}
+	}()
+
+	name := strings.Repeat("x", 2<<20)
+	for i := 0; i < 5; i++ {
+		stream, err := userClient.AddUsers(context.Background())
+		require.NoError(t, err)
+		// 服务端收到第一条消息后立即返回错误，此时客户端正在发送大消息
+		for j, uid := range []int64{-1, 1, 2, 3, 4, 5, 6, 7} {
+			if err := stream.Send(&pb.User{Uid: uid, Name: name}); err != nil {
+				require.ErrorIs(t, err, io.EOF, "第 %d 条消息", j)
+				break
+			}
+		}
+		_, err = stream.CloseAndRecv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	cancel()
+	assert.NoError(t, <-unaryErrs)
+}
+
+func TestStream_Bidi(t *testing.T) {
+	server := startTestServer(t, &pb.UserServiceDesc, &listServiceImpl{})
+	client := newTestClient(t, server.listener.Addr().String())
+	userClient := pb.NewUserClient(client)
+
+	t.Run("一问一答", func(t *testing.T) {
+		stream, err := userClient.GetUsers(context.Background())
+		require.NoError(t, err)
+		for uid := int64(1); uid <= 3; uid++ {
+			require.NoError(t, stream.Send(&pb.ApplyUser{Uid: uid}))
+			reply, err := stream.Recv()
+			require.NoError(t, err)
+			assert.Equal(t, uid, reply.User.Uid)
+		}
+		require.NoError(t, stream.CloseSend())
+		_, err = stream.Recv()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("服务端返回错误", func(t *testing.T) {
+		stream, err := userClient.GetUsers(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.ApplyUser{Uid: 1}))
+		require.NoError(t, stream.Send(&pb.ApplyUser{Uid: 0}))
+
+		reply, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), reply.User.Uid)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		// 流结束后发送返回 io.EOF
+		assert.ErrorIs(t, stream.Send(&pb.ApplyUser{Uid: 2}), io.EOF)
+	})
+
+	// 同一连接上的多个流并发收发，每个流中消息的顺序不变
+	t.Run("多个流并发-保持顺序", func(t *testing.T) {
+		const streams, messages = 10, 50
+
+		var wg sync.WaitGroup
+		for i := 0; i < streams; i++ {
+			wg.Add(1)
+			go func(base int64) {
+				defer wg.Done()
+
+				stream, err := userClient.GetUsers(context.Background())
+				if !assert.NoError(t, err) {
+					return
+				}
+				go func() {
+					for uid := base + 1; uid <= base+messages; uid++ {
+						if stream.Send(&pb.ApplyUser{Uid: uid}) != nil {
+							return
+						}
+					}
+					stream.CloseSend()
+				}()
+
+				want := base + 1
+				for {
+					reply, err := stream.Recv()
+					if err != nil {
+						assert.ErrorIs(t, err, io.EOF)
+						break
+					}
+					assert.Equal(t, want, reply.User.Uid)
+					want++
+				}
+				assert.Equal(t, base+messages+1, want)
+			}(int64(i * 1000))
+		}
+		wg.Wait()
+	})
+}
+
+func TestStream_BidiCancel(t *testing.T) {
+	impl := &listServiceImpl{canceled: make(chan error, 1)}
+	server := startTestServer(t, &pb.UserServiceDesc, impl)
+	client := newTestClient(t, server.listener.Addr().String())
+	userClient := pb.NewUserClient(client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := userClient.GetUsers(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.ApplyUser{Uid: 1}))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	// 客户端取消后，服务端的 Recv 返回 ctx 的错误
+	cancel()
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, stream.Send(&pb.ApplyUser{Uid: 2}), io.EOF)
+	select {
+	case err := <-impl.canceled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("服务端的流没有被取消")
+	}
+}
+
+func TestStream_Unimplemented(t *testing.T) {
+	type nilEmbedUserServiceImpl struct {
+		pb.IUserService
+	}
+
+	tests := []struct {
+		name     string
+		impl     pb.IUserService
+		method   string
+		wantCode codes.Code
+	}{
+		{
+			name:     "不存在的服务",
+			impl:     &listServiceImpl{},
+			method:   "not_exist.ListUsers",
+			wantCode: codes.NotFound,
+		},
+		{
+			name:     "不存在的流式方法",
+			impl:     &listServiceImpl{},
+			method:   pb.UserServiceName + ".ListGroups",
+			wantCode: codes.Unimplemented,
+		},
+		{
+			name:     "一元方法不能作为流调用",
+			impl:     &listServiceImpl{},
+			method:   pb.User_User_FullMethodName,
+			wantCode: codes.Unimplemented,
+		},
+		{
+			name:     "嵌入nil接口",
+			impl:     &nilEmbedUserServiceImpl{},
+			method:   pb.User_ListUsers_FullMethodName,
+			wantCode: codes.Unimplemented,
+		},
+		{
+			name:     "嵌入Unimplemented类型",
+			impl:     &errorServiceImpl{},
+			method:   pb.User_ListUsers_FullMethodName,
+			wantCode: codes.Unimplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startTestServer(t, &pb.UserServiceDesc, tt.impl)
+			client := newTestClient(t, server.listener.Addr().String())
+
+			stream, err := client.NewStream(context.Background(), &pb.UserServiceDesc.Streams[0], tt.method)
+			require.NoError(t, err)
+			// 服务端可能已经结束了流，此时 SendMsg 返回 io.EOF，结束的原因由 RecvMsg 返回
+			if err := stream.SendMsg(&pb.ApplyList{}); err != nil {
+				require.ErrorIs(t, err, io.EOF)
+			}
+			require.NoError(t, stream.CloseSend())
+
+			err = stream.RecvMsg(&pb.User{})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+
+	// 流式方法不能作为一元方法调用
+	server := startTestServer(t, &pb.UserServiceDesc, &listServiceImpl{})
+	client := newTestClient(t, server.listener.Addr().String())
+	err := client.Invoke(context.Background(), pb.User_ListUsers_FullMethodName, &pb.ApplyList{}, &pb.User{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestClient_NewStream(t *testing.T) {
+	server := startTestServer(t, &pb.UserServiceDesc, &listServiceImpl{})
+	client := newTestClient(t, server.listener.Addr().String())
+	desc := &pb.UserServiceDesc.Streams[0]
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		desc    *api.StreamDesc
+		method  string
+		wantErr error
+	}{
+		{
+			name:   "空流描述-失败",
+			ctx:    context.Background(),
+			method: pb.User_ListUsers_FullMethodName,
+		},
+		{
+			name:   "方法格式不正确-失败",
+			ctx:    context.Background(),
+			desc:   desc,
+			method: "ListUsers",
+		},
+		{
+			name:    "ctx已取消-失败",
+			ctx:     canceled,
+			desc:    desc,
+			method:  pb.User_ListUsers_FullMethodName,
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := client.NewStream(tt.ctx, tt.desc, tt.method)
+			assert.Nil(t, stream)
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	// CloseSend 之后不能再发送
+	stream, err := client.NewStream(context.Background(), desc, pb.User_ListUsers_FullMethodName)
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMsg(&pb.ApplyList{}))
+	require.NoError(t, stream.CloseSend())
+	assert.ErrorIs(t, stream.SendMsg(&pb.ApplyList{}), errSendClosed)
+
+	// 客户端关闭后不能打开流
+	client.Close()
+	_, err = client.NewStream(context.Background(), desc, pb.User_ListUsers_FullMethodName)
+	assert.ErrorIs(t, err, ErrClientClosed)
+}
@@ -0,0 +1,218 @@
+//go:build unit
+
+package trpc
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"testing"
+	"time"
+	"v2/pb"
+	"v2/trpc/codes"
+	"v2/trpc/credentials"
+	"v2/trpc/internal/testcert"
+	"v2/trpc/peer"
+	"v2/trpc/status"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// peerHelloImpl 在响应中返回客户端证书的 CommonName
+type peerHelloImpl struct{}
+
+func (s *peerHelloImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "没有对端信息")
+	}
+	if p.TLS == nil {
+		return &pb.ReplyHello{Msg: "plaintext"}, nil
+	}
+	if cert := p.Certificate(); cert != nil {
+		return &pb.ReplyHello{Msg: cert.Subject.CommonName}, nil
+	}
+	return &pb.ReplyHello{Msg: "anonymous"}, nil
+}
+
+// tlsFiles 测试用的证书文件
+type tlsFiles struct {
+	dir      string
+	ca       *testcert.CA
+	caFile   string
+	certFile string // 服务端证书
+	keyFile  string
+}
+
+func newTLSFiles(t *testing.T) *tlsFiles {
+	f := &tlsFiles{dir: t.TempDir(), ca: testcert.NewCA(t, "test-ca")}
+	f.caFile = testcert.WriteFile(t, f.dir, "ca.pem", f.ca.PEM)
+	certPEM, keyPEM := f.ca.Issue(t, "server", "127.0.0.1", "localhost")
+	f.certFile = testcert.WriteFile(t, f.dir, "server.pem", certPEM)
+	f.keyFile = testcert.WriteFile(t, f.dir, "server-key.pem", keyPEM)
+	return f
+}
+
+// issueClient 签发客户端证书，返回证书和私钥文件
+func (f *tlsFiles) issueClient(t *testing.T, commonName string) (string, string) {
+	certPEM, keyPEM := f.ca.Issue(t, commonName)
+	return testcert.WriteFile(t, f.dir, commonName+".pem", certPEM),
+		testcert.WriteFile(t, f.dir, commonName+"-key.pem", keyPEM)
+}
+
+func TestTLS(t *testing.T) {
+	f := newTLSFiles(t)
+	serverCfg, err := credentials.ServerTLSConfig(f.certFile, f.keyFile, "")
+	require.NoError(t, err)
+	addr := startTestServer(t, &pb.HelloServiceDesc, &peerHelloImpl{}, TLS(serverCfg)).listener.Addr().String()
+
+	clientCfg, err := credentials.ClientTLSConfig(f.caFile, "", "")
+	require.NoError(t, err)
+	client := newTestClient(t, addr, WithTLS(clientCfg))
+
+	// 服务端不要求客户端证书
+	resp, err := pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "TLS"})
+	require.NoError(t, err)
+	assert.Equal(t, "anonymous", resp.Msg)
+}
+
+func TestTLS_UntrustedServer(t *testing.T) {
+	f := newTLSFiles(t)
+	serverCfg, err := credentials.ServerTLSConfig(f.certFile, f.keyFile, "")
+	require.NoError(t, err)
+	addr := startTestServer(t, &pb.HelloServiceDesc, &peerHelloImpl{}, TLS(serverCfg)).listener.Addr().String()
+
+	tests := []struct {
+		name string
+		cfg  func() *tls.Config
+	}{
+		{
+			name: "不信任服务端的CA",
+			cfg: func() *tls.Config {
+				other := newTLSFiles(t)
+				cfg, err := credentials.ClientTLSConfig(other.caFile, "", "")
+				require.NoError(t, err)
+				return cfg
+			},
+		},
+		{
+			name: "服务端名称不匹配",
+			cfg: func() *tls.Config {
+				cfg, err := credentials.ClientTLSConfig(f.caFile, "", "")
+				require.NoError(t, err)
+				cfg.ServerName = "users.internal"
+				return cfg
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient("tcp", addr, WithContextDialer(dialTest), WithTLS(tt.cfg()))
+			assert.ErrorContains(t, err, "TLS 握手失败")
+		})
+	}
+}
+
+func TestMTLS(t *testing.T) {
+	f := newTLSFiles(t)
+	serverCfg, err := credentials.ServerTLSConfig(f.certFile, f.keyFile, f.caFile)
+	require.NoError(t, err)
+	addr := startTestServer(t, &pb.HelloServiceDesc, &peerHelloImpl{}, TLS(serverCfg)).listener.Addr().String()
+
+	// 服务方法获取到客户端证书的身份
+	certFile, keyFile := f.issueClient(t, "order-service")
+	clientCfg, err := credentials.ClientTLSConfig(f.caFile, certFile, keyFile)
+	require.NoError(t, err)
+	client := newTestClient(t, addr, WithTLS(clientCfg))
+
+	resp, err := pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "mTLS"})
+	require.NoError(t, err)
+	assert.Equal(t, "order-service", resp.Msg)
+}
+
+func TestMTLS_Rejected(t *testing.T) {
+	f := newTLSFiles(t)
+	serverCfg, err := credentials.ServerTLSConfig(f.certFile, f.keyFile, f.caFile)
+	require.NoError(t, err)
+	addr := startTestServer(t, &pb.HelloServiceDesc, &peerHelloImpl{}, TLS(serverCfg)).listener.Addr().String()
+
+	other := newTLSFiles(t)
+	untrustedCert, untrustedKey := other.issueClient(t, "intruder")
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+	}{
+		{name: "没有客户端证书"},
+		{name: "客户端证书不是由信任的CA签发", certFile: untrustedCert, keyFile: untrustedKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientCfg, err := credentials.ClientTLSConfig(f.caFile, tt.certFile, tt.keyFile)
+			require.NoError(t, err)
+
+			// TLS 1.3 中服务端在客户端握手完成后才验证客户端证书，错误在 NewClient 或第一次调用时返回
+			client, err := NewClient("tcp", addr, WithContextDialer(dialTest), WithTLS(clientCfg), WithBackoff(testBackoff))
+			if err != nil {
+				return
+			}
+			defer client.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_, err = pb.NewHelloClient(client).Hello(ctx, &pb.ApplyHello{Name: "mTLS"})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTLS_PeerWithoutTLS(t *testing.T) {
+	server := startTestServer(t, &pb.HelloServiceDesc, &peerHelloImpl{})
+
+	client := newTestClient(t, server.listener.Addr().String())
+
+	// 没有使用 TLS 时也能获取对端地址
+	resp, err := pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "Plain"})
+	require.NoError(t, err)
+	assert.Equal(t, "plaintext", resp.Msg)
+}
+
+func TestTLS_CertReload(t *testing.T) {
+	f := newTLSFiles(t)
+	serverCfg, err := credentials.ServerTLSConfig(f.certFile, f.keyFile, "")
+	require.NoError(t, err)
+	addr := startTestServer(t, &pb.HelloServiceDesc, &peerHelloImpl{}, TLS(serverCfg)).listener.Addr().String()
+
+	clientCfg, err := credentials.ClientTLSConfig(f.caFile, "", "")
+	require.NoError(t, err)
+	serverName := func() string {
+		rawConn, err := dialTest(context.Background(), addr)
+		require.NoError(t, err)
+		cfg := clientCfg.Clone()
+		cfg.ServerName = "localhost"
+		conn := tls.Client(rawConn, cfg)
+		defer conn.Close()
+		require.NoError(t, conn.Handshake())
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+	assert.Equal(t, "server", serverName())
+
+	// 轮换证书后，新的连接使用新的证书
+	client := newTestClient(t, addr, WithTLS(clientCfg))
+
+	certPEM, keyPEM := f.ca.Issue(t, "server-rotated", "127.0.0.1", "localhost")
+	testcert.WriteFile(t, f.dir, "server.pem", certPEM)
+	testcert.WriteFile(t, f.dir, "server-key.pem", keyPEM)
+	mtime := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(f.certFile, mtime, mtime))
+	require.NoError(t, os.Chtimes(f.keyFile, mtime, mtime))
+	assert.Equal(t, "server-rotated", serverName())
+
+	// 已经建立的连接不受影响
+	_, err = pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "Rotated"})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,416 @@
+package trpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"v2/trpc/codes"
+	"v2/trpc/status"
+)
+
+var errTransportDrained = errors.New("连接已从连接池中移除")
+
+// connError 将连接不可用的原因转换为 codes.Unavailable，调用方可以换一条连接重试
+func connError(err error) error {
+	return status.Errorf(codes.Unavailable, "连接不可用: %v", err)
+}
+
+// clientTransport 客户端的一条连接，可以被多个 goroutine 并发使用：
+// 每个请求带有唯一的 Seq，后台的读 goroutine 根据响应中的 Seq 将其分发给对应的调用方，
+// 写 goroutine 按顺序写入所有调用方的帧。
+// 流与一元调用共用 Seq，流 ID 即打开流的请求的 Seq
+type clientTransport struct {
+	conn           net.Conn
+	maxMessageSize int
+
+	// 帧由写 goroutine 按顺序逐个写入，调用方放弃等待时不会中断写入到一半的帧
+	wmu      sync.Mutex
+	writes   []*writeReq   // 等待写入的帧
+	writeErr error         // 写 goroutine 已退出的原因，非 nil 时不再接受新的帧
+	writeCh  chan struct{} // 有新的帧时通知写 goroutine
+	done     chan struct{} // 连接不可用时关闭
+
+	mu      sync.Mutex
+	seq     uint64
+	pending map[uint64]chan *Reply   // 等待响应的调用
+	streams map[uint64]*clientStream // 还没有结束的流
+	err     error                    // 连接不可用的原因，非 nil 时不再接受新的调用
+	drained bool                     // 连接已从连接池中移除，进行中的调用和流结束后关闭
+
+	onClose  func(*clientTransport) // 连接不可用时调用一次
+	lastUsed time.Time              // 最后一次被选中或有调用进行中的时间，由连接池维护
+}
+
+func newClientTransport(conn net.Conn, maxMessageSize int, onClose func(*clientTransport)) *clientTransport {
+	t := &clientTransport{
+		conn:           conn,
+		maxMessageSize: maxMessageSize,
+		onClose:        onClose,
+		pending:        make(map[uint64]chan *Reply),
+		streams:        make(map[uint64]*clientStream),
+		lastUsed:       time.Now(),
+		writeCh:        make(chan struct{}, 1),
+		done:           make(chan struct{}),
+	}
+	go t.readLoop()
+	go t.writeLoop()
+	return t
+}
+
+// close 关闭连接，等待中的调用和流以 err 结束
+func (t *clientTransport) close(err error) error {
+	t.fail(err)
+	return t.conn.Close()
+}
+
+// load 进行中的调用和流的数量
+func (t *clientTransport) load() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending) + len(t.streams)
+}
+
+// register 分配请求序号并登记等待响应的 channel
+func (t *clientTransport) register() (uint64, chan *Reply, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return 0, nil, connError(t.err)
+	}
+	if t.drained {
+		return 0, nil, connError(errTransportDrained)
+	}
+
+	t.seq++
+	ch := make(chan *Reply, 1)
+	t.pending[t.seq] = ch
+	return t.seq, ch, nil
+}
+
+func (t *clientTransport) unregister(seq uint64) {
+	t.mu.Lock()
+	delete(t.pending, seq)
+	t.mu.Unlock()
+	t.closeIfDrained()
+}
+
+// registerStream 分配流 ID 并登记流
+func (t *clientTransport) registerStream(cs *clientStream) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return connError(t.err)
+	}
+	if t.drained {
+		return connError(errTransportDrained)
+	}
+
+	t.seq++
+	cs.id = t.seq
+	t.streams[cs.id] = cs
+	return nil
+}
+
+// removeStream 移除并返回流，流已经被移除时返回 nil
+func (t *clientTransport) removeStream(id uint64) *clientStream {
+	t.mu.Lock()
+	cs := t.streams[id]
+	delete(t.streams, id)
+	t.mu.Unlock()
+
+	t.closeIfDrained()
+	return cs
+}
+
+// drain 不再接受新的调用，进行中的调用和流结束后关闭连接
+func (t *clientTransport) drain() {
+	t.mu.Lock()
+	t.drained = true
+	t.mu.Unlock()
+	t.closeIfDrained()
+}
+
+// closeIfDrained 连接已从连接池中移除且没有进行中的调用和流时关闭连接
+func (t *clientTransport) closeIfDrained() {
+	t.mu.Lock()
+	idle := t.drained && t.err == nil && len(t.pending)+len(t.streams) == 0
+	t.mu.Unlock()
+
+	if idle {
+		t.close(errTransportDrained)
+	}
+}
+
+func (t *clientTransport) getStream(id uint64) *clientStream {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.streams[id]
+}
+
+func (t *clientTransport) closeErr() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// checkFrame 检查帧能否发送，请求超过大小限制时与服务端一致返回 codes.ResourceExhausted
+func (t *clientTransport) checkFrame(codecName string, payload []byte) error {
+	err := checkFrame(codecName, payload, t.maxMessageSize)
+	if errors.Is(err, ErrMessageTooLarge) {
+		return status.Errorf(codes.ResourceExhausted, "请求超过大小限制: %v", err)
+	}
+	return err
+}
+
+// writeReq 等待写 goroutine 写入的帧
+type writeReq struct {
+	flags    uint8
+	codec    string
+	payload  []byte
+	deadline time.Time  // 调用方 ctx 的截止时间，作为写超时
+	done     chan error // 写入完成后收到结果
+
+	// 以下字段由 wmu 保护
+	started  bool // 已开始写入
+	canceled bool // 调用方在开始写入前放弃，不再写入
+}
+
+// send 将帧交给写 goroutine 写入并等待结果。帧要么完整写入，要么完全不写入：
+// ctx 在开始写入前结束时放弃写入，开始写入后结束时不再等待，帧在后台写完，连接保持可用。
+// ctx 的截止时间作为写超时，超时时帧只写入了一部分，说明连接已经阻塞，断开连接。
+// 连接不可用时返回 codes.Unavailable
+func (t *clientTransport) send(ctx context.Context, flags uint8, codecName string, payload []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := t.checkFrame(codecName, payload); err != nil {
+		return err
+	}
+
+	req := &writeReq{flags: flags, codec: codecName, payload: payload, done: make(chan error, 1)}
+	req.deadline, _ = ctx.Deadline()
+	if err := t.enqueue(req); err != nil {
+		return connError(err)
+	}
+
+	select {
+	case err := <-req.done:
+		switch {
+		case err == nil, errors.Is(err, context.DeadlineExceeded):
+			return err
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			return context.DeadlineExceeded
+		}
+		return connError(err)
+	case <-ctx.Done():
+		t.wmu.Lock()
+		started := req.started
+		req.canceled = !started
+		t.wmu.Unlock()
+		// 写超时与 ctx 的截止时间相同，写 goroutine 也会立即结束写入，等待其结束以确定连接是否完整
+		if started && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			<-req.done
+		}
+		return ctx.Err()
+	}
+}
+
+// enqueue 将帧加入写入队列，写 goroutine 已退出时返回退出的原因
+func (t *clientTransport) enqueue(req *writeReq) error {
+	t.wmu.Lock()
+	if t.writeErr != nil {
+		t.wmu.Unlock()
+		return t.writeErr
+	}
+	t.writes = append(t.writes, req)
+	t.wmu.Unlock()
+
+	select {
+	case t.writeCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// nextWrite 等待并返回下一个需要写入的帧，跳过调用方已经放弃的帧，连接不可用时返回 nil
+func (t *clientTransport) nextWrite() *writeReq {
+	for {
+		t.wmu.Lock()
+		for len(t.writes) > 0 {
+			req := t.writes[0]
+			t.writes[0] = nil
+			t.writes = t.writes[1:]
+			if req.canceled {
+				continue
+			}
+			// 排队期间已经超时的帧不再写入
+			if !req.deadline.IsZero() && !time.Now().Before(req.deadline) {
+				req.done <- context.DeadlineExceeded
+				continue
+			}
+			req.started = true
+			t.wmu.Unlock()
+			return req
+		}
+		t.wmu.Unlock()
+
+		select {
+		case <-t.writeCh:
+		case <-t.done:
+			return nil
+		}
+	}
+}
+
+// writeLoop 按顺序写入队列中的帧，写入失败时断开连接，退出时以连接不可用的原因结束队列中的帧
+func (t *clientTransport) writeLoop() {
+	for {
+		req := t.nextWrite()
+		if req == nil {
+			break
+		}
+
+		if !req.deadline.IsZero() {
+			t.conn.SetWriteDeadline(req.deadline)
+		}
+		w := &countWriter{w: t.conn}
+		err := writeFrame(w, req.flags, req.codec, req.payload, t.maxMessageSize)
+		if !req.deadline.IsZero() {
+			t.conn.SetWriteDeadline(time.Time{})
+		}
+		req.done <- err
+		// 没有写入任何数据就超时时连接仍然完整；帧只写入了一部分或连接出错时数据已经不完整，只能断开
+		if err != nil && (w.n > 0 || !errors.Is(err, os.ErrDeadlineExceeded)) {
+			t.close(fmt.Errorf("写入请求失败: %w", err))
+			break
+		}
+	}
+
+	err := t.closeErr()
+	t.wmu.Lock()
+	t.writeErr = err
+	writes := t.writes
+	t.writes = nil
+	t.wmu.Unlock()
+	for _, req := range writes {
+		req.done <- err
+	}
+}
+
+// countWriter 记录已经写入的字节数
+type countWriter struct {
+	w io.Writer
+	n int
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += n
+	return n, err
+}
+
+// readLoop 持续读取响应，并根据 Seq 分发给等待中的调用或流
+func (t *clientTransport) readLoop() {
+	for {
+		f, err := readFrame(t.conn, t.maxMessageSize)
+		if err != nil {
+			t.close(err)
+			return
+		}
+
+		if err := t.dispatch(f); err != nil {
+			t.close(err)
+			return
+		}
+	}
+}
+
+// dispatch 按帧类型分发，调用方已经放弃等待的响应和已经结束的流的消息直接丢弃
+func (t *clientTransport) dispatch(f *frame) error {
+	switch f.flags {
+	case frameUnary:
+		r, err := UnmarshalReply(f.payload)
+		if err != nil {
+			return err
+		}
+
+		t.mu.Lock()
+		ch, ok := t.pending[r.Seq]
+		delete(t.pending, r.Seq)
+		t.mu.Unlock()
+
+		if ok {
+			ch <- r
+		}
+		t.closeIfDrained()
+	case frameStreamHeader:
+		r, err := UnmarshalReply(f.payload)
+		if err != nil {
+			return err
+		}
+		if cs := t.getStream(r.Seq); cs != nil {
+			cs.setHeader(r.Header)
+		}
+	case frameStreamMessage:
+		id, data, err := readUvarint(f.payload)
+		if err != nil {
+			return err
+		}
+		// 服务端没有遵守流量控制时结束 ctx，由 watch 结束流并通知服务端取消
+		if cs := t.getStream(id); cs != nil && !cs.recv.put(data) {
+			cs.cancel()
+		}
+	case frameStreamWindowUpdate:
+		id, n, err := parseWindowUpdate(f.payload)
+		if err != nil {
+			return err
+		}
+		if cs := t.getStream(id); cs != nil {
+			cs.quota.add(n)
+		}
+	case frameStreamEnd:
+		r, err := UnmarshalReply(f.payload)
+		if err != nil {
+			return err
+		}
+		if cs := t.removeStream(r.Seq); cs != nil {
+			cs.finish(r)
+		}
+	default:
+		return fmt.Errorf("未知的帧类型: %d", f.flags)
+	}
+	return nil
+}
+
+// fail 标记连接不可用，唤醒所有等待中的调用并结束所有的流，然后通知连接池
+func (t *clientTransport) fail(err error) {
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return
+	}
+
+	t.err = err
+	close(t.done)
+	for seq, ch := range t.pending {
+		close(ch)
+		delete(t.pending, seq)
+	}
+	for id, cs := range t.streams {
+		cs.abort(connError(err))
+		delete(t.streams, id)
+	}
+	t.mu.Unlock()
+
+	if t.onClose != nil {
+		t.onClose(t)
+	}
+}
@@ -0,0 +1,18 @@
+// Package trpctest 提供测试 trpc 服务的工具：内存中的监听和对应的 dialer，
+// 以及在内存监听上启动服务并返回已连接的客户端的 StartServer，测试不需要绑定端口，也不需要等待服务启动
+package trpctest
+
+import "v2/trpc/internal/bufconn"
+
+// bufferSize 每条连接每个方向的缓冲区大小
+const bufferSize = 1 << 20
+
+// Listener 内存中的监听，通过 Dial 或 DialContext 建立的连接由 Accept 返回，
+// DialContext 可以直接用作 trpc.WithContextDialer 的参数。
+// 连接与 TCP 一样带有缓冲区（每个方向 1MB），Write 在缓冲区写满之前不需要等待对端读取，支持读写超时
+type Listener = bufconn.Listener
+
+// NewListener 创建内存中的监听
+func NewListener() *Listener {
+	return bufconn.Listen("bufconn", bufferSize)
+}
@@ -0,0 +1,40 @@
+package trpctest
+
+import (
+	"v2/api"
+	"v2/trpc"
+)
+
+// Service 要注册的服务和服务实现
+type Service struct {
+	Desc *api.ServiceDesc
+	Impl any
+}
+
+// StartServer 在内存监听上启动注册了 services 的服务，返回连接到该服务的客户端，
+// cleanup 关闭客户端和服务。注册服务或建立连接失败时返回错误，此时不需要调用 cleanup
+func StartServer(services []Service, serverOpts []trpc.ServerOption, clientOpts ...trpc.ClientOption) (*trpc.Client, func(), error) {
+	server := trpc.NewServerWithoutListener(serverOpts...)
+	for _, svc := range services {
+		if err := server.RegisterService(svc.Desc, svc.Impl); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	lis := NewListener()
+	go server.Serve(lis)
+
+	// 服务开始 Accept 之前 dial 会等待，不需要等待服务启动；Serve 出错返回时关闭监听，dial 随之失败
+	opts := append([]trpc.ClientOption{trpc.WithContextDialer(lis.DialContext)}, clientOpts...)
+	client, err := trpc.NewClient("tcp", lis.Addr().String(), opts...)
+	if err != nil {
+		server.Stop()
+		return nil, nil, err
+	}
+
+	cleanup := func() {
+		client.Close()
+		server.Stop()
+	}
+	return client, cleanup, nil
+}
@@ -0,0 +1,100 @@
+//go:build unit
+
+package trpctest
+
+import (
+	"context"
+	"testing"
+	"v2/pb"
+	"v2/trpc"
+	"v2/trpc/metadata"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type helloImpl struct{}
+
+func (helloImpl) Hello(ctx context.Context, apply *pb.ApplyHello) (*pb.ReplyHello, error) {
+	return &pb.ReplyHello{Msg: "Hello, " + apply.Name + "!"}, nil
+}
+
+func TestStartServer(t *testing.T) {
+	t.Parallel()
+
+	// 服务端和客户端的配置都生效
+	var serverMethods, clientMethods []string
+	client, cleanup, err := StartServer(
+		[]Service{{Desc: &pb.HelloServiceDesc, Impl: helloImpl{}}},
+		[]trpc.ServerOption{trpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *trpc.UnaryServerInfo, handler trpc.UnaryHandler) (any, error) {
+			serverMethods = append(serverMethods, info.FullMethod)
+			return handler(ctx, req)
+		})},
+		trpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *trpc.Client, invoker trpc.UnaryInvoker) error {
+			clientMethods = append(clientMethods, method)
+			return invoker(ctx, method, req, reply)
+		}),
+	)
+	require.NoError(t, err)
+	defer cleanup()
+
+	resp, err := pb.NewHelloClient(client).Hello(context.Background(), &pb.ApplyHello{Name: "Bufconn"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Bufconn!", resp.Msg)
+	assert.Equal(t, []string{"hello_service.Hello"}, serverMethods)
+	assert.Equal(t, []string{"hello_service.Hello"}, clientMethods)
+}
+
+func TestStartServer_Metadata(t *testing.T) {
+	t.Parallel()
+
+	client, cleanup, err := StartServer([]Service{{Desc: &pb.HelloServiceDesc, Impl: helloImpl{}}}, []trpc.ServerOption{
+		trpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *trpc.UnaryServerInfo, handler trpc.UnaryHandler) (any, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			trpc.SetHeader(ctx, metadata.Pairs("echo", md.Get("trace-id")))
+			return handler(ctx, req)
+		}),
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "trace-id", "abc")
+	ctx = trpc.WithCallOptions(ctx, trpc.Header(&header))
+	_, err = pb.NewHelloClient(client).Hello(ctx, &pb.ApplyHello{Name: "Metadata"})
+	require.NoError(t, err)
+	assert.Equal(t, "abc", header.Get("echo"))
+}
+
+func TestStartServer_Error(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		services []Service
+		wantErr  string // 为空时只检查是否返回错误
+	}{
+		// 服务启动失败后关闭监听，建立连接失败
+		{name: "没有注册服务"},
+		{
+			name: "重复注册",
+			services: []Service{
+				{Desc: &pb.HelloServiceDesc, Impl: helloImpl{}},
+				{Desc: &pb.HelloServiceDesc, Impl: helloImpl{}},
+			},
+			wantErr: "重复注册",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, cleanup, err := StartServer(tt.services, nil)
+			require.Error(t, err)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+			assert.Nil(t, client)
+			assert.Nil(t, cleanup)
+		})
+	}
+}